@@ -2,14 +2,25 @@ package submodule
 
 import (
 	"context"
+	"math"
+	"sort"
+	"time"
 
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cst"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 	appstate "github.com/filecoin-project/go-filecoin/internal/pkg/state"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
 )
@@ -19,6 +30,9 @@ type ChainSubmodule struct {
 	ChainReader  *chain.Store
 	MessageStore *chain.MessageStore
 	State        *cst.ChainStateReadWriter
+	// MessageInclusions indexes the messages included near the head, to
+	// reject blocks re-including them.
+	MessageInclusions *chain.MessageInclusionIndex
 	// HeavyTipSetCh is a subscription to the heaviest tipset topic on the chain.
 	// https://github.com/filecoin-project/go-filecoin/issues/2309
 	HeaviestTipSetCh chan interface{}
@@ -28,6 +42,16 @@ type ChainSubmodule struct {
 	Processor  *consensus.DefaultProcessor
 
 	StatusReporter *chain.StatusReporter
+
+	// BlockTime is the duration of an epoch on this chain.
+	BlockTime time.Duration
+
+	// gasPriceFloor is the gas price estimated when recent tipsets include no
+	// messages.
+	gasPriceFloor types.AttoFIL
+
+	// blockstore backs the vm storage used when re-running state transitions.
+	blockstore blockstore.Blockstore
 }
 
 // xxx go back to using an interface here
@@ -45,10 +69,12 @@ type ChainSubmodule struct {
 */
 type chainRepo interface {
 	ChainDatastore() repo.Datastore
+	Config() *config.Config
 }
 
 type chainConfig interface {
 	GenesisCid() cid.Cid
+	BlockTime() time.Duration
 }
 
 // NewChainSubmodule creates a new chain submodule.
@@ -56,24 +82,37 @@ func NewChainSubmodule(config chainConfig, repo chainRepo, blockstore *Blockstor
 	// initialize chain store
 	chainStatusReporter := chain.NewStatusReporter()
 	chainStore := chain.NewStore(repo.ChainDatastore(), blockstore.CborStore, state.NewTreeLoader(), chainStatusReporter, config.GenesisCid())
+	chainStore.SetFinalityDepth(abi.ChainEpoch(repo.Config().Chain.FinalityDepth))
 
 	// set up processor
 	sampler := chain.NewSampler(chainStore)
 	processor := consensus.NewDefaultProcessor(sampler)
 
 	actorState := appstate.NewTipSetStateViewer(chainStore, blockstore.CborStore)
+	processor.SetStateRootRecorder(actorState)
 	messageStore := chain.NewMessageStore(blockstore.Blockstore)
+	inclusions := chain.NewMessageInclusionIndex(chainStore, messageStore, chain.DefaultInclusionWindow, chain.DefaultInclusionBatch)
+	chainStore.SetMessageInclusionIndex(inclusions)
 	chainState := cst.NewChainStateReadWriter(chainStore, messageStore, blockstore.Blockstore, builtin.DefaultActors)
 
+	blockTime := config.BlockTime()
+	if blockTime == 0 {
+		blockTime = clock.DefaultEpochDuration
+	}
+
 	return ChainSubmodule{
-		ChainReader:  chainStore,
-		MessageStore: messageStore,
+		ChainReader:       chainStore,
+		MessageStore:      messageStore,
+		MessageInclusions: inclusions,
 		// HeaviestTipSetCh nil
 		Sampler:        sampler,
 		ActorState:     actorState,
 		State:          chainState,
 		Processor:      processor,
 		StatusReporter: chainStatusReporter,
+		BlockTime:      blockTime,
+		gasPriceFloor:  repo.Config().Mpool.GasPriceFloor(),
+		blockstore:     blockstore.Blockstore,
 	}, nil
 }
 
@@ -85,3 +124,283 @@ type chainNode interface {
 func (c *ChainSubmodule) Start(ctx context.Context, node chainNode) error {
 	return node.Chain().ChainReader.Load(ctx)
 }
+
+// NewEpochClock returns a clock counting epochs of the chain's block time from
+// the timestamp of its genesis block, reading the time from `clk`.
+func (c *ChainSubmodule) NewEpochClock(ctx context.Context, clk clock.Clock) (clock.ChainEpochClock, error) {
+	genesis, err := c.ChainReader.GetGenesisBlock(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load genesis block")
+	}
+	return clock.NewChainClockFromClock(genesis.Timestamp, c.BlockTime, clk), nil
+}
+
+// ValidateTipSet validates `ts` against its parent, returning the first failure.
+// It runs the consensus block syntax and semantic validators on every block,
+// validates the syntax and signatures of their messages, including the BLS
+// aggregate, rejects messages already included by an ancestor, and re-runs
+// the parent's messages through the processor to confirm the state and
+// receipt roots claimed by the blocks of `ts`.
+func (c *ChainSubmodule) ValidateTipSet(ctx context.Context, ts block.TipSet) error {
+	chainClock, err := c.NewEpochClock(ctx, clock.NewSystemClock())
+	if err != nil {
+		return err
+	}
+	validator := consensus.NewDefaultBlockValidator(chainClock)
+	return validateTipSet(ctx, c.ChainReader, c.MessageStore, c.MessageInclusions, validator, c.Processor, vm.NewStorage(c.blockstore), ts)
+}
+
+// IsValidExtension returns true if `ts` extends a tipset known to the chain
+// store: its parent tipset is stored and its height is above the parent's,
+// leaving room for any null rounds between them. An error is returned if `ts`
+// is malformed or its parent can't be loaded for a reason other than being
+// unknown.
+func (c *ChainSubmodule) IsValidExtension(ts block.TipSet) (bool, error) {
+	return isValidExtension(c.ChainReader, ts)
+}
+
+func isValidExtension(reader chain.TipSetProvider, ts block.TipSet) (bool, error) {
+	parentKey, err := ts.Parents()
+	if err != nil {
+		return false, err
+	}
+	height, err := ts.Height()
+	if err != nil {
+		return false, err
+	}
+	parent, err := reader.GetTipSet(parentKey)
+	if errors.Cause(err) == chain.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to load parent tipset %s", parentKey)
+	}
+	parentHeight, err := parent.Height()
+	if err != nil {
+		return false, err
+	}
+	// Heights skipped between the parent and `ts` are null rounds.
+	return height >= parentHeight+1, nil
+}
+
+// EstimateGasPrice returns the `percentile` (from 0 to 100) of the gas prices
+// paid by the distinct messages included in the tipsets within `lookback`
+// epochs of the head, or the configured gas price floor if there are none, see
+// config.MessagePoolConfig.GasPriceFloor.
+func (c *ChainSubmodule) EstimateGasPrice(ctx context.Context, percentile float64, lookback abi.ChainEpoch) (types.AttoFIL, error) {
+	head, err := c.ChainReader.GetTipSet(c.ChainReader.GetHead())
+	if err != nil {
+		return types.ZeroAttoFIL, errors.Wrap(err, "failed to load head")
+	}
+	return estimateGasPrice(ctx, c.ChainReader, c.MessageStore, head, percentile, lookback, c.gasPriceFloor)
+}
+
+func estimateGasPrice(ctx context.Context, tipsets chain.TipSetProvider, messages chain.MessageProvider, head block.TipSet, percentile float64, lookback abi.ChainEpoch, floor types.AttoFIL) (types.AttoFIL, error) {
+	if percentile < 0 || percentile > 100 {
+		return types.ZeroAttoFIL, errors.Errorf("percentile %f out of range", percentile)
+	}
+	headHeight, err := head.Height()
+	if err != nil {
+		return types.ZeroAttoFIL, err
+	}
+
+	var prices []types.AttoFIL
+	// A message included by several blocks of a tipset is counted once.
+	seen := make(map[cid.Cid]struct{})
+	err = chain.WalkAncestors(ctx, tipsets, head, func(ts block.TipSet) (bool, error) {
+		height, err := ts.Height()
+		if err != nil {
+			return false, err
+		}
+		if height <= headHeight-lookback {
+			return true, nil
+		}
+		for i := 0; i < ts.Len(); i++ {
+			blk := ts.At(i)
+			secpMsgs, blsMsgs, err := messages.LoadMessages(ctx, blk.Messages.Cid)
+			if err != nil {
+				return false, errors.Wrapf(err, "failed to load messages for block %s", blk.Cid())
+			}
+			for _, msg := range secpMsgs {
+				mcid, err := msg.OnChainCid()
+				if err != nil {
+					return false, err
+				}
+				if _, ok := seen[mcid]; !ok {
+					seen[mcid] = struct{}{}
+					prices = append(prices, msg.Message.GasPrice)
+				}
+			}
+			for _, msg := range blsMsgs {
+				mcid, err := msg.Cid()
+				if err != nil {
+					return false, err
+				}
+				if _, ok := seen[mcid]; !ok {
+					seen[mcid] = struct{}{}
+					prices = append(prices, msg.GasPrice)
+				}
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return types.ZeroAttoFIL, err
+	}
+	if len(prices) == 0 {
+		return floor, nil
+	}
+
+	sort.Slice(prices, func(i, j int) bool { return prices[i].LessThan(prices[j]) })
+	// Take the nearest rank: the lowest price at or above `percentile` of the prices.
+	rank := int(math.Ceil(percentile / 100 * float64(len(prices))))
+	if rank > 0 {
+		rank--
+	}
+	return prices[rank], nil
+}
+
+type tipSetValidationReader interface {
+	GetTipSet(block.TipSetKey) (block.TipSet, error)
+	GetTipSetState(context.Context, block.TipSetKey) (state.Tree, error)
+}
+
+// blockValidator validates block headers and their messages.
+type blockValidator interface {
+	consensus.BlockSemanticValidator
+	consensus.SyntaxValidator
+}
+
+func validateTipSet(ctx context.Context, reader tipSetValidationReader, messages *chain.MessageStore, inclusions *chain.MessageInclusionIndex, validator blockValidator, processor consensus.Processor, vms vm.Storage, ts block.TipSet) error {
+	parentKey, err := ts.Parents()
+	if err != nil {
+		return err
+	}
+	parent, err := reader.GetTipSet(parentKey)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load parent tipset %s", parentKey)
+	}
+
+	for i := 0; i < ts.Len(); i++ {
+		blk := ts.At(i)
+		if err := validator.ValidateSyntax(ctx, blk); err != nil {
+			return errors.Wrapf(err, "invalid block %s", blk.Cid())
+		}
+		if err := validator.ValidateSemantic(ctx, blk, parent); err != nil {
+			return errors.Wrapf(err, "invalid block %s", blk.Cid())
+		}
+
+		secpMsgs, blsMsgs, err := messages.LoadMessages(ctx, blk.Messages.Cid)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load messages for block %s", blk.Cid())
+		}
+		if err := validator.ValidateMessagesSyntax(ctx, secpMsgs); err != nil {
+			return errors.Wrapf(err, "invalid secp messages in block %s", blk.Cid())
+		}
+		if err := validator.ValidateUnsignedMessagesSyntax(ctx, blsMsgs); err != nil {
+			return errors.Wrapf(err, "invalid bls messages in block %s", blk.Cid())
+		}
+		if err := consensus.VerifyMessageSignatures(ctx, secpMsgs, 0); err != nil {
+			return errors.Wrapf(err, "secp message signature invalid in block %s", blk.Cid())
+		}
+		if err := consensus.VerifyBLSMessageAggregate(blk.BLSAggregateSig.Data, blsMsgs); err != nil {
+			return errors.Wrapf(err, "bls message verification failed for block %s", blk.Cid())
+		}
+		mcids, err := chain.MessageCids(secpMsgs, blsMsgs)
+		if err != nil {
+			return err
+		}
+		if err := inclusions.CheckNotIncluded(parentKey, mcids); err != nil {
+			return errors.Wrapf(err, "invalid block %s", blk.Cid())
+		}
+	}
+
+	receipts, err := verifyStateRoot(ctx, reader, messages, processor, vms, parent, ts)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < ts.Len(); i++ {
+		if err := chain.ValidateReceiptRoot(ctx, ts.At(i), receipts, messages); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyStateRoot re-runs the messages of the parent of `ts` through the
+// processor on top of the grandparent state, and checks the result is the
+// state root claimed by every block of `ts`. A mismatch returns an error
+// wrapping consensus.ErrStateRootMismatch.
+func (c *ChainSubmodule) VerifyStateRoot(ctx context.Context, ts block.TipSet) error {
+	parentKey, err := ts.Parents()
+	if err != nil {
+		return err
+	}
+	parent, err := c.ChainReader.GetTipSet(parentKey)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load parent tipset %s", parentKey)
+	}
+	_, err = verifyStateRoot(ctx, c.ChainReader, c.MessageStore, c.Processor, vm.NewStorage(c.blockstore), parent, ts)
+	return err
+}
+
+// verifyStateRoot checks the state root claimed by the blocks of `ts`, like
+// VerifyStateRoot, returning the receipts of the parent's messages.
+func verifyStateRoot(ctx context.Context, reader tipSetValidationReader, messages chain.MessageProvider, processor consensus.Processor, vms vm.Storage, parent, ts block.TipSet) ([]vm.MessageReceipt, error) {
+	expectedRoot, receipts, err := computeTipSetStateRoot(ctx, reader, messages, processor, vms, parent)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compute state root of parent tipset %s", parent.Key())
+	}
+	for i := 0; i < ts.Len(); i++ {
+		blk := ts.At(i)
+		if !expectedRoot.Equals(blk.StateRoot.Cid) {
+			return nil, errors.Wrapf(consensus.ErrStateRootMismatch, "block %s", blk.Cid())
+		}
+	}
+	return receipts, nil
+}
+
+// computeTipSetStateRoot applies the messages of `ts` on top of the state of
+// its parent and returns the resulting state root and message receipts.
+func computeTipSetStateRoot(ctx context.Context, reader tipSetValidationReader, messages chain.MessageProvider, processor consensus.Processor, vms vm.Storage, ts block.TipSet) (cid.Cid, []vm.MessageReceipt, error) {
+	parentKey, err := ts.Parents()
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	// The genesis state is not the result of a state transition.
+	if parentKey.Empty() {
+		return ts.At(0).StateRoot.Cid, nil, nil
+	}
+
+	st, err := reader.GetTipSetState(ctx, parentKey)
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+
+	var msgs []vm.BlockMessagesInfo
+	for i := 0; i < ts.Len(); i++ {
+		blk := ts.At(i)
+		secpMsgs, blsMsgs, err := messages.LoadMessages(ctx, blk.Messages.Cid)
+		if err != nil {
+			return cid.Undef, nil, errors.Wrapf(err, "failed to load messages for block %s", blk.Cid())
+		}
+		msgs = append(msgs, vm.BlockMessagesInfo{
+			BLSMessages:  blsMsgs,
+			SECPMessages: secpMsgs,
+			Miner:        blk.Miner,
+		})
+	}
+
+	receipts, err := processor.ProcessTipSet(ctx, st, vms, ts, msgs)
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	if err := vms.Flush(); err != nil {
+		return cid.Undef, nil, err
+	}
+	root, err := st.Flush(ctx)
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	return root, receipts, nil
+}
@@ -0,0 +1,434 @@
+package submodule
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
+)
+
+func TestValidateTipSet(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	cst := cborutil.NewIpldStore(bs)
+	blockTime := clock.DefaultEpochDuration
+
+	signer, _ := types.NewMockSignersAndKeyInfo(2)
+	sender, recipient := signer.Addresses[0], signer.Addresses[1]
+	genesis, err := consensus.MakeGenesisFunc(consensus.ActorAccount(sender, abi.NewTokenAmount(1000000)))(cst, bs)
+	require.NoError(t, err)
+	genTS := th.RequireNewTipSet(t, genesis)
+
+	messages := chain.NewMessageStore(bs)
+	emptyMessages, err := messages.StoreMessages(ctx, []*types.SignedMessage{}, []*types.UnsignedMessage{})
+	require.NoError(t, err)
+	msg := types.NewMeteredMessage(sender, recipient, 0, abi.NewTokenAmount(100), builtin.MethodSend, nil, types.NewGasPrice(1), types.GasUnits(10000))
+	smsg, err := types.NewSignedMessage(*msg, &signer)
+	require.NoError(t, err)
+	sendMessages, err := messages.StoreMessages(ctx, []*types.SignedMessage{smsg}, []*types.UnsignedMessage{})
+	require.NoError(t, err)
+
+	minerAddr := vmaddr.RequireIDAddress(t, 100)
+	// The receipt roots of processed tipsets, by key. Others have no receipts.
+	receiptRoots := make(map[string]cid.Cid)
+	newBlock := func(parent block.TipSet, stateRoot, msgs cid.Cid) *block.Block {
+		height := parent.At(0).Height + 1
+		receiptRoot, ok := receiptRoots[parent.Key().String()]
+		if !ok {
+			receiptRoot = types.EmptyReceiptsCID
+		}
+		return &block.Block{
+			Miner:           minerAddr,
+			Ticket:          block.Ticket{VRFProof: []byte(stateRoot.String())},
+			Parents:         parent.Key(),
+			ParentWeight:    fbig.Zero(),
+			Height:          height,
+			StateRoot:       e.NewCid(stateRoot),
+			Messages:        e.NewCid(msgs),
+			MessageReceipts: e.NewCid(receiptRoot),
+			BLSAggregateSig: genesis.BLSAggregateSig,
+			Timestamp:       genesis.Timestamp + uint64(height)*uint64(blockTime.Seconds()),
+		}
+	}
+	newTipSet := func(parent block.TipSet, stateRoot, msgs cid.Cid) block.TipSet {
+		return th.RequireNewTipSet(t, newBlock(parent, stateRoot, msgs))
+	}
+	// link1 sends value, so the state its child claims differs from genesis.
+	link1 := newTipSet(genTS, genesis.StateRoot.Cid, sendMessages)
+
+	store := chain.NewStore(repo.NewInMemoryRepo().ChainDatastore(), cst, state.NewTreeLoader(), chain.NewStatusReporter(), genesis.Cid())
+	for _, ts := range []block.TipSet{genTS, link1} {
+		require.NoError(t, store.PutTipSetMetadata(ctx, &chain.TipSetMetadata{
+			TipSet:          ts,
+			TipSetStateRoot: ts.At(0).StateRoot.Cid,
+			TipSetReceipts:  types.EmptyReceiptsCID,
+		}))
+	}
+
+	processor := consensus.NewDefaultProcessor(&consensus.FakeSampler{})
+	// The state after applying link1's messages to the genesis state.
+	processed, err := state.NewTreeLoader().LoadStateTree(ctx, cst, genesis.StateRoot.Cid)
+	require.NoError(t, err)
+	vms := vm.NewStorage(bs)
+	receipts, err := processor.ProcessTipSet(ctx, processed, vms, link1, []vm.BlockMessagesInfo{{
+		SECPMessages: []*types.SignedMessage{smsg},
+		BLSMessages:  []*types.UnsignedMessage{},
+		Miner:        minerAddr,
+	}})
+	require.NoError(t, err)
+	require.NoError(t, vms.Flush())
+	processedRoot, err := processed.Flush(ctx)
+	require.NoError(t, err)
+	recipientActor, err := processed.GetActor(ctx, recipient)
+	require.NoError(t, err)
+	require.Equal(t, abi.NewTokenAmount(100), recipientActor.Balance)
+	receiptRoots[link1.Key().String()], err = messages.ComputeReceiptRoot(ctx, receipts)
+	require.NoError(t, err)
+
+	inclusions := chain.NewMessageInclusionIndex(store, messages, chain.DefaultInclusionWindow, chain.DefaultInclusionBatch)
+	store.SetMessageInclusionIndex(inclusions)
+	require.NoError(t, store.SetHead(ctx, link1))
+
+	chainClock := clock.NewChainClockFromClock(genesis.Timestamp, blockTime, th.NewFakeClock(time.Unix(int64(genesis.Timestamp), 0).Add(10*blockTime)))
+	validate := func(ts block.TipSet) error {
+		return validateTipSet(ctx, store, messages, inclusions, consensus.NewDefaultBlockValidator(chainClock), processor, vm.NewStorage(bs), ts)
+	}
+
+	t.Run("valid tipset passes", func(t *testing.T) {
+		assert.NoError(t, validate(newTipSet(link1, processedRoot, emptyMessages)))
+	})
+
+	t.Run("wrong state root fails", func(t *testing.T) {
+		for _, root := range []cid.Cid{genesis.StateRoot.Cid, types.CidFromString(t, "wrong state root")} {
+			err := validate(newTipSet(link1, root, emptyMessages))
+			require.Error(t, err)
+			assert.Equal(t, consensus.ErrStateRootMismatch, errors.Cause(err))
+		}
+	})
+
+	t.Run("wrong receipt root fails", func(t *testing.T) {
+		blk := newBlock(link1, processedRoot, emptyMessages)
+		blk.MessageReceipts = e.NewCid(types.EmptyReceiptsCID)
+		err := validate(th.RequireNewTipSet(t, blk))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "claims receipt root")
+	})
+
+	t.Run("invalid message signature fails", func(t *testing.T) {
+		forged := *smsg
+		forged.Message.Value = abi.NewTokenAmount(999)
+		forgedMessages, err := messages.StoreMessages(ctx, []*types.SignedMessage{&forged}, []*types.UnsignedMessage{})
+		require.NoError(t, err)
+		err = validate(newTipSet(link1, processedRoot, forgedMessages))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "secp message signature invalid")
+	})
+
+	t.Run("invalid bls aggregate fails", func(t *testing.T) {
+		blk := newBlock(link1, processedRoot, emptyMessages)
+		blk.BLSAggregateSig.Data = []byte{0x1}
+		err := validate(th.RequireNewTipSet(t, blk))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "bls message verification failed")
+	})
+
+	t.Run("re-included message fails", func(t *testing.T) {
+		// link1 already includes the send.
+		err := validate(newTipSet(link1, processedRoot, sendMessages))
+		require.Error(t, err)
+		assert.Equal(t, chain.ErrMessageReincluded, errors.Cause(err))
+	})
+
+	t.Run("block from the future fails", func(t *testing.T) {
+		blk := newBlock(link1, processedRoot, emptyMessages)
+		blk.Height += 20
+		blk.Timestamp += 20 * uint64(blockTime.Seconds())
+		assert.Error(t, validate(th.RequireNewTipSet(t, blk)))
+	})
+
+	t.Run("unknown parent fails", func(t *testing.T) {
+		unknown := newTipSet(link1, types.CidFromString(t, "unknown parent"), emptyMessages)
+		assert.Error(t, validate(newTipSet(unknown, processedRoot, emptyMessages)))
+	})
+}
+
+// actorSettingProcessor sets an actor in the state when processing a tipset.
+type actorSettingProcessor struct {
+	addr address.Address
+}
+
+func (p actorSettingProcessor) ProcessTipSet(ctx context.Context, st state.Tree, _ vm.Storage, _ block.TipSet, _ []vm.BlockMessagesInfo) ([]vm.MessageReceipt, error) {
+	return []vm.MessageReceipt{}, st.SetActor(ctx, p.addr, actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(1)))
+}
+
+func TestVerifyStateRoot(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	cst := cborutil.NewIpldStore(bs)
+	messages := chain.NewMessageStore(bs)
+	_, err := messages.StoreMessages(ctx, []*types.SignedMessage{}, []*types.UnsignedMessage{})
+	require.NoError(t, err)
+	processor := actorSettingProcessor{addr: vmaddr.RequireIDAddress(t, 100)}
+
+	genRoot, err := state.NewTree(cst).Flush(ctx)
+	require.NoError(t, err)
+	// The state after processing any tipset on top of the genesis state.
+	processed := state.NewTree(cst)
+	_, err = processor.ProcessTipSet(ctx, processed, nil, block.UndefTipSet, nil)
+	require.NoError(t, err)
+	processedRoot, err := processed.Flush(ctx)
+	require.NoError(t, err)
+
+	newTipSet := func(parent block.TipSet, stateRoot cid.Cid) block.TipSet {
+		blk := &block.Block{
+			Ticket:          block.Ticket{VRFProof: []byte(stateRoot.String())},
+			ParentWeight:    fbig.Zero(),
+			StateRoot:       e.NewCid(stateRoot),
+			Messages:        e.NewCid(types.EmptyTxMetaCID),
+			MessageReceipts: e.NewCid(types.EmptyReceiptsCID),
+		}
+		if parent.Defined() {
+			blk.Parents = parent.Key()
+			blk.Height = parent.At(0).Height + 1
+		}
+		return th.RequireNewTipSet(t, blk)
+	}
+	gen := newTipSet(block.UndefTipSet, genRoot)
+	link1 := newTipSet(gen, genRoot)
+
+	store := chain.NewStore(repo.NewInMemoryRepo().ChainDatastore(), cst, state.NewTreeLoader(), chain.NewStatusReporter(), gen.At(0).Cid())
+	for _, ts := range []block.TipSet{gen, link1} {
+		require.NoError(t, store.PutTipSetMetadata(ctx, &chain.TipSetMetadata{
+			TipSet:          ts,
+			TipSetStateRoot: ts.At(0).StateRoot.Cid,
+			TipSetReceipts:  types.EmptyReceiptsCID,
+		}))
+	}
+
+	verify := func(ts block.TipSet) error {
+		_, err := verifyStateRoot(ctx, store, messages, processor, vm.NewStorage(bs), link1, ts)
+		return err
+	}
+
+	t.Run("processed state root passes", func(t *testing.T) {
+		assert.NoError(t, verify(newTipSet(link1, processedRoot)))
+	})
+
+	t.Run("tampered state root fails", func(t *testing.T) {
+		for _, root := range []cid.Cid{genRoot, types.CidFromString(t, "tampered state root")} {
+			err := verify(newTipSet(link1, root))
+			require.Error(t, err)
+			assert.Equal(t, consensus.ErrStateRootMismatch, errors.Cause(err))
+		}
+	})
+}
+
+// failingTipSets fails to load any tipset.
+type failingTipSets struct{}
+
+func (failingTipSets) GetTipSet(block.TipSetKey) (block.TipSet, error) {
+	return block.UndefTipSet, errors.New("datastore unavailable")
+}
+
+func TestIsValidExtension(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	gen := builder.NewGenesis()
+	link1 := builder.AppendOn(gen, 2)
+	cst := cborutil.NewIpldStore(blockstore.NewBlockstore(datastore.NewMapDatastore()))
+	store := chain.NewStore(repo.NewInMemoryRepo().ChainDatastore(), cst, state.NewTreeLoader(), chain.NewStatusReporter(), gen.At(0).Cid())
+	for _, ts := range []block.TipSet{gen, link1} {
+		require.NoError(t, store.PutTipSetMetadata(ctx, &chain.TipSetMetadata{
+			TipSet:          ts,
+			TipSetStateRoot: ts.At(0).StateRoot.Cid,
+			TipSetReceipts:  types.EmptyReceiptsCID,
+		}))
+	}
+	withHeight := func(parent block.TipSet, height abi.ChainEpoch) block.TipSet {
+		return th.RequireNewTipSet(t, &block.Block{
+			Parents:      parent.Key(),
+			ParentWeight: fbig.Zero(),
+			Height:       height,
+		})
+	}
+
+	t.Run("child of known tipset valid", func(t *testing.T) {
+		valid, err := isValidExtension(store, builder.AppendOn(link1, 1))
+		require.NoError(t, err)
+		assert.True(t, valid)
+	})
+
+	t.Run("null rounds after parent valid", func(t *testing.T) {
+		valid, err := isValidExtension(store, withHeight(link1, link1.At(0).Height+3))
+		require.NoError(t, err)
+		assert.True(t, valid)
+	})
+
+	t.Run("unknown parent invalid", func(t *testing.T) {
+		other := chain.NewBuilder(t, address.Undef)
+		unknown := other.AppendOn(other.NewGenesis(), 1)
+		valid, err := isValidExtension(store, other.AppendOn(unknown, 1))
+		require.NoError(t, err)
+		assert.False(t, valid)
+	})
+
+	t.Run("height not above parent invalid", func(t *testing.T) {
+		valid, err := isValidExtension(store, withHeight(link1, link1.At(0).Height))
+		require.NoError(t, err)
+		assert.False(t, valid)
+	})
+
+	t.Run("parent load failure errors", func(t *testing.T) {
+		_, err := isValidExtension(failingTipSets{}, builder.AppendOn(link1, 1))
+		assert.Error(t, err)
+	})
+}
+
+func TestEstimateGasPrice(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signer, _ := types.NewMockSignersAndKeyInfo(2)
+	from, to := signer.Addresses[0], signer.Addresses[1]
+	nonce := uint64(0)
+	withPrices := func(prices ...int64) []*types.SignedMessage {
+		var msgs []*types.SignedMessage
+		for _, price := range prices {
+			msg := types.NewMeteredMessage(from, to, nonce, types.ZeroAttoFIL, builtin.MethodSend, nil, types.NewGasPrice(price), types.GasUnits(1))
+			smsg, err := types.NewSignedMessage(*msg, signer)
+			require.NoError(t, err)
+			msgs = append(msgs, smsg)
+			nonce++
+		}
+		return msgs
+	}
+
+	builder := chain.NewBuilder(t, address.Undef)
+	including := func(parent block.TipSet, msgs []*types.SignedMessage) block.TipSet {
+		return builder.BuildOneOn(parent, func(b *chain.BlockBuilder) {
+			b.AddMessages(msgs, []*types.UnsignedMessage{})
+		})
+	}
+	// Heights 1 to 3, the last with no messages.
+	old := including(builder.NewGenesis(), withPrices(100, 200))
+	recent := including(old, withPrices(4, 1, 3, 2))
+	head := including(recent, nil)
+
+	floor := types.NewGasPrice(1)
+	estimate := func(percentile float64, lookback abi.ChainEpoch) types.AttoFIL {
+		price, err := estimateGasPrice(ctx, builder, builder, head, percentile, lookback, floor)
+		require.NoError(t, err)
+		return price
+	}
+
+	t.Run("percentiles of recent prices", func(t *testing.T) {
+		assert.Equal(t, types.NewGasPrice(1), estimate(0, 2))
+		assert.Equal(t, types.NewGasPrice(1), estimate(25, 2))
+		assert.Equal(t, types.NewGasPrice(2), estimate(50, 2))
+		assert.Equal(t, types.NewGasPrice(3), estimate(60, 2))
+		assert.Equal(t, types.NewGasPrice(4), estimate(100, 2))
+	})
+
+	t.Run("longer lookback includes older prices", func(t *testing.T) {
+		assert.Equal(t, types.NewGasPrice(200), estimate(100, 3))
+		assert.Equal(t, types.NewGasPrice(3), estimate(50, 3))
+	})
+
+	t.Run("empty window returns floor", func(t *testing.T) {
+		assert.Equal(t, floor, estimate(50, 1))
+		assert.Equal(t, floor, estimate(50, 0))
+	})
+
+	t.Run("message included by several blocks counted once", func(t *testing.T) {
+		shared, others := withPrices(10), withPrices(20, 30)
+		wide := builder.Build(head, 2, func(b *chain.BlockBuilder, i int) {
+			if i == 0 {
+				b.AddMessages(shared, []*types.UnsignedMessage{})
+			} else {
+				b.AddMessages(append(shared, others...), []*types.UnsignedMessage{})
+			}
+		})
+		price, err := estimateGasPrice(ctx, builder, builder, wide, 50, 1, floor)
+		require.NoError(t, err)
+		assert.Equal(t, types.NewGasPrice(20), price)
+	})
+
+	t.Run("percentile out of range", func(t *testing.T) {
+		_, err := estimateGasPrice(ctx, builder, builder, head, 101, 2, floor)
+		assert.Error(t, err)
+	})
+}
+
+// chainTestConfig configures a chain submodule with a genesis block and block time.
+type chainTestConfig struct {
+	genesis   cid.Cid
+	blockTime time.Duration
+}
+
+func (c chainTestConfig) GenesisCid() cid.Cid {
+	return c.genesis
+}
+
+func (c chainTestConfig) BlockTime() time.Duration {
+	return c.blockTime
+}
+
+func TestChainSubmoduleBlockTime(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	blockstoreSubmodule := &BlockstoreSubmodule{Blockstore: bs, CborStore: cborutil.NewIpldStore(bs)}
+	genesis := &block.Block{Timestamp: 1000, ParentWeight: fbig.Zero()}
+	genesisCid, err := blockstoreSubmodule.CborStore.Put(ctx, genesis)
+	require.NoError(t, err)
+	genesisTime := time.Unix(1000, 0)
+
+	t.Run("custom block time used by epoch clock", func(t *testing.T) {
+		chn, err := NewChainSubmodule(chainTestConfig{genesis: genesisCid, blockTime: 3 * time.Second}, repo.NewInMemoryRepo(), blockstoreSubmodule)
+		require.NoError(t, err)
+		assert.Equal(t, 3*time.Second, chn.BlockTime)
+
+		epochClock, err := chn.NewEpochClock(ctx, th.NewFakeClock(genesisTime))
+		require.NoError(t, err)
+		assert.Equal(t, genesisTime.Add(12*time.Second), epochClock.StartTimeOfEpoch(4))
+		assert.Equal(t, abi.ChainEpoch(4), epochClock.EpochAtTime(genesisTime.Add(14*time.Second)))
+	})
+
+	t.Run("zero block time defaults", func(t *testing.T) {
+		chn, err := NewChainSubmodule(chainTestConfig{genesis: genesisCid}, repo.NewInMemoryRepo(), blockstoreSubmodule)
+		require.NoError(t, err)
+		assert.Equal(t, clock.DefaultEpochDuration, chn.BlockTime)
+	})
+}
@@ -3,12 +3,15 @@ package submodule
 import (
 	"context"
 
+	ds "github.com/ipfs/go-datastore"
+
 	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/net"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/net/pubsub"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 )
 
 // MessagingSubmodule enhances the `Node` with internal messaging capabilities.
@@ -24,6 +27,9 @@ type MessagingSubmodule struct {
 	MessageSub   pubsub.Subscription
 
 	MsgPool *message.Pool
+
+	// datastore holds the pool's pending messages while the node is stopped.
+	datastore repo.Datastore
 }
 
 type messagingConfig interface {
@@ -32,6 +38,7 @@ type messagingConfig interface {
 
 type messagingRepo interface {
 	Config() *config.Config
+	Datastore() ds.Batching
 }
 
 // NewMessagingSubmodule creates a new discovery submodule.
@@ -55,6 +62,18 @@ func NewMessagingSubmodule(ctx context.Context, config messagingConfig, repo mes
 		Outbox:       outbox,
 		MessageTopic: pubsub.NewTopic(topic),
 		// MessageSub: nil,
-		MsgPool: msgPool,
+		MsgPool:   msgPool,
+		datastore: repo.Datastore(),
 	}, nil
 }
+
+// Start restores the pending messages the pool persisted when the node last
+// stopped. It must be called once the chain state can validate them.
+func (m *MessagingSubmodule) Start(ctx context.Context) error {
+	return m.MsgPool.Restore(ctx, m.datastore)
+}
+
+// Stop persists the pool's pending messages for Start to restore.
+func (m *MessagingSubmodule) Stop(ctx context.Context) error {
+	return m.MsgPool.Persist(m.datastore)
+}
@@ -0,0 +1,43 @@
+package submodule
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+func TestMessagingSubmoduleRestart(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	r := repo.NewInMemoryRepo()
+	newMessaging := func() *MessagingSubmodule {
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		return &MessagingSubmodule{MsgPool: pool, datastore: r.Datastore()}
+	}
+
+	signer, _ := types.NewMockSignersAndKeyInfo(1)
+	msg := types.NewSignedMessageForTestGetter(signer)()
+
+	before := newMessaging()
+	require.NoError(t, before.Start(ctx))
+	c, err := before.MsgPool.Add(ctx, msg, 3)
+	require.NoError(t, err)
+	require.NoError(t, before.Stop(ctx))
+
+	after := newMessaging()
+	require.NoError(t, after.Start(ctx))
+	restored, ok := after.MsgPool.Get(c)
+	require.True(t, ok)
+	assert.Equal(t, msg, restored)
+	assert.Equal(t, []*types.SignedMessage{msg}, after.MsgPool.Pending())
+}
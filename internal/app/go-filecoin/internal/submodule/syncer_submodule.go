@@ -2,7 +2,6 @@ package submodule
 
 import (
 	"context"
-	"time"
 
 	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/ipfs/go-cid"
@@ -37,7 +36,6 @@ type SyncerSubmodule struct {
 
 type syncerConfig interface {
 	GenesisCid() cid.Cid
-	BlockTime() time.Duration
 	ChainClock() clock.ChainEpochClock
 }
 
@@ -66,7 +64,9 @@ func NewSyncerSubmodule(ctx context.Context, config syncerConfig, repo chainRepo
 
 	// set up consensus
 	stateViewer := consensus.AsPowerStateViewer(state.NewViewer(blockstore.CborStore))
-	nodeConsensus := consensus.NewExpected(blockstore.CborStore, blockstore.Blockstore, chn.Processor, &stateViewer, config.BlockTime(), consensus.ElectionMachine{}, consensus.TicketMachine{}, postVerifier)
+	params := consensus.DefaultParams()
+	params.BlockTime = chn.BlockTime
+	nodeConsensus := consensus.NewExpected(blockstore.CborStore, blockstore.Blockstore, chn.Processor, &stateViewer, params, consensus.ElectionMachine{VRFInputs: params.VRFInputs}, consensus.TicketMachine{VRFInputs: params.VRFInputs}, postVerifier)
 	nodeChainSelector := consensus.NewChainSelector(blockstore.CborStore, &stateViewer, config.GenesisCid())
 
 	// setup fecher
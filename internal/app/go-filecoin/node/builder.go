@@ -175,12 +175,10 @@ func (b *Builder) build(ctx context.Context) (*Node, error) {
 	}
 
 	if b.chainClock == nil {
-		// get the genesis block time from the chainsubmodule
-		geneBlk, err := nd.chain.ChainReader.GetGenesisBlock(ctx)
+		b.chainClock, err = nd.chain.NewEpochClock(ctx, clock.NewSystemClock())
 		if err != nil {
 			return nil, err
 		}
-		b.chainClock = clock.NewChainClock(geneBlk.Timestamp, b.blockTime)
 	}
 	nd.ChainClock = b.chainClock
 
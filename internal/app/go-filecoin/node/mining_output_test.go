@@ -0,0 +1,45 @@
+package node
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/mining"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestHandleNewMiningOutputLocalOnly(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	forwarded := make(chan *block.Block, 2)
+	nd := &Node{}
+	nd.BlockMining.MiningDoneWg = &sync.WaitGroup{}
+	nd.BlockMining.AddNewlyMinedBlock = func(_ context.Context, blk *block.Block) {
+		forwarded <- blk
+	}
+	nd.setIsMining(true)
+
+	outCh := make(chan mining.Output)
+	nd.BlockMining.MiningDoneWg.Add(1)
+	go nd.handleNewMiningOutput(ctx, outCh)
+
+	local := &block.Block{Height: 1, ParentWeight: fbig.Zero()}
+	broadcast := &block.Block{Height: 2, ParentWeight: fbig.Zero()}
+	outCh <- mining.Output{NewBlock: local, LocalOnly: true}
+	outCh <- mining.Output{NewBlock: broadcast}
+
+	// Outputs are handled in order, so once the broadcast block is forwarded
+	// the local only block has been handled too.
+	assert.Equal(t, broadcast, <-forwarded)
+	cancel()
+	nd.BlockMining.MiningDoneWg.Wait()
+	assert.Empty(t, forwarded)
+}
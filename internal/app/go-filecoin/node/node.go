@@ -11,9 +11,7 @@ import (
 	"github.com/filecoin-project/go-sectorbuilder"
 	"github.com/filecoin-project/go-sectorbuilder/fs"
 	"github.com/filecoin-project/specs-actors/actors/abi"
-	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
 	bserv "github.com/ipfs/go-blockservice"
-	"github.com/ipfs/go-cid"
 	ds "github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/namespace"
 	logging "github.com/ipfs/go-log"
@@ -114,6 +112,10 @@ func (node *Node) Start(ctx context.Context) error {
 		return err
 	}
 
+	if err := node.Messaging.Start(ctx); err != nil {
+		return errors.Wrap(err, "failed to restore message pool")
+	}
+
 	// Only set these up if there is a miner configured.
 	if _, err := node.MiningAddress(); err == nil {
 		if err := node.setupStorageMining(ctx); err != nil {
@@ -243,6 +245,8 @@ func (node *Node) handleNewMiningOutput(ctx context.Context, miningOutCh <-chan
 			if output.Err != nil {
 				log.Errorf("stopping mining. error: %s", output.Err.Error())
 				node.StopMining(context.Background())
+			} else if output.LocalOnly {
+				log.Debugf("not broadcasting locally mined block %s", output.NewBlock.Cid())
 			} else {
 				node.BlockMining.MiningDoneWg.Add(1)
 				go func() {
@@ -313,6 +317,10 @@ func (node *Node) Stop(ctx context.Context) {
 		node.StorageMining = nil
 	}
 
+	if err := node.Messaging.Stop(ctx); err != nil {
+		fmt.Printf("error persisting message pool: %s\n", err)
+	}
+
 	if err := node.Host().Close(); err != nil {
 		fmt.Printf("error closing host: %s\n", err)
 	}
@@ -615,7 +623,7 @@ func (node *Node) GetMiningWorker(ctx context.Context) (*mining.DefaultWorker, e
 }
 
 // CreateMiningWorker creates a mining.Worker for the node using the configured
-// getStateTree, getWeight, and getAncestors functions for the node
+// getStateTree, weight, and getAncestors functions for the node
 func (node *Node) CreateMiningWorker(ctx context.Context) (*mining.DefaultWorker, error) {
 	minerAddr, err := node.MiningAddress()
 	if err != nil {
@@ -629,6 +637,8 @@ func (node *Node) CreateMiningWorker(ctx context.Context) (*mining.DefaultWorker
 		return nil, err
 	}
 
+	params := consensus.DefaultParams()
+	stateViewer := consensus.AsPowerStateViewer(state.NewViewer(node.Blockstore.CborStore))
 	return mining.NewDefaultWorker(mining.WorkerParameters{
 		API: node.PorcelainAPI,
 
@@ -637,38 +647,22 @@ func (node *Node) CreateMiningWorker(ctx context.Context) (*mining.DefaultWorker
 		WorkerSigner:   node.Wallet.Wallet,
 
 		GetStateTree:   node.chain.ChainReader.GetTipSetState,
-		GetWeight:      node.getWeight,
+		GetWeight:      mining.NewChainWeightCalculator(&stateViewer, node.chain.ChainReader),
 		GetAncestors:   node.getAncestors,
-		Election:       consensus.ElectionMachine{},
-		TicketGen:      consensus.TicketMachine{},
+		Election:       consensus.ElectionMachine{VRFInputs: params.VRFInputs},
+		TicketGen:      consensus.TicketMachine{VRFInputs: params.VRFInputs},
 		TipSetMetadata: node.chain.ChainReader,
 
-		MessageSource: node.Messaging.Inbox.Pool(),
-		MessageStore:  node.chain.MessageStore,
-		Processor:     node.Chain().Processor,
-		Blockstore:    node.Blockstore.Blockstore,
-		Clock:         node.ChainClock,
-		Poster:        node.StorageMining.PoStGenerator,
+		MessageSource:   node.Messaging.Inbox.Pool(),
+		MessageStore:    node.chain.MessageStore,
+		MessageProvider: node.chain.MessageStore,
+		Processor:       node.Chain().Processor,
+		Blockstore:      node.Blockstore.Blockstore,
+		Clock:           node.ChainClock,
+		Poster:          node.StorageMining.PoStGenerator,
 	}), nil
 }
 
-// getWeight is the default GetWeight function for the mining worker.
-func (node *Node) getWeight(ctx context.Context, ts block.TipSet) (fbig.Int, error) {
-	parent, err := ts.Parents()
-	if err != nil {
-		return fbig.Zero(), err
-	}
-	// TODO handle genesis cid more gracefully
-	if parent.Len() == 0 {
-		return node.syncer.ChainSelector.Weight(ctx, ts, cid.Undef)
-	}
-	root, err := node.chain.ChainReader.GetTipSetStateRoot(parent)
-	if err != nil {
-		return fbig.Zero(), err
-	}
-	return node.syncer.ChainSelector.Weight(ctx, ts, root)
-}
-
 // getAncestors is the default GetAncestors function for the mining worker.
 func (node *Node) getAncestors(ctx context.Context, ts block.TipSet, newBlockHeight abi.ChainEpoch) ([]block.TipSet, error) {
 	ancestorHeight := newBlockHeight - consensus.AncestorRoundsNeeded
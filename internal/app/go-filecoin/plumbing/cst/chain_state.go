@@ -2,12 +2,14 @@ package cst
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/account"
 	initactor "github.com/filecoin-project/specs-actors/actors/builtin/init"
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-blockservice"
@@ -191,6 +193,40 @@ func (chn *ChainStateReadWriter) GetActorAt(ctx context.Context, tipKey block.Ti
 	return actr, nil
 }
 
+// GetBalances returns the balances of the actors at `addrs` in the state at
+// `key`, which is loaded once for all of them. Results and errors are
+// positional: the i-th balance and error correspond to the i-th address, so a
+// single missing actor does not fail the whole batch.
+func (chn *ChainStateReadWriter) GetBalances(ctx context.Context, key block.TipSetKey, addrs []address.Address) ([]types.AttoFIL, []error) {
+	balances := make([]types.AttoFIL, len(addrs))
+	errs := make([]error, len(addrs))
+	st, err := chn.readWriter.GetTipSetState(ctx, key)
+	if err != nil {
+		err = errors.Wrap(err, "failed to load state")
+		for i := range addrs {
+			balances[i], errs[i] = types.ZeroAttoFIL, err
+		}
+		return balances, errs
+	}
+
+	for i, addr := range addrs {
+		balances[i] = types.ZeroAttoFIL
+		idAddr := addr
+		if addr.Protocol() != address.ID {
+			if idAddr, errs[i] = chn.resolveAddress(ctx, st, addr); errs[i] != nil {
+				continue
+			}
+		}
+		actr, err := st.GetActor(ctx, idAddr)
+		if err != nil {
+			errs[i] = errors.Wrapf(err, "no actor at address %s", addr)
+			continue
+		}
+		balances[i] = actr.Balance
+	}
+	return balances, errs
+}
+
 // GetActorStateAt returns the root state of an actor at a given point in the chain (specified by tipset key)
 func (chn *ChainStateReadWriter) GetActorStateAt(ctx context.Context, tipKey block.TipSetKey, addr address.Address, out interface{}) error {
 	act, err := chn.GetActorAt(ctx, tipKey, addr)
@@ -206,13 +242,40 @@ func (chn *ChainStateReadWriter) GetActorStateAt(ctx context.Context, tipKey blo
 	return encoding.Decode(blk.RawData(), out)
 }
 
+// DumpActorState returns the JSON encoding of the head state of the actor at
+// `addr` in the state at `key`, decoded according to the actor's code.
+func (chn *ChainStateReadWriter) DumpActorState(ctx context.Context, key block.TipSetKey, addr address.Address) ([]byte, error) {
+	act, err := chn.GetActorAt(ctx, key, addr)
+	if err != nil {
+		return nil, err
+	}
+	newState, ok := vm.DefaultActorStates[act.Code.Cid]
+	if !ok {
+		return nil, errors.Errorf("cannot decode state of actor %s with unknown code %s", addr, act.Code.Cid)
+	}
+
+	blk, err := chn.bstore.Get(act.Head.Cid)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load state of actor %s", addr)
+	}
+	st := newState()
+	if err := encoding.Decode(blk.RawData(), st); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode state of actor %s", addr)
+	}
+	return json.Marshal(st)
+}
+
 // ResolveAddressAt resolves ID address for actor
 func (chn *ChainStateReadWriter) ResolveAddressAt(ctx context.Context, tipKey block.TipSetKey, addr address.Address) (address.Address, error) {
 	st, err := chn.readWriter.GetTipSetState(ctx, tipKey)
 	if err != nil {
 		return address.Undef, errors.Wrap(err, "failed to load latest state")
 	}
+	return chn.resolveAddress(ctx, st, addr)
+}
 
+// resolveAddress resolves `addr` to an ID address with the init actor in `st`.
+func (chn *ChainStateReadWriter) resolveAddress(ctx context.Context, st state.Tree, addr address.Address) (address.Address, error) {
 	init, err := st.GetActor(ctx, builtin.InitActorAddr)
 	if err != nil {
 		return address.Undef, err
@@ -232,6 +295,35 @@ func (chn *ChainStateReadWriter) ResolveAddressAt(ctx context.Context, tipKey bl
 	return state.ResolveAddress(&actorStore{ctx, chn.ReadOnlyIpldStore}, addr)
 }
 
+// ResolveAddress returns both the ID address and the public key address of
+// the account actor at `addr`, which may be either, in the state at `tipKey`.
+func (chn *ChainStateReadWriter) ResolveAddress(ctx context.Context, tipKey block.TipSetKey, addr address.Address) (idAddr, keyAddr address.Address, err error) {
+	if addr.Protocol() != address.ID {
+		idAddr, err = chn.ResolveAddressAt(ctx, tipKey, addr)
+		if err != nil {
+			return address.Undef, address.Undef, err
+		}
+		return idAddr, addr, nil
+	}
+
+	act, err := chn.GetActorAt(ctx, tipKey, addr)
+	if err != nil {
+		return address.Undef, address.Undef, err
+	}
+	if !act.Code.Cid.Equals(builtin.AccountActorCodeID) {
+		return address.Undef, address.Undef, errors.Errorf("actor at %s is not an account actor", addr)
+	}
+	blk, err := chn.bstore.Get(act.Head.Cid)
+	if err != nil {
+		return address.Undef, address.Undef, err
+	}
+	var accountState account.State
+	if err := encoding.Decode(blk.RawData(), &accountState); err != nil {
+		return address.Undef, address.Undef, err
+	}
+	return addr, accountState.Address, nil
+}
+
 // LsActors returns a channel with actors from the latest state on the chain
 func (chn *ChainStateReadWriter) LsActors(ctx context.Context) (<-chan state.GetAllActorsResult, error) {
 	st, err := chn.readWriter.GetTipSetState(ctx, chn.readWriter.GetHead())
@@ -241,6 +333,35 @@ func (chn *ChainStateReadWriter) LsActors(ctx context.Context) (<-chan state.Get
 	return st.GetAllActors(ctx), nil
 }
 
+// ActorEntry is an actor in the state tree together with its address.
+type ActorEntry struct {
+	Address address.Address
+	Actor   *actor.Actor
+}
+
+// ListActors returns every actor in the state tree at the tipset identified by `key`.
+func (chn *ChainStateReadWriter) ListActors(ctx context.Context, key block.TipSetKey) ([]ActorEntry, error) {
+	var entries []ActorEntry
+	err := chn.ForEachActorAt(ctx, key, func(addr address.Address, act *actor.Actor) error {
+		entries = append(entries, ActorEntry{Address: addr, Actor: act})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ForEachActorAt calls `visit` with each actor in the state tree at the tipset identified by `key`,
+// without loading them all into memory. Iteration stops at the first error returned by `visit`.
+func (chn *ChainStateReadWriter) ForEachActorAt(ctx context.Context, key block.TipSetKey, visit state.ActorWalkFn) error {
+	st, err := chn.readWriter.GetTipSetState(ctx, key)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load state for %s", key)
+	}
+	return st.ForEachActor(ctx, visit)
+}
+
 // GetActorSignature returns the signature of the given actor's given method.
 // The function signature is typically used to enable a caller to decode the
 // output of an actor method call (message).
@@ -0,0 +1,319 @@
+package cst_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/account"
+	initactor "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/actors/util/adt"
+	"github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cst"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	appstate "github.com/filecoin-project/go-filecoin/internal/pkg/state"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
+)
+
+// fakeChainState serves tipsets from a builder and a fixed state tree per tipset.
+type fakeChainState struct {
+	*chain.Builder
+	head   block.TipSetKey
+	states map[string]state.Tree
+	store  cborutil.ReadOnlyIpldStore
+}
+
+func (f *fakeChainState) GetHead() block.TipSetKey {
+	return f.head
+}
+
+func (f *fakeChainState) GetTipSetState(_ context.Context, key block.TipSetKey) (state.Tree, error) {
+	st, ok := f.states[key.String()]
+	if !ok {
+		return nil, errors.Errorf("no state for %s", key)
+	}
+	return st, nil
+}
+
+func (f *fakeChainState) SetHead(_ context.Context, ts block.TipSet) error {
+	f.head = ts.Key()
+	return nil
+}
+
+func (f *fakeChainState) ReadOnlyStateStore() cborutil.ReadOnlyIpldStore {
+	return f.store
+}
+
+func TestListActors(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	ipldStore := cborutil.NewIpldStore(bs)
+
+	builder := chain.NewBuilder(t, address.Undef)
+	head := builder.AppendOn(builder.NewGenesis(), 1)
+
+	addrGetter := vmaddr.NewForTestGetter()
+	expected := map[address.Address]abi.TokenAmount{
+		addrGetter(): abi.NewTokenAmount(1),
+		addrGetter(): abi.NewTokenAmount(2),
+		addrGetter(): abi.NewTokenAmount(3),
+	}
+	tree := state.NewTree(ipldStore)
+	for addr, balance := range expected {
+		require.NoError(t, tree.SetActor(ctx, addr, actor.NewActor(builtin.AccountActorCodeID, balance)))
+	}
+	_, err := tree.Flush(ctx)
+	require.NoError(t, err)
+
+	chainState := &fakeChainState{
+		Builder: builder,
+		head:    head.Key(),
+		states:  map[string]state.Tree{head.Key().String(): tree},
+		store:   cborutil.ReadOnlyIpldStore{IpldStore: ipldStore},
+	}
+	reader := cst.NewChainStateReadWriter(chainState, builder, bs, nil)
+
+	t.Run("lists every actor", func(t *testing.T) {
+		entries, err := reader.ListActors(ctx, head.Key())
+		require.NoError(t, err)
+		require.Len(t, entries, len(expected))
+		for _, entry := range entries {
+			balance, ok := expected[entry.Address]
+			require.True(t, ok, "unexpected actor %s", entry.Address)
+			assert.True(t, balance.Equals(entry.Actor.Balance))
+		}
+	})
+
+	t.Run("visitor errors stop iteration", func(t *testing.T) {
+		visited := 0
+		err := reader.ForEachActorAt(ctx, head.Key(), func(address.Address, *actor.Actor) error {
+			visited++
+			return errors.New("stop")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, visited)
+	})
+
+	t.Run("unknown tipset errors", func(t *testing.T) {
+		_, err := reader.ListActors(ctx, builder.AppendOn(head, 1).Key())
+		assert.Error(t, err)
+	})
+}
+
+func TestResolveAddress(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	ipldStore := cborutil.NewIpldStore(bs)
+	store := appstate.StoreFromCbor(ctx, ipldStore)
+
+	builder := chain.NewBuilder(t, address.Undef)
+	head := builder.AppendOn(builder.NewGenesis(), 1)
+
+	// Register an account with the init actor.
+	keyAddr := vmaddr.NewForTestGetter()()
+	emptyMap, err := adt.MakeEmptyMap(store)
+	require.NoError(t, err)
+	initState := initactor.ConstructState(emptyMap.Root(), "test")
+	idAddr, err := initState.MapAddressToNewID(store, keyAddr)
+	require.NoError(t, err)
+	initHead, err := ipldStore.Put(ctx, initState)
+	require.NoError(t, err)
+	accountHead, err := ipldStore.Put(ctx, &account.State{Address: keyAddr})
+	require.NoError(t, err)
+	emptyArray, err := adt.MakeEmptyArray(store)
+	require.NoError(t, err)
+	emptySet, err := market.MakeEmptySetMultimap(store)
+	require.NoError(t, err)
+	marketState := market.ConstructState(emptyArray.Root(), emptyMap.Root(), emptySet.Root())
+	marketHead, err := ipldStore.Put(ctx, marketState)
+	require.NoError(t, err)
+
+	tree := state.NewTree(ipldStore)
+	initActor := actor.NewActor(builtin.InitActorCodeID, abi.NewTokenAmount(0))
+	initActor.Head = e.NewCid(initHead)
+	require.NoError(t, tree.SetActor(ctx, builtin.InitActorAddr, initActor))
+	accountActor := actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(1))
+	accountActor.Head = e.NewCid(accountHead)
+	require.NoError(t, tree.SetActor(ctx, idAddr, accountActor))
+	_, err = tree.Flush(ctx)
+	require.NoError(t, err)
+
+	chainState := &fakeChainState{
+		Builder: builder,
+		head:    head.Key(),
+		states:  map[string]state.Tree{head.Key().String(): tree},
+		store:   cborutil.ReadOnlyIpldStore{IpldStore: ipldStore},
+	}
+	reader := cst.NewChainStateReadWriter(chainState, builder, bs, nil)
+
+	t.Run("key address resolves to ID", func(t *testing.T) {
+		gotID, gotKey, err := reader.ResolveAddress(ctx, head.Key(), keyAddr)
+		require.NoError(t, err)
+		assert.Equal(t, idAddr, gotID)
+		assert.Equal(t, keyAddr, gotKey)
+	})
+
+	t.Run("ID address resolves to key", func(t *testing.T) {
+		gotID, gotKey, err := reader.ResolveAddress(ctx, head.Key(), idAddr)
+		require.NoError(t, err)
+		assert.Equal(t, idAddr, gotID)
+		assert.Equal(t, keyAddr, gotKey)
+	})
+
+	t.Run("non-account actor errors", func(t *testing.T) {
+		_, _, err := reader.ResolveAddress(ctx, head.Key(), builtin.InitActorAddr)
+		assert.EqualError(t, err, fmt.Sprintf("actor at %s is not an account actor", builtin.InitActorAddr))
+	})
+}
+
+func TestDumpActorState(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	ipldStore := cborutil.NewIpldStore(bs)
+	store := appstate.StoreFromCbor(ctx, ipldStore)
+
+	builder := chain.NewBuilder(t, address.Undef)
+	head := builder.AppendOn(builder.NewGenesis(), 1)
+
+	keyAddr := vmaddr.NewForTestGetter()()
+	emptyMap, err := adt.MakeEmptyMap(store)
+	require.NoError(t, err)
+	initState := initactor.ConstructState(emptyMap.Root(), "test")
+	idAddr, err := initState.MapAddressToNewID(store, keyAddr)
+	require.NoError(t, err)
+	initHead, err := ipldStore.Put(ctx, initState)
+	require.NoError(t, err)
+	accountHead, err := ipldStore.Put(ctx, &account.State{Address: keyAddr})
+	require.NoError(t, err)
+
+	tree := state.NewTree(ipldStore)
+	initActor := actor.NewActor(builtin.InitActorCodeID, abi.NewTokenAmount(0))
+	initActor.Head = e.NewCid(initHead)
+	require.NoError(t, tree.SetActor(ctx, builtin.InitActorAddr, initActor))
+	accountActor := actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(1))
+	accountActor.Head = e.NewCid(accountHead)
+	require.NoError(t, tree.SetActor(ctx, idAddr, accountActor))
+	marketActor := actor.NewActor(builtin.StorageMarketActorCodeID, abi.NewTokenAmount(0))
+	marketActor.Head = e.NewCid(marketHead)
+	require.NoError(t, tree.SetActor(ctx, builtin.StorageMarketActorAddr, marketActor))
+	unknownAddr := vmaddr.RequireIDAddress(t, 1000)
+	unknownCode := types.CidFromString(t, "unknown")
+	require.NoError(t, tree.SetActor(ctx, unknownAddr, actor.NewActor(unknownCode, abi.NewTokenAmount(0))))
+	_, err = tree.Flush(ctx)
+	require.NoError(t, err)
+
+	chainState := &fakeChainState{
+		Builder: builder,
+		head:    head.Key(),
+		states:  map[string]state.Tree{head.Key().String(): tree},
+		store:   cborutil.ReadOnlyIpldStore{IpldStore: ipldStore},
+	}
+	reader := cst.NewChainStateReadWriter(chainState, builder, bs, nil)
+
+	t.Run("dumps builtin actor state", func(t *testing.T) {
+		out, err := reader.DumpActorState(ctx, head.Key(), idAddr)
+		require.NoError(t, err)
+		var dumped account.State
+		require.NoError(t, json.Unmarshal(out, &dumped))
+		assert.Equal(t, keyAddr, dumped.Address)
+	})
+
+	t.Run("dumps storage market actor state", func(t *testing.T) {
+		out, err := reader.DumpActorState(ctx, head.Key(), builtin.StorageMarketActorAddr)
+		require.NoError(t, err)
+		expected, err := json.Marshal(marketState)
+		require.NoError(t, err)
+		assert.JSONEq(t, string(expected), string(out))
+	})
+
+	t.Run("unknown actor code errors", func(t *testing.T) {
+		_, err := reader.DumpActorState(ctx, head.Key(), unknownAddr)
+		assert.EqualError(t, err, fmt.Sprintf("cannot decode state of actor %s with unknown code %s", unknownAddr, unknownCode))
+	})
+}
+
+func TestGetBalances(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	ipldStore := cborutil.NewIpldStore(bs)
+	store := appstate.StoreFromCbor(ctx, ipldStore)
+
+	builder := chain.NewBuilder(t, address.Undef)
+	head := builder.AppendOn(builder.NewGenesis(), 1)
+
+	addrGetter := vmaddr.NewForTestGetter()
+	keyAddr, unknownKeyAddr := addrGetter(), addrGetter()
+	emptyMap, err := adt.MakeEmptyMap(store)
+	require.NoError(t, err)
+	initState := initactor.ConstructState(emptyMap.Root(), "test")
+	idAddr, err := initState.MapAddressToNewID(store, keyAddr)
+	require.NoError(t, err)
+	initHead, err := ipldStore.Put(ctx, initState)
+	require.NoError(t, err)
+
+	tree := state.NewTree(ipldStore)
+	initActor := actor.NewActor(builtin.InitActorCodeID, abi.NewTokenAmount(0))
+	initActor.Head = e.NewCid(initHead)
+	require.NoError(t, tree.SetActor(ctx, builtin.InitActorAddr, initActor))
+	require.NoError(t, tree.SetActor(ctx, idAddr, actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(7))))
+	require.NoError(t, tree.SetActor(ctx, builtin.RewardActorAddr, actor.NewActor(builtin.RewardActorCodeID, abi.NewTokenAmount(42))))
+	_, err = tree.Flush(ctx)
+	require.NoError(t, err)
+
+	chainState := &fakeChainState{
+		Builder: builder,
+		head:    head.Key(),
+		states:  map[string]state.Tree{head.Key().String(): tree},
+		store:   cborutil.ReadOnlyIpldStore{IpldStore: ipldStore},
+	}
+	reader := cst.NewChainStateReadWriter(chainState, builder, bs, nil)
+	missingID := vmaddr.RequireIDAddress(t, 999)
+
+	t.Run("positional results", func(t *testing.T) {
+		balances, errs := reader.GetBalances(ctx, head.Key(), []address.Address{keyAddr, missingID, builtin.RewardActorAddr, unknownKeyAddr, idAddr})
+		require.Len(t, balances, 5)
+		require.Len(t, errs, 5)
+
+		assert.NoError(t, errs[0])
+		assert.Equal(t, abi.NewTokenAmount(7), balances[0])
+		assert.Error(t, errs[1])
+		assert.Equal(t, abi.NewTokenAmount(0), balances[1])
+		assert.NoError(t, errs[2])
+		assert.Equal(t, abi.NewTokenAmount(42), balances[2])
+		assert.Error(t, errs[3])
+		assert.NoError(t, errs[4])
+		assert.Equal(t, abi.NewTokenAmount(7), balances[4])
+	})
+
+	t.Run("missing state fails every address", func(t *testing.T) {
+		_, errs := reader.GetBalances(ctx, builder.AppendOn(head, 1).Key(), []address.Address{idAddr, builtin.RewardActorAddr})
+		require.Len(t, errs, 2)
+		assert.Error(t, errs[0])
+		assert.Error(t, errs[1])
+	})
+}
@@ -11,11 +11,14 @@ import (
 	cid "github.com/ipfs/go-cid"
 	cbor "github.com/ipfs/go-ipld-cbor"
 	node "github.com/ipfs/go-ipld-format"
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/constants"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
 	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 )
 
 // Block is a block in the blockchain.
@@ -65,11 +68,19 @@ type Block struct {
 	// ForkSignaling is extra data used by miners to communicate
 	ForkSignaling uint64
 
+	// Extension is opaque data reserved for future soft-fork signaling.
+	// It is empty for blocks which don't use it and may be at most
+	// MaxExtensionLength bytes long.
+	Extension []byte `json:"extension"`
+
 	cachedCid cid.Cid
 
 	cachedBytes []byte
 }
 
+// MaxExtensionLength is the maximum length in bytes of a block's Extension.
+const MaxExtensionLength = 64
+
 // IndexMessagesField is the message field position in the encoded block
 const IndexMessagesField = 8
 
@@ -129,6 +140,21 @@ func (b *Block) String() string {
 	return fmt.Sprintf("Block cid=[%v]: %s", cid, string(js))
 }
 
+// MarshalLogObject implements zapcore.ObjectMarshaler so that structured
+// loggers record a block's identifying fields rather than its full encoding.
+func (b *Block) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("cid", b.Cid().String())
+	enc.AddString("miner", b.Miner.String())
+	enc.AddInt64("height", int64(b.Height))
+	enc.AddInt("parents", b.Parents.Len())
+	return nil
+}
+
+// IsGenesis returns true iff the block is at height zero and has no parents.
+func (b *Block) IsGenesis() bool {
+	return b.Height == 0 && b.Parents.Empty()
+}
+
 // DecodeBlock decodes raw cbor bytes into a Block.
 func DecodeBlock(b []byte) (*Block, error) {
 	var out Block
@@ -162,8 +188,20 @@ func (b *Block) SignatureData() []byte {
 		Timestamp:       b.Timestamp,
 		BLSAggregateSig: b.BLSAggregateSig,
 		ForkSignaling:   b.ForkSignaling,
+		Extension:       b.Extension,
 		// BlockSig omitted
 	}
 
 	return tmp.ToNode().RawData()
 }
+
+// SignBlock signs the block's signature data with the key of `worker` and sets
+// the result as the block's signature.
+func SignBlock(signer types.Signer, b *Block, worker address.Address) error {
+	sig, err := signer.SignBytes(b.SignatureData(), worker)
+	if err != nil {
+		return errors.Wrap(err, "failed to sign block")
+	}
+	b.BlockSig = sig
+	return nil
+}
@@ -10,6 +10,9 @@ import (
 	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 
 	blk "github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
@@ -63,6 +66,7 @@ func TestTriangleEncoding(t *testing.T) {
 		candidate1 := blk.NewEPoStCandidate(5, []byte{0x05}, 52)
 		candidate2 := blk.NewEPoStCandidate(3, []byte{0x04}, 3000)
 		postInfo := blk.NewEPoStInfo([]byte{0x07}, []byte{0x02, 0x06}, candidate1, candidate2)
+		postInfo.SealProofType = otherSealProofType
 		b := &blk.Block{
 			Miner:           newAddress(),
 			Ticket:          blk.Ticket{VRFProof: []byte{0x01, 0x02, 0x03}},
@@ -83,6 +87,7 @@ func TestTriangleEncoding(t *testing.T) {
 			},
 			EPoStInfo:     postInfo,
 			ForkSignaling: 6,
+			Extension:     []byte{0x01, 0x02},
 		}
 		s := reflect.TypeOf(*b)
 		// This check is here to request that you add a non-zero value for new fields
@@ -90,7 +95,10 @@ func TestTriangleEncoding(t *testing.T) {
 		// Also please add non zero fields to "b" and "diff" in TestSignatureData
 		// and add a new check that different values of the new field result in
 		// different output data.
-		require.Equal(t, 16, s.NumField()) // Note: this also counts private fields
+		require.Equal(t, 17, s.NumField()) // Note: this also counts private fields
+		// Likewise for the fields of the election PoSt info, which existing
+		// blocks encode as an array.
+		require.Equal(t, 5, reflect.TypeOf(b.EPoStInfo).NumField())
 		testRoundTrip(t, b)
 	})
 }
@@ -230,6 +238,7 @@ func TestSignatureData(t *testing.T) {
 		Parents:         blk.NewTipSetKey(types.CidFromString(t, "somecid")),
 		ParentWeight:    fbig.NewInt(1000),
 		ForkSignaling:   3,
+		Extension:       []byte{0x0a},
 		StateRoot:       e.NewCid(types.CidFromString(t, "somecid")),
 		Timestamp:       1,
 		EPoStInfo:       postInfo,
@@ -242,6 +251,7 @@ func TestSignatureData(t *testing.T) {
 	diffCandidate1 := blk.NewEPoStCandidate(0, []byte{0x04}, 25)
 	diffCandidate2 := blk.NewEPoStCandidate(1, []byte{0x05}, 3001)
 	diffPoStInfo := blk.NewEPoStInfo([]byte{0x17}, []byte{0x12, 0x16}, diffCandidate1, diffCandidate2)
+	diffPoStInfo.SealProofType = otherSealProofType
 
 	diff := &blk.Block{
 		Miner:           newAddress(),
@@ -252,6 +262,7 @@ func TestSignatureData(t *testing.T) {
 		Parents:         blk.NewTipSetKey(types.CidFromString(t, "someothercid")),
 		ParentWeight:    fbig.NewInt(1001),
 		ForkSignaling:   2,
+		Extension:       []byte{0x0b, 0x0c},
 		StateRoot:       e.NewCid(types.CidFromString(t, "someothercid")),
 		Timestamp:       4,
 		EPoStInfo:       diffPoStInfo,
@@ -364,6 +375,17 @@ func TestSignatureData(t *testing.T) {
 		assert.False(t, bytes.Equal(before, after))
 	}()
 
+	func() {
+		before := b.SignatureData()
+
+		cpy := b.Extension
+		defer func() { b.Extension = cpy }()
+
+		b.Extension = diff.Extension
+		after := b.SignatureData()
+		assert.False(t, bytes.Equal(before, after))
+	}()
+
 	func() {
 		before := b.SignatureData()
 
@@ -458,4 +480,168 @@ func TestSignatureData(t *testing.T) {
 		assert.False(t, bytes.Equal(before, after))
 	}()
 
+	func() {
+		before := b.SignatureData()
+		cpy := b.EPoStInfo.SealProofType
+		defer func() { b.EPoStInfo.SealProofType = cpy }()
+
+		b.EPoStInfo.SealProofType = diff.EPoStInfo.SealProofType
+		after := b.SignatureData()
+
+		assert.False(t, bytes.Equal(before, after))
+	}()
+
+}
+
+// otherSealProofType is a seal proof type other than the default, which is
+// all the encoding tests need of it.
+const otherSealProofType = blk.DefaultSealProofType + 1
+
+// EPoStInfo for the default seal proof type keeps the encoding it had before
+// the proof type was added, while other types are carried through.
+func TestEPoStInfoEncoding(t *testing.T) {
+	tf.UnitTest(t)
+
+	// legacyEPoStInfo is the layout of EPoStInfo before the proof type.
+	type legacyEPoStInfo struct {
+		_              struct{} `cbor:",toarray"`
+		PoStProof      []byte
+		PoStRandomness blk.VRFPi
+		Winners        []blk.EPoStCandidate
+	}
+	winner := blk.NewEPoStCandidate(5, []byte{0x05}, 52)
+	legacy, err := encoding.Encode(legacyEPoStInfo{PoStProof: []byte{0x07}, PoStRandomness: []byte{0x02}, Winners: []blk.EPoStCandidate{winner}})
+	require.NoError(t, err)
+
+	t.Run("default type encodes as before", func(t *testing.T) {
+		info := blk.NewEPoStInfo([]byte{0x07}, []byte{0x02}, winner)
+		encoded, err := encoding.Encode(info)
+		require.NoError(t, err)
+		assert.Equal(t, legacy, encoded)
+
+		info.SealProofType = blk.DefaultSealProofType
+		encoded, err = encoding.Encode(info)
+		require.NoError(t, err)
+		assert.Equal(t, legacy, encoded)
+	})
+
+	t.Run("legacy encoding decodes to default type", func(t *testing.T) {
+		var info blk.EPoStInfo
+		require.NoError(t, encoding.Decode(legacy, &info))
+		assert.Equal(t, blk.NewEPoStInfo([]byte{0x07}, []byte{0x02}, winner), info)
+		assert.Equal(t, blk.DefaultSealProofType, info.SealProof())
+	})
+
+	t.Run("other type round trips", func(t *testing.T) {
+		info := blk.NewEPoStInfo([]byte{0x07}, []byte{0x02}, winner)
+		info.SealProofType = otherSealProofType
+		encoded, err := encoding.Encode(info)
+		require.NoError(t, err)
+		assert.NotEqual(t, legacy, encoded)
+
+		var decoded blk.EPoStInfo
+		require.NoError(t, encoding.Decode(encoded, &decoded))
+		assert.Equal(t, info, decoded)
+		assert.Equal(t, otherSealProofType, decoded.SealProof())
+	})
+}
+
+func TestSignBlock(t *testing.T) {
+	tf.UnitTest(t)
+
+	signer, _ := types.NewMockSignersAndKeyInfo(2)
+	worker := signer.Addresses[0]
+
+	b := &blk.Block{
+		Miner:           vmaddr.NewForTestGetter()(),
+		Ticket:          blk.Ticket{VRFProof: []byte{0x01, 0x02, 0x03}},
+		Height:          2,
+		Messages:        e.NewCid(types.CidFromString(t, "somecid")),
+		MessageReceipts: e.NewCid(types.CidFromString(t, "somecid")),
+		Parents:         blk.NewTipSetKey(types.CidFromString(t, "somecid")),
+		ParentWeight:    fbig.NewInt(1000),
+		StateRoot:       e.NewCid(types.CidFromString(t, "somecid")),
+		Timestamp:       1,
+	}
+
+	require.NoError(t, blk.SignBlock(signer, b, worker))
+	assert.True(t, crypto.IsValidSignature(b.SignatureData(), worker, b.BlockSig))
+	assert.False(t, crypto.IsValidSignature(b.SignatureData(), signer.Addresses[1], b.BlockSig))
+
+	// signing with an address unknown to the signer fails and leaves the block unsigned
+	unsigned := &blk.Block{Height: 3, ParentWeight: fbig.Zero()}
+	assert.Error(t, blk.SignBlock(signer, unsigned, vmaddr.NewForTestGetter()()))
+	assert.Empty(t, unsigned.BlockSig.Data)
+}
+
+func TestBlockMarshalLogObject(t *testing.T) {
+	tf.UnitTest(t)
+
+	miner := vmaddr.NewForTestGetter()()
+	b := &blk.Block{
+		Miner:        miner,
+		Height:       7,
+		Parents:      blk.NewTipSetKey(types.CidFromString(t, "parent1"), types.CidFromString(t, "parent2")),
+		ParentWeight: fbig.Zero(),
+	}
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	zap.New(core).Info("mined", zap.Object("block", b))
+
+	entries := logs.All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, map[string]interface{}{
+		"cid":     b.Cid().String(),
+		"miner":   miner.String(),
+		"height":  int64(7),
+		"parents": 2,
+	}, entries[0].ContextMap()["block"])
+}
+
+func TestMakeGenesisBlock(t *testing.T) {
+	tf.UnitTest(t)
+
+	miner := vmaddr.NewForTestGetter()()
+	stateRoot := types.CidFromString(t, "state root")
+
+	gen := blk.MakeGenesisBlock(miner, stateRoot, 1234)
+	require.NoError(t, gen.ValidateGenesis())
+	assert.Equal(t, abi.ChainEpoch(0), gen.Height)
+	assert.True(t, gen.Parents.Empty())
+	assert.Equal(t, stateRoot, gen.StateRoot.Cid)
+
+	t.Run("cid is stable for fixed inputs", func(t *testing.T) {
+		again := blk.MakeGenesisBlock(miner, stateRoot, 1234)
+		assert.Equal(t, gen.Cid(), again.Cid())
+
+		later := blk.MakeGenesisBlock(miner, stateRoot, 1235)
+		assert.NotEqual(t, gen.Cid(), later.Cid())
+	})
+
+	t.Run("non-genesis blocks are rejected", func(t *testing.T) {
+		withHeight := blk.MakeGenesisBlock(miner, stateRoot, 1234)
+		withHeight.Height = 1
+		assert.Error(t, withHeight.ValidateGenesis())
+
+		withParents := blk.MakeGenesisBlock(miner, stateRoot, 1234)
+		withParents.Parents = blk.NewTipSetKey(types.CidFromString(t, "parent"))
+		assert.Error(t, withParents.ValidateGenesis())
+
+		signed := blk.MakeGenesisBlock(miner, stateRoot, 1234)
+		signed.BlockSig = crypto.Signature{Type: crypto.SigTypeSecp256k1, Data: []byte{1}}
+		assert.Error(t, signed.ValidateGenesis())
+	})
+}
+
+func TestIsGenesis(t *testing.T) {
+	tf.UnitTest(t)
+
+	genesis := &blk.Block{Height: 0}
+	assert.True(t, genesis.IsGenesis())
+
+	withParents := &blk.Block{Height: 0, Parents: blk.NewTipSetKey(types.CidFromString(t, "parent"))}
+	assert.False(t, withParents.IsGenesis())
+
+	normal := &blk.Block{Height: 5, Parents: blk.NewTipSetKey(types.CidFromString(t, "parent"))}
+	assert.False(t, normal.IsGenesis())
 }
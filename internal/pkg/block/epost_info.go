@@ -3,14 +3,78 @@ package block
 import (
 	ffi "github.com/filecoin-project/filecoin-ffi"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
 )
 
+// DefaultSealProofType is the seal proof type of the sectors proven by
+// blocks that do not set another.
+const DefaultSealProofType = abi.RegisteredProof_StackedDRG32GiBSeal
+
 // EPoStInfo wraps all data needed to verify an election post proof
 type EPoStInfo struct {
 	_              struct{} `cbor:",toarray"`
 	PoStProof      []byte
 	PoStRandomness VRFPi
 	Winners        []EPoStCandidate
+	// SealProofType is the seal proof type of the proven sectors. The zero
+	// value stands for DefaultSealProofType, see SealProof.
+	SealProofType abi.RegisteredProof
+}
+
+// legacyEPoStInfo is the encoding of an EPoStInfo for the
+// DefaultSealProofType, which leaves the proof type out so that blocks
+// encoded before it was added keep their cids.
+type legacyEPoStInfo struct {
+	_              struct{} `cbor:",toarray"`
+	PoStProof      []byte
+	PoStRandomness VRFPi
+	Winners        []EPoStCandidate
+}
+
+// typedEPoStInfo is the encoding of an EPoStInfo for any other seal proof
+// type, trailing the legacy fields.
+type typedEPoStInfo struct {
+	_              struct{} `cbor:",toarray"`
+	PoStProof      []byte
+	PoStRandomness VRFPi
+	Winners        []EPoStCandidate
+	SealProofType  abi.RegisteredProof
+}
+
+// SealProof returns the seal proof type of the proven sectors.
+func (x EPoStInfo) SealProof() abi.RegisteredProof {
+	if x.SealProofType == 0 {
+		return DefaultSealProofType
+	}
+	return x.SealProofType
+}
+
+// MarshalCBOR encodes the EPoStInfo, trailing the seal proof type only if it
+// is not the DefaultSealProofType.
+func (x EPoStInfo) MarshalCBOR() ([]byte, error) {
+	if x.SealProof() == DefaultSealProofType {
+		return encoding.Encode(legacyEPoStInfo{PoStProof: x.PoStProof, PoStRandomness: x.PoStRandomness, Winners: x.Winners})
+	}
+	return encoding.Encode(typedEPoStInfo{PoStProof: x.PoStProof, PoStRandomness: x.PoStRandomness, Winners: x.Winners, SealProofType: x.SealProofType})
+}
+
+// UnmarshalCBOR decodes an EPoStInfo with or without a trailing seal proof
+// type, leaving it zero when absent.
+func (x *EPoStInfo) UnmarshalCBOR(raw []byte) error {
+	var typed typedEPoStInfo
+	if err := encoding.Decode(raw, &typed); err == nil {
+		*x = EPoStInfo{PoStProof: typed.PoStProof, PoStRandomness: typed.PoStRandomness, Winners: typed.Winners, SealProofType: typed.SealProofType}
+		return nil
+	}
+
+	var legacy legacyEPoStInfo
+	if err := encoding.Decode(raw, &legacy); err != nil {
+		return errors.Wrap(err, "could not decode epost info")
+	}
+	*x = EPoStInfo{PoStProof: legacy.PoStProof, PoStRandomness: legacy.PoStRandomness, Winners: legacy.Winners}
+	return nil
 }
 
 // EPoStCandidate wraps the input data needed to verify an election PoSt
@@ -30,7 +94,8 @@ func NewEPoStCandidate(sID uint64, pt []byte, sci uint64) EPoStCandidate {
 	}
 }
 
-// NewEPoStInfo constructs an epost info from data
+// NewEPoStInfo constructs an epost info from data, for sectors sealed with
+// the DefaultSealProofType.
 func NewEPoStInfo(proof []byte, rand VRFPi, winners ...EPoStCandidate) EPoStInfo {
 	return EPoStInfo{
 		Winners:        winners,
@@ -0,0 +1,53 @@
+package block
+
+import (
+	"github.com/filecoin-project/go-address"
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	cid "github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// MakeGenesisBlock returns the canonical genesis block mined by `miner` over
+// the state tree rooted at `stateRoot`. The block is at height zero, has no
+// parents, references the empty message and receipt collections and carries
+// no signatures, so it is fully determined by its inputs.
+func MakeGenesisBlock(miner address.Address, stateRoot cid.Cid, timestamp uint64) *Block {
+	return &Block{
+		Miner:           miner,
+		Parents:         NewTipSetKey(),
+		ParentWeight:    fbig.Zero(),
+		Height:          0,
+		StateRoot:       e.NewCid(stateRoot),
+		Messages:        e.NewCid(types.EmptyTxMetaCID),
+		MessageReceipts: e.NewCid(types.EmptyReceiptsCID),
+		Timestamp:       timestamp,
+	}
+}
+
+// ValidateGenesis returns an error if the block cannot be a genesis block:
+// it must be at height zero, have no parents and no parent weight, reference
+// a state root and carry no signatures.
+func (b *Block) ValidateGenesis() error {
+	if b.Height != 0 {
+		return errors.Errorf("genesis block has height %d", b.Height)
+	}
+	if !b.Parents.Empty() {
+		return errors.Errorf("genesis block has parents %s", b.Parents)
+	}
+	if b.ParentWeight.Int != nil && b.ParentWeight.Sign() != 0 {
+		return errors.Errorf("genesis block has parent weight %s", b.ParentWeight)
+	}
+	if !b.StateRoot.Defined() {
+		return errors.New("genesis block has no state root")
+	}
+	if len(b.BlockSig.Data) != 0 {
+		return errors.New("genesis block is signed")
+	}
+	if len(b.BLSAggregateSig.Data) != 0 {
+		return errors.New("genesis block has a BLS aggregate signature")
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package block
 
 import (
 	"bytes"
+	"fmt"
 	"sort"
 
 	"github.com/filecoin-project/specs-actors/actors/abi"
@@ -31,21 +32,41 @@ var (
 	errUndefTipSet = errors.New("undefined tipset")
 )
 
+// DuplicateBlockError is returned from the tipset constructor when the same
+// block is given more than once.
+type DuplicateBlockError struct {
+	Cid cid.Cid
+}
+
+func (e *DuplicateBlockError) Error() string {
+	return fmt.Sprintf("duplicate block %s in tipset", e.Cid)
+}
+
+// MaxTipSetSize is the largest number of blocks accepted in a tipset. It
+// bounds the resources an attacker can make a node spend on a single tipset,
+// and is set well above the expected number of winners in an epoch.
+var MaxTipSetSize = 256
+
 // UndefTipSet is a singleton representing a nil or undefined tipset.
 var UndefTipSet = TipSet{}
 
 // NewTipSet builds a new TipSet from a collection of blocks.
-// The blocks must be distinct (different CIDs), have the same height, and same parent set.
+// The blocks must be distinct (different CIDs), have the same height, and same parent set,
+// and there may be at most MaxTipSetSize of them.
 func NewTipSet(blocks ...*Block) (TipSet, error) {
 	if len(blocks) == 0 {
 		return UndefTipSet, errNoBlocks
 	}
+	if len(blocks) > MaxTipSetSize {
+		return UndefTipSet, errors.Errorf("tipset has %d blocks, max is %d", len(blocks), MaxTipSetSize)
+	}
 
 	first := blocks[0]
 	height := first.Height
 	parents := first.Parents
 	weight := first.ParentWeight
 	cids := make([]cid.Cid, len(blocks))
+	seen := make(map[cid.Cid]struct{}, len(blocks))
 
 	sorted := make([]*Block, len(blocks))
 	for i, blk := range blocks {
@@ -60,8 +81,13 @@ func NewTipSet(blocks ...*Block) (TipSet, error) {
 				return UndefTipSet, errors.Errorf("Inconsistent block parent weights %d and %d", weight, blk.ParentWeight)
 			}
 		}
+		c := blk.Cid()
+		if _, ok := seen[c]; ok {
+			return UndefTipSet, &DuplicateBlockError{Cid: c}
+		}
+		seen[c] = struct{}{}
 		sorted[i] = blk
-		cids[i] = blk.Cid()
+		cids[i] = c
 	}
 
 	// Sort blocks by ticket
@@ -73,12 +99,7 @@ func NewTipSet(blocks ...*Block) (TipSet, error) {
 		}
 		return cmp < 0
 	})
-	// Duplicate blocks (CIDs) are rejected here, pass that error through.
-	key, err := NewTipSetKeyFromUnique(cids...)
-	if err != nil {
-		return UndefTipSet, err
-	}
-	return TipSet{sorted, key}, nil
+	return TipSet{sorted, NewTipSetKey(cids...)}, nil
 }
 
 // Defined checks whether the tipset is defined.
@@ -2,6 +2,7 @@ package block_test
 
 import (
 	"bytes"
+	"fmt"
 	"testing"
 
 	"github.com/filecoin-project/specs-actors/actors/abi"
@@ -166,7 +167,8 @@ func TestTipSet(t *testing.T) {
 	t.Run("duplicate block fails new tipset", func(t *testing.T) {
 		b1, b2, b3 = makeTestBlocks(t)
 		ts, err := blk.NewTipSet(b1, b2, b1)
-		assert.Error(t, err)
+		require.Error(t, err)
+		assert.Equal(t, &blk.DuplicateBlockError{Cid: b1.Cid()}, err)
 		assert.False(t, ts.Defined())
 	})
 
@@ -217,12 +219,59 @@ func TestTipSetMinTicket(t *testing.T) {
 	assert.Equal(t, ts.At(2), b3)
 }
 
+// TestTipSetMaxSize checks that tipsets of up to MaxTipSetSize blocks can be
+// built and larger ones are rejected.
+func TestTipSetMaxSize(t *testing.T) {
+	tf.UnitTest(t)
+
+	blocks := make([]*blk.Block, blk.MaxTipSetSize+1)
+	for i := range blocks {
+		blocks[i] = block(t, []byte{byte(i), byte(i >> 8)}, 1, cid1, parentWeight, uint64(i), "")
+	}
+
+	ts, err := blk.NewTipSet(blocks[:blk.MaxTipSetSize]...)
+	require.NoError(t, err)
+	assert.Equal(t, blk.MaxTipSetSize, ts.Len())
+
+	_, err = blk.NewTipSet(blocks...)
+	assert.EqualError(t, err, fmt.Sprintf("tipset has %d blocks, max is %d", blk.MaxTipSetSize+1, blk.MaxTipSetSize))
+}
+
 func TestUndefKey(t *testing.T) {
 	ts := blk.UndefTipSet
 	udKey := ts.Key()
 	assert.True(t, udKey.Empty())
 }
 
+// BenchmarkTipSetKey compares reading the key cached at construction with
+// recomputing it from the block CIDs.
+func BenchmarkTipSetKey(b *testing.B) {
+	blocks := make([]*blk.Block, 5)
+	for i := range blocks {
+		blocks[i] = &blk.Block{Ticket: blk.Ticket{VRFProof: []byte{byte(i)}}, ParentWeight: fbig.Zero(), Timestamp: uint64(i)}
+	}
+	ts, err := blk.NewTipSet(blocks...)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = ts.Key()
+		}
+	})
+
+	b.Run("recomputed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cids := make([]cid.Cid, ts.Len())
+			for j := range cids {
+				cids[j] = ts.At(j).Cid()
+			}
+			_ = blk.NewTipSetKey(cids...)
+		}
+	})
+}
+
 // Test methods: String, Key, ToSlice, MinTicket, Height, NewTipSet, Equals
 func makeTestBlocks(t *testing.T) (*blk.Block, *blk.Block, *blk.Block) {
 	b1 := block(t, []byte{1}, 1, cid1, parentWeight, 1, "1")
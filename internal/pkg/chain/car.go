@@ -129,7 +129,7 @@ func Export(ctx context.Context, headTS block.TipSet, cr carChainReader, mr carM
 				filter[hdr.MessageReceipts.Cid] = true
 			}
 
-			if hdr.Height == 0 {
+			if hdr.IsGenesis() {
 				logCar.Debugf("writing state tree: %s", hdr.StateRoot)
 				stateRoots, err := sr.ChainStateTree(ctx, hdr.StateRoot.Cid)
 				if err != nil {
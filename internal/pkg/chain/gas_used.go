@@ -0,0 +1,37 @@
+package chain
+
+import (
+	"context"
+
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+)
+
+// TipSetGasUsed returns the total gas used by the messages of `ts`, summed
+// from `receipts`. A message included by more than one block of the tipset
+// is applied, and so has a receipt, only once; the receipts must number the
+// distinct messages of the tipset.
+func TipSetGasUsed(ctx context.Context, messages MessageProvider, ts block.TipSet, receipts []vm.MessageReceipt) (gas.Unit, error) {
+	mcids, err := TipSetMessageCids(ctx, messages, ts)
+	if err != nil {
+		return gas.Zero, err
+	}
+	distinct := make(map[cid.Cid]struct{}, len(mcids))
+	for _, c := range mcids {
+		distinct[c] = struct{}{}
+	}
+	if len(receipts) != len(distinct) {
+		return gas.Zero, errors.Errorf("tipset %s has %d distinct messages but %d receipts", ts.Key(), len(distinct), len(receipts))
+	}
+
+	total := big.Zero()
+	for _, r := range receipts {
+		total = big.Add(total, r.GasUsed.AsBigInt())
+	}
+	return gas.Unit(total), nil
+}
@@ -0,0 +1,54 @@
+package chain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+)
+
+func TestTipSetGasUsed(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	mm := vm.NewMessageMaker(t, types.MustGenerateKeyInfo(1, 42))
+	alice := mm.Addresses()[0]
+	shared, m1, m2 := mm.NewSignedMessage(alice, 0), mm.NewSignedMessage(alice, 1), mm.NewSignedMessage(alice, 2)
+
+	// Two blocks both including `shared`.
+	ts := builder.Build(builder.NewGenesis(), 2, func(b *chain.BlockBuilder, i int) {
+		own := []*types.SignedMessage{m1, m2}[i]
+		b.AddMessages([]*types.SignedMessage{shared, own}, []*types.UnsignedMessage{})
+	})
+	receipt := func(used int64) vm.MessageReceipt {
+		return vm.MessageReceipt{GasUsed: gas.NewGas(used)}
+	}
+
+	t.Run("sums receipts of distinct messages", func(t *testing.T) {
+		used, err := chain.TipSetGasUsed(ctx, builder, ts, []vm.MessageReceipt{receipt(10), receipt(20), receipt(30)})
+		require.NoError(t, err)
+		assert.True(t, gas.NewGas(60).AsBigInt().Equals(used.AsBigInt()))
+	})
+
+	t.Run("receipt count must match", func(t *testing.T) {
+		_, err := chain.TipSetGasUsed(ctx, builder, ts, []vm.MessageReceipt{receipt(10), receipt(20), receipt(30), receipt(40)})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "3 distinct messages but 4 receipts")
+	})
+
+	t.Run("empty tipset uses no gas", func(t *testing.T) {
+		empty := builder.AppendOn(ts, 1)
+		used, err := chain.TipSetGasUsed(ctx, builder, empty, nil)
+		require.NoError(t, err)
+		assert.True(t, gas.Zero.AsBigInt().Equals(used.AsBigInt()))
+	})
+}
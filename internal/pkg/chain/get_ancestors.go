@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
 	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 
@@ -15,6 +16,9 @@ import (
 // ErrNoCommonAncestor is returned when two chains assumed to have a common ancestor do not.
 var ErrNoCommonAncestor = errors.New("no common ancestor")
 
+// ErrMissingAncestor is returned when an ancestor block can not be loaded during traversal.
+var ErrMissingAncestor = errors.New("missing ancestor block")
+
 // GetRecentAncestors returns the ancestors of base as a slice of TipSets down to and including the
 // first non-empty tipset with height <= `minHeight` (or the genesis tipset if minHeight is negative).
 //
@@ -30,6 +34,46 @@ func GetRecentAncestors(ctx context.Context, base block.TipSet, provider TipSetP
 	return CollectTipSetsPastHeight(iterator, minHeight)
 }
 
+// GetAncestorsChecked returns `ts` and its ancestors down to and including the first tipset
+// with height <= `toEpoch` (or the genesis tipset). If the traversal reaches a block that can not
+// be loaded it returns the ancestors collected so far along with the CID of the missing block
+// and an error wrapping ErrMissingAncestor, so the caller can fetch exactly what's needed.
+func GetAncestorsChecked(ctx context.Context, reader BlockProvider, ts block.TipSet, toEpoch abi.ChainEpoch) ([]block.TipSet, cid.Cid, error) {
+	ancestors := []block.TipSet{ts}
+	for cur := ts; ; {
+		if err := ctx.Err(); err != nil {
+			return ancestors, cid.Undef, err
+		}
+
+		h, err := cur.Height()
+		if err != nil {
+			return ancestors, cid.Undef, err
+		}
+		parentKey, err := cur.Parents()
+		if err != nil {
+			return ancestors, cid.Undef, err
+		}
+		// Parents is empty for the genesis tipset.
+		if h <= toEpoch || parentKey.Empty() {
+			return ancestors, cid.Undef, nil
+		}
+
+		var blocks []*block.Block
+		for it := parentKey.Iter(); !it.Complete(); it.Next() {
+			blk, err := reader.GetBlock(ctx, it.Value())
+			if err != nil {
+				return ancestors, it.Value(), errors.Wrapf(ErrMissingAncestor, "failed to load block %s: %s", it.Value(), err)
+			}
+			blocks = append(blocks, blk)
+		}
+		cur, err = block.NewTipSet(blocks...)
+		if err != nil {
+			return ancestors, cid.Undef, err
+		}
+		ancestors = append(ancestors, cur)
+	}
+}
+
 // CollectTipSetsPastHeight collects all tipsets down to the first tipset with a height less than
 // or equal to the earliest possible proving period start
 func CollectTipSetsPastHeight(iterator *TipsetIterator, minHeight abi.ChainEpoch) ([]block.TipSet, error) {
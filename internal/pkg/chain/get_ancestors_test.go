@@ -6,6 +6,8 @@ import (
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -290,6 +292,57 @@ func TestGetRecentAncestorsStartingEpochIsNull(t *testing.T) {
 	assert.Equal(t, abi.ChainEpoch(30), lastBlockHeight)
 }
 
+func TestGetAncestorsChecked(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genesis := builder.NewGenesis()
+	head := builder.AppendManyOn(10, genesis)
+
+	t.Run("complete chain", func(t *testing.T) {
+		ancestors, missing, err := chain.GetAncestorsChecked(ctx, builder, head, 5)
+		require.NoError(t, err)
+		assert.Equal(t, cid.Undef, missing)
+		assert.Equal(t, 6, len(ancestors))
+		assert.Equal(t, head, ancestors[0])
+		lastHeight, err := ancestors[len(ancestors)-1].Height()
+		require.NoError(t, err)
+		assert.Equal(t, abi.ChainEpoch(5), lastHeight)
+
+		// traversal stops at genesis
+		ancestors, missing, err = chain.GetAncestorsChecked(ctx, builder, head, -1)
+		require.NoError(t, err)
+		assert.Equal(t, cid.Undef, missing)
+		assert.Equal(t, 11, len(ancestors))
+		assert.Equal(t, genesis, ancestors[len(ancestors)-1])
+	})
+
+	t.Run("missing tipset", func(t *testing.T) {
+		removed := builder.RequireTipSets(head.Key(), 4)[3]
+		provider := &hidingBlockProvider{builder, removed.At(0).Cid()}
+
+		ancestors, missing, err := chain.GetAncestorsChecked(ctx, provider, head, 0)
+		require.Error(t, err)
+		assert.Equal(t, chain.ErrMissingAncestor, errors.Cause(err))
+		assert.Equal(t, removed.At(0).Cid(), missing)
+		// the tipsets above the missing link are still returned
+		assert.Equal(t, 3, len(ancestors))
+	})
+}
+
+// hidingBlockProvider serves blocks from a builder except for a single hidden block.
+type hidingBlockProvider struct {
+	*chain.Builder
+	hidden cid.Cid
+}
+
+func (p *hidingBlockProvider) GetBlock(ctx context.Context, c cid.Cid) (*block.Block, error) {
+	if c.Equals(p.hidden) {
+		return nil, errors.Errorf("block %s not found", c)
+	}
+	return p.Builder.GetBlock(ctx, c)
+}
+
 func TestFindCommonAncestorSameChain(t *testing.T) {
 	tf.UnitTest(t)
 	ctx := context.Background()
@@ -373,3 +426,27 @@ func TestFindCommonAncestorNullBlockFork(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, commonHead, commonAncestor)
 }
+
+func TestBuilderForkCommonAncestor(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+
+	base := builder.AppendManyOn(5, block.UndefTipSet)
+	left := builder.Fork(base, 3, nil)
+	right := builder.Fork(base, 4, func(bb *chain.BlockBuilder) {
+		bb.IncHeight(1)
+	})
+	require.False(t, left.Equals(right))
+
+	leftHeight, err := left.Height()
+	require.NoError(t, err)
+	assert.Equal(t, abi.ChainEpoch(7), leftHeight)
+	rightHeight, err := right.Height()
+	require.NoError(t, err)
+	assert.Equal(t, abi.ChainEpoch(12), rightHeight)
+
+	common, err := chain.FindCommonAncestor(chain.IterAncestors(ctx, builder, left), chain.IterAncestors(ctx, builder, right))
+	require.NoError(t, err)
+	assert.True(t, base.Equals(common))
+}
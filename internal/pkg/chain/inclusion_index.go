@@ -0,0 +1,344 @@
+package chain
+
+import (
+	"context"
+	"sync"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// DefaultInclusionWindow is the number of epochs below the head within which
+// the node indexes included messages, the chain's finality depth.
+const DefaultInclusionWindow = abi.ChainEpoch(900)
+
+// ErrMessageReincluded is returned when a block includes a message already
+// included by one of its ancestors.
+var ErrMessageReincluded = errors.New("message already included in an ancestor")
+
+// DefaultInclusionBatch is the most tipsets the index loads per head change.
+const DefaultInclusionBatch = 32
+
+// MessageInclusionIndex maps the CIDs of messages included in the tipsets
+// within a window of epochs below the head to the tipset first including
+// them. It follows the head as it is set, forgetting the messages of tipsets
+// dropped by a reorg or falling out of the window.
+//
+// The index does a bounded amount of work per head: it loads at most a batch
+// of tipsets each time the head is set, indexing those new at the head
+// first and backfilling the rest of the window below the indexed tipsets
+// with what remains. Messages below the indexed tipsets are not known until
+// the backfill reaches them.
+type MessageInclusionIndex struct {
+	tipsets  TipSetProvider
+	messages MessageProvider
+	window   abi.ChainEpoch
+	batch    int
+
+	mu sync.Mutex
+	// chain holds the indexed tipsets in ascending height order.
+	chain []block.TipSet
+	// included holds the message CIDs of each indexed tipset, by key.
+	included map[string][]cid.Cid
+	first    map[cid.Cid]block.TipSetKey
+	// complete is set once the backfill has reached the bottom of the window.
+	complete bool
+}
+
+// NewMessageInclusionIndex returns an empty index of the messages included
+// within `window` epochs of the head, loading at most `batch` tipsets per
+// head change.
+func NewMessageInclusionIndex(tipsets TipSetProvider, messages MessageProvider, window abi.ChainEpoch, batch int) *MessageInclusionIndex {
+	return &MessageInclusionIndex{
+		tipsets:  tipsets,
+		messages: messages,
+		window:   window,
+		batch:    batch,
+		included: make(map[string][]cid.Cid),
+		first:    make(map[cid.Cid]block.TipSetKey),
+	}
+}
+
+// FirstInclusion returns the key of the earliest indexed tipset including
+// the message with on-chain CID `mcid`, see types.SignedMessage.OnChainCid.
+func (idx *MessageInclusionIndex) FirstInclusion(mcid cid.Cid) (block.TipSetKey, bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	key, ok := idx.first[mcid]
+	return key, ok
+}
+
+// CheckNotIncluded returns an error wrapping ErrMessageReincluded if any of
+// `mcids` was included in `parent` or an indexed ancestor of it. Parents off
+// the indexed chain can't be checked and pass.
+func (idx *MessageInclusionIndex) CheckNotIncluded(parent block.TipSetKey, mcids []cid.Cid) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	parentPos := -1
+	for i, ts := range idx.chain {
+		if ts.Key().Equals(parent) {
+			parentPos = i
+			break
+		}
+	}
+	if parentPos < 0 {
+		return nil
+	}
+	// Tipsets above the parent are on the indexed chain but not ancestors.
+	above := make(map[string]struct{})
+	for _, ts := range idx.chain[parentPos+1:] {
+		above[ts.Key().String()] = struct{}{}
+	}
+	for _, c := range mcids {
+		first, ok := idx.first[c]
+		if !ok {
+			continue
+		}
+		if _, ok := above[first.String()]; !ok {
+			return errors.Wrapf(ErrMessageReincluded, "message %s first included in %s", c, first)
+		}
+	}
+	return nil
+}
+
+// SetHead updates the index for a new head. It indexes the tipsets back to
+// the common ancestor with the previous head, unindexing those dropped, and
+// backfills the window with the rest of the batch. If the new tipsets don't
+// fit in the batch, the index restarts from the head.
+func (idx *MessageInclusionIndex) SetHead(ctx context.Context, head block.TipSet) error {
+	headHeight, err := head.Height()
+	if err != nil {
+		return err
+	}
+	minHeight := headHeight - idx.window
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	positions := make(map[string]int, len(idx.chain))
+	for i, ts := range idx.chain {
+		positions[ts.Key().String()] = i
+	}
+	common := -1
+	restart := false
+	var added []block.TipSet
+	err = WalkAncestors(ctx, idx.tipsets, head, func(ts block.TipSet) (bool, error) {
+		if pos, ok := positions[ts.Key().String()]; ok {
+			common = pos
+			return true, nil
+		}
+		height, err := ts.Height()
+		if err != nil {
+			return false, err
+		}
+		if height < minHeight {
+			return true, nil
+		}
+		if len(added) == idx.batch {
+			restart = true
+			return true, nil
+		}
+		added = append(added, ts)
+		return false, nil
+	})
+	if err != nil {
+		return err
+	}
+	addedCids, err := idx.loadCids(ctx, added)
+	if err != nil {
+		return err
+	}
+
+	// Modify the index only once loading has succeeded, so that a failure
+	// leaves it unchanged.
+	if restart {
+		common = -1
+	}
+	if common < 0 {
+		// Unless it was cut short, the walk covered the whole window.
+		idx.complete = !restart
+	}
+	for len(idx.chain) > common+1 {
+		idx.unindex(len(idx.chain) - 1)
+	}
+	for i := len(added) - 1; i >= 0; i-- {
+		idx.index(added[i], addedCids[i])
+	}
+	for len(idx.chain) > 0 {
+		height, err := idx.chain[0].Height()
+		if err != nil {
+			return err
+		}
+		if height >= minHeight {
+			break
+		}
+		idx.unindex(0)
+		idx.complete = true
+	}
+
+	return idx.backfill(ctx, minHeight, idx.batch-len(added))
+}
+
+// backfill indexes up to `limit` tipsets of the window below the lowest
+// indexed tipset. It must be called with the lock held.
+func (idx *MessageInclusionIndex) backfill(ctx context.Context, minHeight abi.ChainEpoch, limit int) error {
+	if idx.complete || len(idx.chain) == 0 || limit <= 0 {
+		return nil
+	}
+	lowest := idx.chain[0]
+	parents, err := lowest.Parents()
+	if err != nil {
+		return err
+	}
+	if parents.Empty() {
+		idx.complete = true
+		return nil
+	}
+	parent, err := idx.tipsets.GetTipSet(parents)
+	if err != nil {
+		return err
+	}
+
+	var below []block.TipSet
+	reachedBottom := false
+	err = WalkAncestors(ctx, idx.tipsets, parent, func(ts block.TipSet) (bool, error) {
+		height, err := ts.Height()
+		if err != nil {
+			return false, err
+		}
+		if height < minHeight {
+			reachedBottom = true
+			return true, nil
+		}
+		if len(below) == limit {
+			return true, nil
+		}
+		below = append(below, ts)
+		return false, nil
+	})
+	if err != nil {
+		return err
+	}
+	belowCids, err := idx.loadCids(ctx, below)
+	if err != nil {
+		return err
+	}
+
+	for i, ts := range below {
+		idx.prepend(ts, belowCids[i])
+	}
+	if reachedBottom || len(below) < limit {
+		// The walk stopped below the window or at the genesis tipset.
+		idx.complete = true
+	}
+	return nil
+}
+
+// loadCids loads the message CIDs of each of `tipsets`.
+func (idx *MessageInclusionIndex) loadCids(ctx context.Context, tipsets []block.TipSet) ([][]cid.Cid, error) {
+	cids := make([][]cid.Cid, len(tipsets))
+	for i, ts := range tipsets {
+		var err error
+		if cids[i], err = TipSetMessageCids(ctx, idx.messages, ts); err != nil {
+			return nil, err
+		}
+	}
+	return cids, nil
+}
+
+// index appends `ts` to the indexed chain. It must be called with the lock held.
+func (idx *MessageInclusionIndex) index(ts block.TipSet, mcids []cid.Cid) {
+	key := ts.Key()
+	idx.chain = append(idx.chain, ts)
+	idx.included[key.String()] = mcids
+	for _, c := range mcids {
+		if _, ok := idx.first[c]; !ok {
+			idx.first[c] = key
+		}
+	}
+}
+
+// prepend adds `ts`, the parent of the lowest indexed tipset, to the bottom
+// of the indexed chain. It must be called with the lock held.
+func (idx *MessageInclusionIndex) prepend(ts block.TipSet, mcids []cid.Cid) {
+	key := ts.Key()
+	idx.chain = append([]block.TipSet{ts}, idx.chain...)
+	idx.included[key.String()] = mcids
+	for _, c := range mcids {
+		idx.first[c] = key
+	}
+}
+
+// unindex removes the tipset at `pos` from the indexed chain, moving the
+// first inclusion of its messages to the next indexed tipset including them,
+// if any. It must be called with the lock held.
+func (idx *MessageInclusionIndex) unindex(pos int) {
+	key := idx.chain[pos].Key()
+	idx.chain = append(idx.chain[:pos:pos], idx.chain[pos+1:]...)
+
+	orphaned := make(map[cid.Cid]struct{})
+	for _, c := range idx.included[key.String()] {
+		if first, ok := idx.first[c]; ok && first.Equals(key) {
+			delete(idx.first, c)
+			orphaned[c] = struct{}{}
+		}
+	}
+	delete(idx.included, key.String())
+
+	for _, ts := range idx.chain {
+		if len(orphaned) == 0 {
+			break
+		}
+		for _, c := range idx.included[ts.Key().String()] {
+			if _, ok := orphaned[c]; ok {
+				idx.first[c] = ts.Key()
+				delete(orphaned, c)
+			}
+		}
+	}
+}
+
+// TipSetMessageCids returns the on-chain CIDs of the messages included in the
+// blocks of `ts`.
+func TipSetMessageCids(ctx context.Context, messages MessageProvider, ts block.TipSet) ([]cid.Cid, error) {
+	var cids []cid.Cid
+	for i := 0; i < ts.Len(); i++ {
+		blk := ts.At(i)
+		secpMsgs, blsMsgs, err := messages.LoadMessages(ctx, blk.Messages.Cid)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load messages of block %s", blk.Cid())
+		}
+		blkCids, err := MessageCids(secpMsgs, blsMsgs)
+		if err != nil {
+			return nil, err
+		}
+		cids = append(cids, blkCids...)
+	}
+	return cids, nil
+}
+
+// MessageCids returns the on-chain CIDs of the messages of a block, see
+// types.SignedMessage.OnChainCid.
+func MessageCids(secpMsgs []*types.SignedMessage, blsMsgs []*types.UnsignedMessage) ([]cid.Cid, error) {
+	cids := make([]cid.Cid, 0, len(secpMsgs)+len(blsMsgs))
+	for _, m := range secpMsgs {
+		c, err := m.OnChainCid()
+		if err != nil {
+			return nil, err
+		}
+		cids = append(cids, c)
+	}
+	for _, m := range blsMsgs {
+		c, err := m.Cid()
+		if err != nil {
+			return nil, err
+		}
+		cids = append(cids, c)
+	}
+	return cids, nil
+}
@@ -0,0 +1,182 @@
+package chain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
+	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+)
+
+func TestMessageInclusionIndex(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	mm := vm.NewMessageMaker(t, types.MustGenerateKeyInfo(1, 42))
+	alice := mm.Addresses()[0]
+	m1, m2, m3, m4 := mm.NewSignedMessage(alice, 1), mm.NewSignedMessage(alice, 2), mm.NewSignedMessage(alice, 3), mm.NewSignedMessage(alice, 4)
+
+	including := func(parent block.TipSet, msgs ...*types.SignedMessage) block.TipSet {
+		return builder.BuildOneOn(parent, func(b *chain.BlockBuilder) {
+			b.AddMessages(msgs, []*types.UnsignedMessage{})
+		})
+	}
+	mcid := func(msg *types.SignedMessage) cid.Cid {
+		c, err := msg.Cid()
+		require.NoError(t, err)
+		return c
+	}
+	assertFirst := func(idx *chain.MessageInclusionIndex, msg *types.SignedMessage, ts block.TipSet) {
+		key, ok := idx.FirstInclusion(mcid(msg))
+		require.True(t, ok)
+		assert.Equal(t, ts.Key(), key)
+	}
+	assertAbsent := func(idx *chain.MessageInclusionIndex, msg *types.SignedMessage) {
+		_, ok := idx.FirstInclusion(mcid(msg))
+		assert.False(t, ok)
+	}
+
+	genesis := builder.NewGenesis()
+	a := including(genesis, m1)
+	b := including(a, m1, m2)
+	c := including(b, m3)
+
+	t.Run("reports first inclusion", func(t *testing.T) {
+		idx := chain.NewMessageInclusionIndex(builder, builder, 10, chain.DefaultInclusionBatch)
+		require.NoError(t, idx.SetHead(ctx, b))
+		require.NoError(t, idx.SetHead(ctx, c))
+		assertFirst(idx, m1, a)
+		assertFirst(idx, m2, b)
+		assertFirst(idx, m3, c)
+		assertAbsent(idx, m4)
+	})
+
+	t.Run("reorg drops abandoned tipsets", func(t *testing.T) {
+		idx := chain.NewMessageInclusionIndex(builder, builder, 10, chain.DefaultInclusionBatch)
+		require.NoError(t, idx.SetHead(ctx, c))
+
+		// fork replaces b and c.
+		fork := including(a, m4)
+		require.NoError(t, idx.SetHead(ctx, fork))
+		assertFirst(idx, m1, a)
+		assertAbsent(idx, m2)
+		assertAbsent(idx, m3)
+		assertFirst(idx, m4, fork)
+
+		require.NoError(t, idx.SetHead(ctx, c))
+		assertFirst(idx, m2, b)
+		assertFirst(idx, m3, c)
+		assertAbsent(idx, m4)
+	})
+
+	t.Run("tipsets outside window forgotten", func(t *testing.T) {
+		idx := chain.NewMessageInclusionIndex(builder, builder, 1, chain.DefaultInclusionBatch)
+		require.NoError(t, idx.SetHead(ctx, c))
+		// a is below the window, so b is the first inclusion seen.
+		assertFirst(idx, m1, b)
+		assertFirst(idx, m3, c)
+
+		d := including(c)
+		require.NoError(t, idx.SetHead(ctx, d))
+		assertAbsent(idx, m1)
+		assertAbsent(idx, m2)
+		assertFirst(idx, m3, c)
+	})
+
+	t.Run("first inclusion moves to next indexed tipset", func(t *testing.T) {
+		idx := chain.NewMessageInclusionIndex(builder, builder, 1, chain.DefaultInclusionBatch)
+		require.NoError(t, idx.SetHead(ctx, b))
+		assertFirst(idx, m1, a)
+
+		// a falls out of the window, b also includes m1.
+		require.NoError(t, idx.SetHead(ctx, c))
+		assertFirst(idx, m1, b)
+	})
+
+	t.Run("indexes in batches", func(t *testing.T) {
+		idx := chain.NewMessageInclusionIndex(builder, builder, 10, 1)
+		require.NoError(t, idx.SetHead(ctx, c))
+		assertFirst(idx, m3, c)
+		assertAbsent(idx, m2)
+		assertAbsent(idx, m1)
+
+		// Setting the head again backfills the window, one tipset at a time.
+		require.NoError(t, idx.SetHead(ctx, c))
+		assertFirst(idx, m2, b)
+		assertFirst(idx, m1, b)
+
+		require.NoError(t, idx.SetHead(ctx, c))
+		assertFirst(idx, m1, a)
+
+		// More new tipsets than fit in a batch restart the index at the head.
+		d := including(c, m4)
+		head := including(d)
+		require.NoError(t, idx.SetHead(ctx, head))
+		assertAbsent(idx, m4)
+		assertAbsent(idx, m1)
+		require.NoError(t, idx.SetHead(ctx, head))
+		assertFirst(idx, m4, d)
+	})
+
+	t.Run("rejects messages included by ancestors", func(t *testing.T) {
+		idx := chain.NewMessageInclusionIndex(builder, builder, 10, chain.DefaultInclusionBatch)
+		require.NoError(t, idx.SetHead(ctx, c))
+
+		err := idx.CheckNotIncluded(b.Key(), []cid.Cid{mcid(m4), mcid(m1)})
+		require.Error(t, err)
+		assert.Equal(t, chain.ErrMessageReincluded, errors.Cause(err))
+		assert.NoError(t, idx.CheckNotIncluded(b.Key(), []cid.Cid{mcid(m4)}))
+		// m3 is first included by c, which is above b.
+		assert.NoError(t, idx.CheckNotIncluded(b.Key(), []cid.Cid{mcid(m3)}))
+		// A parent off the indexed chain can't be checked.
+		fork := including(a, m4)
+		assert.NoError(t, idx.CheckNotIncluded(fork.Key(), []cid.Cid{mcid(m1)}))
+	})
+
+	t.Run("mempool key matches inclusion key", func(t *testing.T) {
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		m5 := mm.NewSignedMessage(alice, 5)
+		poolCid, err := pool.Add(ctx, m5, 0)
+		require.NoError(t, err)
+
+		included := including(c, m5)
+		idx := chain.NewMessageInclusionIndex(builder, builder, 10, chain.DefaultInclusionBatch)
+		require.NoError(t, idx.SetHead(ctx, included))
+		key, ok := idx.FirstInclusion(poolCid)
+		require.True(t, ok)
+		assert.Equal(t, included.Key(), key)
+	})
+
+	t.Run("mempool key matches inclusion key for BLS messages", func(t *testing.T) {
+		blsMaker := vm.NewMessageMaker(t, []crypto.KeyInfo{crypto.NewBLSKeyRandom()})
+		bob := blsMaker.Addresses()[0]
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		m6 := blsMaker.NewSignedMessage(bob, 0)
+		poolCid, err := pool.Add(ctx, m6, 0)
+		require.NoError(t, err)
+
+		// BLS messages are included unsigned.
+		included := builder.BuildOneOn(c, func(b *chain.BlockBuilder) {
+			b.AddMessages([]*types.SignedMessage{}, []*types.UnsignedMessage{&m6.Message})
+		})
+		idx := chain.NewMessageInclusionIndex(builder, builder, 10, chain.DefaultInclusionBatch)
+		require.NoError(t, idx.SetHead(ctx, included))
+		key, ok := idx.FirstInclusion(poolCid)
+		require.True(t, ok)
+		assert.Equal(t, included.Key(), key)
+	})
+}
@@ -3,15 +3,19 @@ package chain
 import (
 	"context"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-amt-ipld/v2"
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	"github.com/pkg/errors"
 	cbg "github.com/whyrusleeping/cbor-gen"
 
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/constants"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
 	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
@@ -32,6 +36,7 @@ type MessageWriter interface {
 	StoreMessages(ctx context.Context, secpMessages []*types.SignedMessage, blsMessages []*types.UnsignedMessage) (cid.Cid, error)
 	StoreReceipts(context.Context, []vm.MessageReceipt) (cid.Cid, error)
 	StoreTxMeta(context.Context, types.TxMeta) (cid.Cid, error)
+	ComputeMessageRoot(ctx context.Context, msgs []*types.SignedMessage) (cid.Cid, error)
 }
 
 // MessageStore stores and loads collections of signed messages and receipts.
@@ -53,7 +58,7 @@ func (ms *MessageStore) LoadMessages(ctx context.Context, metaCid cid.Cid) ([]*t
 		return nil, nil, err
 	}
 
-	secpCids, err := ms.loadAMTCids(ctx, meta.SecpRoot.Cid)
+	secpCids, err := ms.loadMessageCids(ctx, meta.Version, meta.SecpRoot.Cid)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -73,7 +78,7 @@ func (ms *MessageStore) LoadMessages(ctx context.Context, metaCid cid.Cid) ([]*t
 		secpMsgs[i] = message
 	}
 
-	blsCids, err := ms.loadAMTCids(ctx, meta.BLSRoot.Cid)
+	blsCids, err := ms.loadMessageCids(ctx, meta.Version, meta.BLSRoot.Cid)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -96,10 +101,28 @@ func (ms *MessageStore) LoadMessages(ctx context.Context, metaCid cid.Cid) ([]*t
 	return secpMsgs, blsMsgs, nil
 }
 
+// BlockMessages loads the messages included in `b`, split into the BLS
+// messages, whose signatures are aggregated in the block, and the secp256k1
+// signed messages. It errors if a message in the secp collection is not
+// signed with secp256k1.
+func (ms *MessageStore) BlockMessages(ctx context.Context, b *block.Block) ([]*types.UnsignedMessage, []*types.SignedMessage, error) {
+	secpMsgs, blsMsgs, err := ms.LoadMessages(ctx, b.Messages.Cid)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to load messages of block %s", b.Cid())
+	}
+	for _, msg := range secpMsgs {
+		if msg.Signature.Type != crypto.SigTypeSecp256k1 {
+			return nil, nil, errors.Errorf("block %s includes message from %s with signature type %d among secp256k1 messages", b.Cid(), msg.Message.From, msg.Signature.Type)
+		}
+	}
+	return blsMsgs, secpMsgs, nil
+}
+
 // StoreMessages puts the input signed messages to a collection and then writes
-// this collection to ipld storage.  The cid of the collection is returned.
+// this collection to ipld storage in the current TxMeta version.  The cid of
+// the collection is returned.
 func (ms *MessageStore) StoreMessages(ctx context.Context, secpMessages []*types.SignedMessage, blsMessages []*types.UnsignedMessage) (cid.Cid, error) {
-	var ret types.TxMeta
+	ret := types.TxMeta{Version: types.CurrentTxMetaVersion}
 	var err error
 
 	// store secp messages
@@ -128,6 +151,24 @@ func (ms *MessageStore) StoreMessages(ctx context.Context, secpMessages []*types
 	return ms.StoreTxMeta(ctx, ret)
 }
 
+// ComputeMessageRoot stores `msgs` as StoreMessages would for a block
+// including them and returns the cid of the TxMeta the block's Messages
+// field commits to. BLS messages are stored unsigned, as their signatures are
+// aggregated in the block, and all others signed, each kind in the order
+// given.
+func (ms *MessageStore) ComputeMessageRoot(ctx context.Context, msgs []*types.SignedMessage) (cid.Cid, error) {
+	var secpMessages []*types.SignedMessage
+	var blsMessages []*types.UnsignedMessage
+	for _, msg := range msgs {
+		if msg.Message.From.Protocol() == address.BLS {
+			blsMessages = append(blsMessages, &msg.Message)
+		} else {
+			secpMessages = append(secpMessages, msg)
+		}
+	}
+	return ms.StoreMessages(ctx, secpMessages, blsMessages)
+}
+
 // LoadReceipts loads the signed messages in the collection with cid c from ipld
 // storage and returns the slice implied by the collection
 func (ms *MessageStore) LoadReceipts(ctx context.Context, c cid.Cid) ([]vm.MessageReceipt, error) {
@@ -166,6 +207,39 @@ func (ms *MessageStore) StoreReceipts(ctx context.Context, receipts []vm.Message
 	return ms.storeAMTCids(ctx, cids)
 }
 
+// ComputeReceiptRoot returns the root of the AMT holding `receipts` in
+// order, i.e. the MessageReceipts root a block would commit to. Unlike
+// StoreReceipts, it stores nothing.
+func (ms *MessageStore) ComputeReceiptRoot(ctx context.Context, receipts []vm.MessageReceipt) (cid.Cid, error) {
+	scratch := NewMessageStore(blockstore.NewBlockstore(datastore.NewMapDatastore()))
+	return scratch.StoreReceipts(ctx, receipts)
+}
+
+// ValidateReceiptRoot checks that the MessageReceipts root claimed by `blk`
+// is the root of `receipts`, the receipts of applying its parent tipset's
+// messages.
+func ValidateReceiptRoot(ctx context.Context, blk *block.Block, receipts []vm.MessageReceipt, ms *MessageStore) error {
+	root, err := ms.ComputeReceiptRoot(ctx, receipts)
+	if err != nil {
+		return errors.Wrap(err, "failed to compute receipt root")
+	}
+	if !root.Equals(blk.MessageReceipts.Cid) {
+		return errors.Errorf("block %s claims receipt root %s but receipts have root %s", blk.Cid(), blk.MessageReceipts.Cid, root)
+	}
+	return nil
+}
+
+// loadMessageCids loads the message cids under root, decoding the collection
+// in the layout used by TxMetas of the given version.
+func (ms *MessageStore) loadMessageCids(ctx context.Context, version types.TxMetaVersion, root cid.Cid) ([]cid.Cid, error) {
+	switch version {
+	case types.TxMetaVersionLegacy, types.TxMetaVersion1:
+		return ms.loadAMTCids(ctx, root)
+	default:
+		return nil, errors.Errorf("unknown tx meta version %d", version)
+	}
+}
+
 func (ms *MessageStore) loadAMTCids(ctx context.Context, c cid.Cid) ([]cid.Cid, error) {
 	as := cborutil.NewIpldStore(ms.bs)
 	a, err := amt.LoadAMT(ctx, as, c)
@@ -224,7 +298,8 @@ func (ms *MessageStore) storeSignedMessages(messages []*types.SignedMessage) ([]
 	return cids, nil
 }
 
-// StoreTxMeta writes the secproot, blsroot block to the message store
+// StoreTxMeta writes the secproot, blsroot block to the message store in the
+// encoding selected by the TxMeta's version.
 func (ms *MessageStore) StoreTxMeta(ctx context.Context, meta types.TxMeta) (cid.Cid, error) {
 	return ms.storeBlock(meta)
 }
@@ -4,11 +4,17 @@ import (
 	"context"
 	"testing"
 
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
 	"github.com/ipfs/go-datastore"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
 )
@@ -62,3 +68,187 @@ func TestMessageStoreReceiptsHappy(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, receipts, rtReceipts)
 }
+
+func TestMessageStoreComputeMessageRoot(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signer := types.NewMockSigner(types.MustGenerateMixedKeyInfo(1, 1))
+	blsAddr, secpAddr := signer.Addresses[0], signer.Addresses[1]
+	newMsg := func(from address.Address, nonce uint64) *types.SignedMessage {
+		msg := types.NewMeteredMessage(from, secpAddr, nonce, types.ZeroAttoFIL, builtin.MethodSend, []byte{}, types.NewAttoFILFromFIL(1), 300)
+		smsg, err := types.NewSignedMessage(*msg, signer)
+		require.NoError(t, err)
+		return smsg
+	}
+	s0, s1 := newMsg(secpAddr, 0), newMsg(secpAddr, 1)
+	b0 := newMsg(blsAddr, 0)
+
+	ms := chain.NewMessageStore(blockstore.NewBlockstore(datastore.NewMapDatastore()))
+
+	root, err := ms.ComputeMessageRoot(ctx, []*types.SignedMessage{s0, b0, s1})
+	require.NoError(t, err)
+
+	t.Run("matches stored tx meta", func(t *testing.T) {
+		expected, err := ms.StoreMessages(ctx, []*types.SignedMessage{s0, s1}, []*types.UnsignedMessage{&b0.Message})
+		require.NoError(t, err)
+		assert.Equal(t, expected, root)
+
+		secp, bls, err := ms.LoadMessages(ctx, root)
+		require.NoError(t, err)
+		assert.Equal(t, []*types.SignedMessage{s0, s1}, secp)
+		assert.Equal(t, []*types.UnsignedMessage{&b0.Message}, bls)
+	})
+
+	t.Run("ordering within a kind matters", func(t *testing.T) {
+		reordered, err := ms.ComputeMessageRoot(ctx, []*types.SignedMessage{s1, b0, s0})
+		require.NoError(t, err)
+		assert.NotEqual(t, root, reordered)
+	})
+
+	t.Run("no messages gives empty tx meta", func(t *testing.T) {
+		empty, err := ms.ComputeMessageRoot(ctx, nil)
+		require.NoError(t, err)
+		assert.Equal(t, types.EmptyTxMetaCID, empty)
+	})
+}
+
+func TestValidateReceiptRoot(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	mr := vm.NewReceiptMaker()
+	receipts := []vm.MessageReceipt{mr.NewReceipt(), mr.NewReceipt(), mr.NewReceipt()}
+
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	ms := chain.NewMessageStore(bs)
+	root, err := ms.ComputeReceiptRoot(ctx, receipts)
+	require.NoError(t, err)
+	blk := &block.Block{MessageReceipts: e.NewCid(root)}
+
+	t.Run("computing the root stores nothing", func(t *testing.T) {
+		has, err := bs.Has(root)
+		require.NoError(t, err)
+		assert.False(t, has)
+
+		stored, err := ms.StoreReceipts(ctx, receipts)
+		require.NoError(t, err)
+		assert.Equal(t, root, stored)
+	})
+
+	t.Run("matching receipts pass", func(t *testing.T) {
+		assert.NoError(t, chain.ValidateReceiptRoot(ctx, blk, receipts, ms))
+	})
+
+	t.Run("tampered receipts fail", func(t *testing.T) {
+		tampered := append([]vm.MessageReceipt{}, receipts...)
+		tampered[1].ExitCode++
+		assert.Error(t, chain.ValidateReceiptRoot(ctx, blk, tampered, ms))
+	})
+
+	t.Run("reordered receipts fail", func(t *testing.T) {
+		reordered := []vm.MessageReceipt{receipts[2], receipts[1], receipts[0]}
+		assert.Error(t, chain.ValidateReceiptRoot(ctx, blk, reordered, ms))
+	})
+}
+
+func TestMessageStoreBlockMessages(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signer := types.NewMockSigner(types.MustGenerateMixedKeyInfo(1, 1))
+	blsAddr, secpAddr := signer.Addresses[0], signer.Addresses[1]
+	newMsg := func(from address.Address, nonce uint64) *types.UnsignedMessage {
+		return types.NewMeteredMessage(from, secpAddr, nonce, types.ZeroAttoFIL, builtin.MethodSend, []byte{}, types.NewAttoFILFromFIL(1), 300)
+	}
+	sign := func(msg *types.UnsignedMessage) *types.SignedMessage {
+		smsg, err := types.NewSignedMessage(*msg, signer)
+		require.NoError(t, err)
+		return smsg
+	}
+	bls := []*types.UnsignedMessage{newMsg(blsAddr, 0), newMsg(blsAddr, 1)}
+	secp := []*types.SignedMessage{sign(newMsg(secpAddr, 0)), sign(newMsg(secpAddr, 1)), sign(newMsg(secpAddr, 2))}
+
+	ms := chain.NewMessageStore(blockstore.NewBlockstore(datastore.NewMapDatastore()))
+
+	t.Run("messages split by signature type", func(t *testing.T) {
+		root, err := ms.StoreMessages(ctx, secp, bls)
+		require.NoError(t, err)
+		gotBLS, gotSecp, err := ms.BlockMessages(ctx, &block.Block{Messages: e.NewCid(root)})
+		require.NoError(t, err)
+		assert.Equal(t, bls, gotBLS)
+		assert.Equal(t, secp, gotSecp)
+	})
+
+	t.Run("bls signed message among secp messages fails", func(t *testing.T) {
+		root, err := ms.StoreMessages(ctx, append([]*types.SignedMessage{sign(bls[0])}, secp...), nil)
+		require.NoError(t, err)
+		_, _, err = ms.BlockMessages(ctx, &block.Block{Messages: e.NewCid(root)})
+		assert.Error(t, err)
+	})
+}
+
+func TestMessageStoreTxMetaVersions(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	keys := types.MustGenerateKeyInfo(2, 42)
+	mm := vm.NewMessageMaker(t, keys)
+	alice := mm.Addresses()[0]
+	bob := mm.Addresses()[1]
+	msgs := []*types.SignedMessage{
+		mm.NewSignedMessage(alice, 0),
+		mm.NewSignedMessage(bob, 0),
+	}
+
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	ms := chain.NewMessageStore(bs)
+
+	currentCid, err := ms.StoreMessages(ctx, msgs, []*types.UnsignedMessage{})
+	require.NoError(t, err)
+
+	t.Run("current format written and round trips", func(t *testing.T) {
+		meta, err := ms.LoadTxMeta(ctx, currentCid)
+		require.NoError(t, err)
+		assert.Equal(t, types.CurrentTxMetaVersion, meta.Version)
+
+		rtMsgs, _, err := ms.LoadMessages(ctx, currentCid)
+		require.NoError(t, err)
+		assert.Equal(t, msgs, rtMsgs)
+
+		// re-storing a loaded TxMeta preserves its cid
+		again, err := ms.StoreTxMeta(ctx, meta)
+		require.NoError(t, err)
+		assert.Equal(t, currentCid, again)
+	})
+
+	t.Run("legacy format round trips", func(t *testing.T) {
+		meta, err := ms.LoadTxMeta(ctx, currentCid)
+		require.NoError(t, err)
+		meta.Version = types.TxMetaVersionLegacy
+
+		legacyCid, err := ms.StoreTxMeta(ctx, meta)
+		require.NoError(t, err)
+		assert.NotEqual(t, currentCid, legacyCid)
+
+		legacyMeta, err := ms.LoadTxMeta(ctx, legacyCid)
+		require.NoError(t, err)
+		assert.Equal(t, types.TxMetaVersionLegacy, legacyMeta.Version)
+
+		rtMsgs, _, err := ms.LoadMessages(ctx, legacyCid)
+		require.NoError(t, err)
+		assert.Equal(t, msgs, rtMsgs)
+
+		again, err := ms.StoreTxMeta(ctx, legacyMeta)
+		require.NoError(t, err)
+		assert.Equal(t, legacyCid, again)
+	})
+
+	t.Run("unknown version is rejected", func(t *testing.T) {
+		meta, err := ms.LoadTxMeta(ctx, currentCid)
+		require.NoError(t, err)
+		meta.Version = types.LatestTxMetaVersion + 1
+		_, err = ms.StoreTxMeta(ctx, meta)
+		assert.Error(t, err)
+	})
+}
@@ -4,77 +4,257 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"sync"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	acrypto "github.com/filecoin-project/specs-actors/actors/crypto"
 	"github.com/minio/blake2b-simd"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
 )
 
+// DrandSchedule maps drand beacon rounds to the chain epochs they are sampled at.
+type DrandSchedule interface {
+	EpochForRound(round uint64) abi.ChainEpoch
+}
+
+// ErrBeaconRoundAfterHead is returned when a drand round maps to an epoch the chain has not reached yet.
+var ErrBeaconRoundAfterHead = errors.New("drand round maps to an epoch after the head")
+
+// DefaultSampleCacheSize is the number of seeds held by a caching sampler.
+const DefaultSampleCacheSize = 1024
+
 // A sampler draws randomness seeds from the chain.
 type Sampler struct {
-	reader TipSetProvider
+	reader   TipSetProvider
+	schedule DrandSchedule
+	cache    *sampleCache
 }
 
 func NewSampler(reader TipSetProvider) *Sampler {
-	return &Sampler{reader}
+	return &Sampler{reader: reader}
+}
+
+// NewCachingSampler returns a sampler that remembers the seeds it draws, keyed by head and epoch.
+func NewCachingSampler(reader TipSetProvider, size int) *Sampler {
+	return &Sampler{reader: reader, cache: newSampleCache(size)}
+}
+
+// NewSamplerWithSchedule returns a sampler that can also draw randomness for drand rounds.
+func NewSamplerWithSchedule(reader TipSetProvider, schedule DrandSchedule) *Sampler {
+	return &Sampler{reader: reader, schedule: schedule}
 }
 
 // Draws a randomness seed from the chain identified by `head` and the highest tipset with height <= `epoch`.
 // If `head` is empty (as when processing the genesis block), the seed is empty.
 func (s *Sampler) Sample(ctx context.Context, head block.TipSetKey, epoch abi.ChainEpoch) (crypto.RandomSeed, error) {
-	var ticket block.Ticket
-	if !head.Empty() {
-		start, err := s.reader.GetTipSet(head)
-		if err != nil {
-			return nil, err
-		}
-		// Note: it is not an error to have epoch > start.Height(); in the case of a run of null blocks the
-		// sought-after height may be after the base (last non-empty) tipset.
-		// It's also not an error for the requested epoch to be negative.
+	return s.sample(ctx, head, epoch, s.findTipsetAtEpoch)
+}
 
-		tip, err := s.findTipsetAtEpoch(ctx, start, epoch)
-		if err != nil {
-			return nil, err
-		}
-		ticket, err = tip.MinTicket()
-		if err != nil {
-			return nil, err
+// SampleStrict draws the same seed as Sample, but requires the chain from `head` back to `epoch` to be
+// complete: if any tipset on the way can not be loaded it returns an error wrapping ErrMissingAncestor.
+// Validation should use it, since a gap means the chain is incomplete rather than that blocks were null.
+func (s *Sampler) SampleStrict(ctx context.Context, head block.TipSetKey, epoch abi.ChainEpoch) (crypto.RandomSeed, error) {
+	return s.sample(ctx, head, epoch, s.findTipsetAtEpochStrict)
+}
+
+func (s *Sampler) sample(ctx context.Context, head block.TipSetKey, epoch abi.ChainEpoch,
+	find func(context.Context, block.TipSet, abi.ChainEpoch) (block.TipSet, error)) (crypto.RandomSeed, error) {
+	if s.cache != nil {
+		if seed, ok := s.cache.get(head, epoch); ok {
+			return seed, nil
 		}
-	} else {
-		// Sampling for the genesis block.
-		ticket.VRFProof = []byte{}
+	}
+
+	ticket, err := s.minTicket(ctx, head, epoch, find)
+	if err != nil {
+		return nil, err
 	}
 
 	buf := bytes.Buffer{}
 	buf.Write(ticket.VRFProof)
-	err := binary.Write(&buf, binary.BigEndian, epoch)
+	err = binary.Write(&buf, binary.BigEndian, epoch)
+	if err != nil {
+		return nil, err
+	}
+
+	bufHash := blake2b.Sum256(buf.Bytes())
+	seed := crypto.RandomSeed(bufHash[:])
+	if s.cache != nil {
+		s.cache.put(head, epoch, seed)
+	}
+	return seed, nil
+}
+
+// MinTicketAt returns the ticket whose VRF proof Sample hashes into the seed it draws from the chain
+// identified by `head` at `epoch`: the min ticket of the highest tipset with height <= `epoch`. It lets
+// the seed be recomputed independently. If `head` is empty the ticket has an empty proof.
+func (s *Sampler) MinTicketAt(ctx context.Context, head block.TipSetKey, epoch abi.ChainEpoch) (block.Ticket, error) {
+	return s.minTicket(ctx, head, epoch, s.findTipsetAtEpoch)
+}
+
+func (s *Sampler) minTicket(ctx context.Context, head block.TipSetKey, epoch abi.ChainEpoch,
+	find func(context.Context, block.TipSet, abi.ChainEpoch) (block.TipSet, error)) (block.Ticket, error) {
+	if head.Empty() {
+		// Sampling for the genesis block.
+		return block.Ticket{VRFProof: []byte{}}, nil
+	}
+	start, err := s.reader.GetTipSet(head)
+	if err != nil {
+		return block.Ticket{}, err
+	}
+	// Note: it is not an error to have epoch > start.Height(); in the case of a run of null blocks the
+	// sought-after height may be after the base (last non-empty) tipset.
+	// It's also not an error for the requested epoch to be negative.
+	tip, err := find(ctx, start, epoch)
+	if err != nil {
+		return block.Ticket{}, err
+	}
+	return tip.MinTicket()
+}
+
+// WindowPoStRandomness draws the randomness for `miner`'s window PoSt challenge at `deadline` from
+// the chain identified by `head`. It hashes the seed Sample draws at `deadline` with the miner's
+// address behind the windowed PoSt domain separation tag, so it differs from the randomness of other
+// miners and of other uses of the same seed, such as elections.
+func (s *Sampler) WindowPoStRandomness(ctx context.Context, head block.TipSetKey, deadline abi.ChainEpoch, miner address.Address) (crypto.RandomSeed, error) {
+	seed, err := s.Sample(ctx, head, deadline)
 	if err != nil {
 		return nil, err
 	}
 
+	buf := bytes.Buffer{}
+	err = binary.Write(&buf, binary.BigEndian, int64(acrypto.DomainSeparationTag_WindowedPoStChallengeSeed))
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(seed)
+	buf.Write(miner.Bytes())
+
 	bufHash := blake2b.Sum256(buf.Bytes())
-	return bufHash[:], err
+	return crypto.RandomSeed(bufHash[:]), nil
+}
+
+// Warm draws and caches the seeds for every epoch in [fromEpoch, toEpoch] on the chain identified by `head`,
+// so that later calls to Sample for those epochs are served from the cache.
+// It is a no-op for a sampler constructed without a cache.
+func (s *Sampler) Warm(ctx context.Context, head block.TipSetKey, fromEpoch, toEpoch abi.ChainEpoch) error {
+	if s.cache == nil {
+		return nil
+	}
+	for epoch := fromEpoch; epoch <= toEpoch; epoch++ {
+		if _, err := s.Sample(ctx, head, epoch); err != nil {
+			return errors.Wrapf(err, "failed to warm epoch %d", epoch)
+		}
+	}
+	return nil
+}
+
+// Draws a randomness seed for a drand `round` from the chain identified by `head`.
+// The round is mapped to a chain epoch with the sampler's schedule, and the seed is
+// the one `Sample` draws at that epoch. Rounds mapping to an epoch after the head
+// return ErrBeaconRoundAfterHead, since their randomness is not yet fixed by the chain.
+func (s *Sampler) SampleBeacon(ctx context.Context, head block.TipSetKey, round uint64) (crypto.RandomSeed, error) {
+	if s.schedule == nil {
+		return nil, errors.New("sampler has no drand schedule")
+	}
+	epoch := s.schedule.EpochForRound(round)
+
+	if !head.Empty() {
+		headTs, err := s.reader.GetTipSet(head)
+		if err != nil {
+			return nil, err
+		}
+		headHeight, err := headTs.Height()
+		if err != nil {
+			return nil, err
+		}
+		if epoch > headHeight {
+			return nil, errors.Wrapf(ErrBeaconRoundAfterHead, "round %d maps to epoch %d, head is at %d", round, epoch, headHeight)
+		}
+	}
+	return s.Sample(ctx, head, epoch)
 }
 
 // Finds the the highest tipset with height <= the requested epoch, by traversing backward from start.
 func (s *Sampler) findTipsetAtEpoch(ctx context.Context, start block.TipSet, epoch abi.ChainEpoch) (ts block.TipSet, err error) {
+	err = WalkAncestors(ctx, s.reader, start, func(visited block.TipSet) (bool, error) {
+		ts = visited
+		h, err := visited.Height()
+		if err != nil {
+			return false, err
+		}
+		return h <= epoch, nil
+	})
+	// If the walk completed, ts is the genesis tipset.
+	return
+}
+
+// Finds the highest tipset with height <= the requested epoch like findTipsetAtEpoch, failing with
+// ErrMissingAncestor if a tipset between start and the target can not be loaded.
+func (s *Sampler) findTipsetAtEpochStrict(ctx context.Context, start block.TipSet, epoch abi.ChainEpoch) (block.TipSet, error) {
 	iterator := IterAncestors(ctx, s.reader, start)
-	var h abi.ChainEpoch
-	for ; !iterator.Complete(); err = iterator.Next() {
+	for {
+		ts := iterator.Value()
+		h, err := ts.Height()
 		if err != nil {
-			return
+			return block.UndefTipSet, err
 		}
-		ts = iterator.Value()
-		h, err = ts.Height()
+		if h <= epoch || ts.At(0).IsGenesis() {
+			return ts, nil
+		}
+		parents, err := ts.Parents()
 		if err != nil {
-			return
+			return block.UndefTipSet, err
+		}
+		if err := iterator.Next(); err != nil {
+			if ctx.Err() != nil {
+				return block.UndefTipSet, err
+			}
+			return block.UndefTipSet, errors.Wrapf(ErrMissingAncestor, "failed to load tipset %s: %s", parents, err)
 		}
-		if h <= epoch {
-			break
+		if iterator.Complete() {
+			// Walked past the genesis tipset, which is the lowest ancestor there is.
+			return ts, nil
 		}
 	}
-	// If the iterator completed, ts is the genesis tipset.
-	return
+}
+
+type sampleKey struct {
+	head  string
+	epoch abi.ChainEpoch
+}
+
+// sampleCache is a bounded map of drawn seeds. When full it is emptied
+// rather than tracking recency, since seeds are cheap to redraw.
+type sampleCache struct {
+	mu    sync.Mutex
+	size  int
+	seeds map[sampleKey]crypto.RandomSeed
+}
+
+func newSampleCache(size int) *sampleCache {
+	return &sampleCache{size: size, seeds: make(map[sampleKey]crypto.RandomSeed)}
+}
+
+func (c *sampleCache) get(head block.TipSetKey, epoch abi.ChainEpoch) (crypto.RandomSeed, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	seed, ok := c.seeds[sampleKey{head.String(), epoch}]
+	return seed, ok
+}
+
+func (c *sampleCache) put(head block.TipSetKey, epoch abi.ChainEpoch, seed crypto.RandomSeed) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size <= 0 {
+		return
+	}
+	if len(c.seeds) >= c.size {
+		c.seeds = make(map[sampleKey]crypto.RandomSeed)
+	}
+	c.seeds[sampleKey{head.String(), epoch}] = seed
 }
@@ -0,0 +1,246 @@
+package chain_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/minio/blake2b-simd"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+// stubDrandSchedule maps rounds to epochs from a fixed table.
+type stubDrandSchedule map[uint64]abi.ChainEpoch
+
+func (s stubDrandSchedule) EpochForRound(round uint64) abi.ChainEpoch {
+	return s[round]
+}
+
+func TestSampleBeacon(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genesis := builder.NewGenesis()
+	head := builder.AppendManyOn(3, genesis)
+
+	schedule := stubDrandSchedule{10: 1, 11: 2, 20: 7}
+	sampler := chain.NewSamplerWithSchedule(builder, schedule)
+
+	t.Run("samples the scheduled epoch", func(t *testing.T) {
+		for round, epoch := range map[uint64]abi.ChainEpoch{10: 1, 11: 2} {
+			expected, err := sampler.Sample(ctx, head.Key(), epoch)
+			require.NoError(t, err)
+
+			seed, err := sampler.SampleBeacon(ctx, head.Key(), round)
+			require.NoError(t, err)
+			assert.Equal(t, expected, seed)
+		}
+
+		first, err := sampler.SampleBeacon(ctx, head.Key(), 10)
+		require.NoError(t, err)
+		second, err := sampler.SampleBeacon(ctx, head.Key(), 11)
+		require.NoError(t, err)
+		assert.NotEqual(t, first, second)
+	})
+
+	t.Run("rounds after the head are rejected", func(t *testing.T) {
+		_, err := sampler.SampleBeacon(ctx, head.Key(), 20)
+		require.Error(t, err)
+		assert.Equal(t, chain.ErrBeaconRoundAfterHead, errors.Cause(err))
+	})
+
+	t.Run("sampler without schedule errors", func(t *testing.T) {
+		_, err := chain.NewSampler(builder).SampleBeacon(ctx, head.Key(), 10)
+		assert.Error(t, err)
+	})
+
+	t.Run("genesis sampling uses empty head", func(t *testing.T) {
+		expected, err := sampler.Sample(ctx, block.NewTipSetKey(), 1)
+		require.NoError(t, err)
+		seed, err := sampler.SampleBeacon(ctx, block.NewTipSetKey(), 10)
+		require.NoError(t, err)
+		assert.Equal(t, expected, seed)
+	})
+}
+
+func TestMinTicketAt(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genesis := builder.NewGenesis()
+	wide := builder.AppendOn(genesis, 3)
+	// Epochs 2 and 3 are null.
+	head := builder.BuildOneOn(wide, func(b *chain.BlockBuilder) {
+		b.IncHeight(2)
+	})
+	sampler := chain.NewSampler(builder)
+
+	requireMinTicket := func(ts block.TipSet) block.Ticket {
+		ticket, err := ts.MinTicket()
+		require.NoError(t, err)
+		return ticket
+	}
+
+	for epoch, expected := range map[abi.ChainEpoch]block.TipSet{0: genesis, 1: wide, 2: wide, 3: wide, 4: head, 9: head} {
+		ticket, err := sampler.MinTicketAt(ctx, head.Key(), epoch)
+		require.NoError(t, err)
+		assert.Equal(t, requireMinTicket(expected), ticket, "epoch %d", epoch)
+	}
+
+	t.Run("ticket recomputes the seed", func(t *testing.T) {
+		ticket, err := sampler.MinTicketAt(ctx, head.Key(), 2)
+		require.NoError(t, err)
+		buf := bytes.Buffer{}
+		buf.Write(ticket.VRFProof)
+		require.NoError(t, binary.Write(&buf, binary.BigEndian, abi.ChainEpoch(2)))
+		hash := blake2b.Sum256(buf.Bytes())
+
+		seed, err := sampler.Sample(ctx, head.Key(), 2)
+		require.NoError(t, err)
+		assert.Equal(t, crypto.RandomSeed(hash[:]), seed)
+	})
+
+	t.Run("empty head", func(t *testing.T) {
+		ticket, err := sampler.MinTicketAt(ctx, block.NewTipSetKey(), 1)
+		require.NoError(t, err)
+		assert.Empty(t, ticket.VRFProof)
+	})
+}
+
+func TestWindowPoStRandomness(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	head := builder.AppendManyOn(5, builder.NewGenesis())
+	sampler := chain.NewSampler(builder)
+	addrGetter := vmaddr.NewForTestGetter()
+	minerA, minerB := addrGetter(), addrGetter()
+
+	requireRandomness := func(deadline abi.ChainEpoch, miner address.Address) crypto.RandomSeed {
+		rand, err := sampler.WindowPoStRandomness(ctx, head.Key(), deadline, miner)
+		require.NoError(t, err)
+		return rand
+	}
+
+	t.Run("deterministic", func(t *testing.T) {
+		assert.Equal(t, requireRandomness(3, minerA), requireRandomness(3, minerA))
+		other, err := chain.NewSampler(builder).WindowPoStRandomness(ctx, head.Key(), 3, minerA)
+		require.NoError(t, err)
+		assert.Equal(t, requireRandomness(3, minerA), other)
+	})
+
+	t.Run("distinct from election seed", func(t *testing.T) {
+		seed, err := sampler.Sample(ctx, head.Key(), 3)
+		require.NoError(t, err)
+		assert.NotEqual(t, seed, requireRandomness(3, minerA))
+	})
+
+	t.Run("distinct per miner and deadline", func(t *testing.T) {
+		assert.NotEqual(t, requireRandomness(3, minerA), requireRandomness(3, minerB))
+		assert.NotEqual(t, requireRandomness(3, minerA), requireRandomness(4, minerA))
+	})
+}
+
+// countingTipSetProvider counts the tipsets loaded through it.
+type countingTipSetProvider struct {
+	chain.TipSetProvider
+	loads int
+}
+
+func (p *countingTipSetProvider) GetTipSet(key block.TipSetKey) (block.TipSet, error) {
+	p.loads++
+	return p.TipSetProvider.GetTipSet(key)
+}
+
+func TestSamplerWarm(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genesis := builder.NewGenesis()
+	head := builder.AppendManyOn(5, genesis)
+
+	t.Run("warmed epochs hit the cache", func(t *testing.T) {
+		provider := &countingTipSetProvider{TipSetProvider: builder}
+		sampler := chain.NewCachingSampler(provider, chain.DefaultSampleCacheSize)
+		require.NoError(t, sampler.Warm(ctx, head.Key(), 2, 4))
+
+		loaded := provider.loads
+		for epoch := abi.ChainEpoch(2); epoch <= 4; epoch++ {
+			expected, err := chain.NewSampler(builder).Sample(ctx, head.Key(), epoch)
+			require.NoError(t, err)
+
+			seed, err := sampler.Sample(ctx, head.Key(), epoch)
+			require.NoError(t, err)
+			assert.Equal(t, expected, seed)
+		}
+		assert.Equal(t, loaded, provider.loads)
+	})
+
+	t.Run("uncached sampler is not warmed", func(t *testing.T) {
+		provider := &countingTipSetProvider{TipSetProvider: builder}
+		require.NoError(t, chain.NewSampler(provider).Warm(ctx, head.Key(), 2, 4))
+		assert.Equal(t, 0, provider.loads)
+	})
+}
+
+// hidingTipSetProvider fails to load a single tipset.
+type hidingTipSetProvider struct {
+	chain.TipSetProvider
+	hidden block.TipSetKey
+}
+
+func (p *hidingTipSetProvider) GetTipSet(key block.TipSetKey) (block.TipSet, error) {
+	if key.Equals(p.hidden) {
+		return block.UndefTipSet, errors.New("tipset not found")
+	}
+	return p.TipSetProvider.GetTipSet(key)
+}
+
+func TestSampleStrict(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genesis := builder.NewGenesis()
+	link1 := builder.AppendOn(genesis, 1)
+	link2 := builder.AppendOn(link1, 1)
+	head := builder.AppendManyOn(3, link2)
+
+	t.Run("complete chain", func(t *testing.T) {
+		sampler := chain.NewSampler(builder)
+		for _, epoch := range []abi.ChainEpoch{0, 2, 5} {
+			expected, err := sampler.Sample(ctx, head.Key(), epoch)
+			require.NoError(t, err)
+			seed, err := sampler.SampleStrict(ctx, head.Key(), epoch)
+			require.NoError(t, err)
+			assert.Equal(t, expected, seed)
+		}
+	})
+
+	t.Run("missing ancestor", func(t *testing.T) {
+		sampler := chain.NewSampler(&hidingTipSetProvider{TipSetProvider: builder, hidden: link1.Key()})
+
+		// Sampling above the gap still succeeds.
+		_, err := sampler.SampleStrict(ctx, head.Key(), 3)
+		require.NoError(t, err)
+
+		_, err = sampler.SampleStrict(ctx, head.Key(), 0)
+		require.Error(t, err)
+		assert.Equal(t, chain.ErrMissingAncestor, errors.Cause(err))
+	})
+}
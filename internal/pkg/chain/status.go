@@ -3,33 +3,57 @@ package chain
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	logging "github.com/ipfs/go-log"
 )
 
 var logChainStatus = logging.Logger("chain/status")
 
+// DefaultReorgAlertThreshold is the reorg depth, in epochs, above which a
+// StatusReporter raises a deep reorg alert.
+const DefaultReorgAlertThreshold = abi.ChainEpoch(5)
+
 // Reporter defines an interface to updating and reporting the status of the blockchain.
 type Reporter interface {
 	UpdateStatus(...StatusUpdates)
 	Status() Status
 }
 
+// ReorgRecorder is implemented by reporters which track the depth of reorgs.
+type ReorgRecorder interface {
+	RecordReorg(old, new, commonAncestor block.TipSet) (abi.ChainEpoch, error)
+}
+
 // StatusReporter implements the Reporter interface.
 type StatusReporter struct {
 	statusMu sync.Mutex
 	status   *Status
+	clock    clock.Clock
+	// lastHeadUpdate is when the validated head last changed, or when the
+	// reporter was created if it has not.
+	lastHeadUpdate time.Time
+
+	reorgMu        sync.Mutex
+	lastReorgDepth abi.ChainEpoch
+	reorgThreshold abi.ChainEpoch
+	onDeepReorg    func(depth abi.ChainEpoch)
 }
 
 // UpdateStatus updates the status heald by StatusReporter.
 func (sr *StatusReporter) UpdateStatus(update ...StatusUpdates) {
 	sr.statusMu.Lock()
 	defer sr.statusMu.Unlock()
+	prevHead := sr.status.ValidatedHead
 	for _, u := range update {
 		u(sr.status)
 	}
+	if !sr.status.ValidatedHead.Equals(prevHead) {
+		sr.lastHeadUpdate = sr.clock.Now()
+	}
 	logChainStatus.Debugf("syncing status: %s", sr.status.String())
 }
 
@@ -38,10 +62,75 @@ func (sr *StatusReporter) Status() Status {
 	return *sr.status
 }
 
+// LastHeadUpdate returns the time the validated head last changed, or the
+// time the reporter was created if it has not.
+func (sr *StatusReporter) LastHeadUpdate() time.Time {
+	sr.statusMu.Lock()
+	defer sr.statusMu.Unlock()
+	return sr.lastHeadUpdate
+}
+
+// IsStalled returns true if the validated head has not changed for longer
+// than `threshold`.
+func (sr *StatusReporter) IsStalled(threshold time.Duration) bool {
+	return sr.clock.Since(sr.LastHeadUpdate()) > threshold
+}
+
+// SetReorgAlert installs a callback fired whenever a recorded reorg drops
+// more than `threshold` epochs from the old head. A nil callback disables
+// the alert.
+func (sr *StatusReporter) SetReorgAlert(threshold abi.ChainEpoch, onDeepReorg func(depth abi.ChainEpoch)) {
+	sr.reorgMu.Lock()
+	defer sr.reorgMu.Unlock()
+	sr.reorgThreshold = threshold
+	sr.onDeepReorg = onDeepReorg
+}
+
+// RecordReorg records the depth of a reorg from `old` to `new`, measured as
+// the number of epochs dropped between the common ancestor and the old head.
+// It returns the recorded depth.
+func (sr *StatusReporter) RecordReorg(old, new, commonAncestor block.TipSet) (abi.ChainEpoch, error) {
+	dropped, _, err := ReorgDiff(old, new, commonAncestor)
+	if err != nil {
+		return 0, err
+	}
+
+	sr.reorgMu.Lock()
+	sr.lastReorgDepth = dropped
+	alert := sr.onDeepReorg
+	deep := dropped > sr.reorgThreshold
+	sr.reorgMu.Unlock()
+
+	if deep {
+		logChainStatus.Warnf("deep reorg dropped %d epochs from head %s", dropped, old.String())
+		if alert != nil {
+			alert(dropped)
+		}
+	}
+	return dropped, nil
+}
+
+// LastReorgDepth returns the depth of the most recently recorded reorg, or
+// zero if none has been recorded.
+func (sr *StatusReporter) LastReorgDepth() abi.ChainEpoch {
+	sr.reorgMu.Lock()
+	defer sr.reorgMu.Unlock()
+	return sr.lastReorgDepth
+}
+
 // NewStatusReporter initializes a new StatusReporter.
 func NewStatusReporter() *StatusReporter {
+	return NewStatusReporterWithClock(clock.NewSystemClock())
+}
+
+// NewStatusReporterWithClock initializes a new StatusReporter timing head
+// updates with `clk`.
+func NewStatusReporterWithClock(clk clock.Clock) *StatusReporter {
 	return &StatusReporter{
-		status: newDefaultChainStatus(),
+		status:         newDefaultChainStatus(),
+		clock:          clk,
+		lastHeadUpdate: clk.Now(),
+		reorgThreshold: DefaultReorgAlertThreshold,
 	}
 }
 
@@ -1,14 +1,22 @@
 package chain
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	cbor "github.com/ipfs/go-ipld-cbor"
 	"github.com/stretchr/testify/assert"
-	//"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/require"
 
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
 )
 
 func TestStatus(t *testing.T) {
@@ -45,3 +53,98 @@ func TestStatus(t *testing.T) {
 		fetchHead(t3), fetchHeight(789))
 	assert.Equal(t, expStatus, sr.Status())
 }
+
+func TestStatusReorgDepth(t *testing.T) {
+	tf.UnitTest(t)
+
+	builder := NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+
+	// genesis -> link1 -> link2 -> ... -> link6
+	//                  \-> fork1 -> fork2
+	link1 := builder.AppendOn(genTS, 1)
+	link2 := builder.AppendOn(link1, 1)
+	link6 := builder.AppendManyOn(4, link2)
+	fork1 := builder.AppendOn(link1, 2)
+	fork2 := builder.AppendOn(fork1, 1)
+
+	var alerts []abi.ChainEpoch
+	sr := NewStatusReporter()
+	sr.SetReorgAlert(3, func(depth abi.ChainEpoch) {
+		alerts = append(alerts, depth)
+	})
+	assert.Equal(t, abi.ChainEpoch(0), sr.LastReorgDepth())
+
+	t.Run("shallow reorg", func(t *testing.T) {
+		depth, err := sr.RecordReorg(link2, fork2, link1)
+		require.NoError(t, err)
+		assert.Equal(t, abi.ChainEpoch(1), depth)
+		assert.Equal(t, abi.ChainEpoch(1), sr.LastReorgDepth())
+		assert.Empty(t, alerts)
+	})
+
+	t.Run("deep reorg fires alert", func(t *testing.T) {
+		depth, err := sr.RecordReorg(link6, fork2, link1)
+		require.NoError(t, err)
+		assert.Equal(t, abi.ChainEpoch(5), depth)
+		assert.Equal(t, abi.ChainEpoch(5), sr.LastReorgDepth())
+		assert.Equal(t, []abi.ChainEpoch{5}, alerts)
+	})
+
+	t.Run("invalid common ancestor", func(t *testing.T) {
+		_, err := sr.RecordReorg(link1, fork2, link6)
+		assert.Error(t, err)
+		assert.Equal(t, abi.ChainEpoch(5), sr.LastReorgDepth())
+	})
+}
+
+// manualClock is a clock whose time only moves when advanced.
+type manualClock struct {
+	clock.Clock
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	return c.now
+}
+
+func (c *manualClock) Since(t time.Time) time.Duration {
+	return c.now.Sub(t)
+}
+
+func TestStatusReporterStalled(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	clk := &manualClock{Clock: clock.NewSystemClock(), now: time.Unix(1234567890, 0)}
+	sr := NewStatusReporterWithClock(clk)
+	cs := NewStore(repo.NewInMemoryRepo().Datastore(), cbor.NewMemCborStore(), state.NewTreeLoader(), sr, genTS.At(0).Cid())
+
+	link1 := builder.AppendOn(genTS, 1)
+	for _, ts := range []block.TipSet{genTS, link1} {
+		require.NoError(t, cs.PutTipSetMetadata(ctx, &TipSetMetadata{
+			TipSet:          ts,
+			TipSetStateRoot: ts.At(0).StateRoot.Cid,
+			TipSetReceipts:  types.EmptyReceiptsCID,
+		}))
+	}
+	threshold := time.Minute
+
+	require.NoError(t, cs.SetHead(ctx, genTS))
+	assert.Equal(t, clk.Now(), sr.LastHeadUpdate())
+	assert.False(t, sr.IsStalled(threshold))
+
+	// The head advances before the threshold passes.
+	clk.now = clk.now.Add(threshold / 2)
+	require.NoError(t, cs.SetHead(ctx, link1))
+	assert.Equal(t, clk.Now(), sr.LastHeadUpdate())
+	clk.now = clk.now.Add(threshold / 2)
+	assert.False(t, sr.IsStalled(threshold))
+
+	// Setting the same head again is not an update.
+	require.NoError(t, cs.SetHead(ctx, link1))
+	clk.now = clk.now.Add(threshold)
+	assert.True(t, sr.IsStalled(threshold))
+}
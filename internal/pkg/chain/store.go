@@ -2,6 +2,7 @@ package chain
 
 import (
 	"context"
+	"fmt"
 	"runtime/debug"
 	"sync"
 
@@ -98,6 +99,25 @@ type Store struct {
 
 	// Reporter is used by the store to update the current status of the chain.
 	reporter Reporter
+
+	// finality is the deepest reorg SetHead accepts, zero for no limit.
+	// Protected by mu.
+	finality abi.ChainEpoch
+
+	// inclusions, if set, follows the head to index included messages.
+	// Protected by mu.
+	inclusions *MessageInclusionIndex
+}
+
+// ReorgTooDeepError is returned by SetHead when the new head would drop more
+// epochs of the current chain than the finality depth allows.
+type ReorgTooDeepError struct {
+	Depth    abi.ChainEpoch
+	Finality abi.ChainEpoch
+}
+
+func (e *ReorgTooDeepError) Error() string {
+	return fmt.Sprintf("reorg of depth %d exceeds finality depth %d", e.Depth, e.Finality)
 }
 
 // NewStore constructs a new default store.
@@ -150,27 +170,30 @@ func (store *Store) Load(ctx context.Context) (err error) {
 	logStatusEvery := startHeight / 10
 
 	var genesii block.TipSet
+	childHeight := startHeight + 1
 	// Provide tipsets directly from the block store, not from the tipset index which is
 	// being rebuilt by this traversal.
 	tipsetProvider := TipSetProviderFromBlocks(ctx, store.stateAndBlockSource)
-	for iterator := IterAncestors(ctx, tipsetProvider, headTs); !iterator.Complete(); err = iterator.Next() {
+	iterator := IterAncestors(ctx, tipsetProvider, headTs)
+	for !iterator.Complete() {
+		ts := iterator.Value()
+		height, err := ts.Height()
 		if err != nil {
 			return err
 		}
-
-		height, err := iterator.Value().Height()
-		if err != nil {
-			return err
+		// Heights may skip null blocks but must decrease towards genesis.
+		if height >= childHeight {
+			return errors.Errorf("tipset %s at height %d is not below its child at height %d", ts.Key(), height, childHeight)
 		}
 		if logStatusEvery != 0 && (height%logStatusEvery) == 0 {
-			logStore.Infof("load tipset: %s, height: %v", iterator.Value().String(), height)
+			logStore.Infof("load tipset: %s, height: %v", ts.String(), height)
 		}
-		stateRoot, receipts, err := store.loadStateRootAndReceipts(iterator.Value())
+		stateRoot, receipts, err := store.loadStateRootAndReceipts(ts)
 		if err != nil {
 			return err
 		}
 		err = store.PutTipSetMetadata(ctx, &TipSetMetadata{
-			TipSet:          iterator.Value(),
+			TipSet:          ts,
 			TipSetStateRoot: stateRoot,
 			TipSetReceipts:  receipts,
 		})
@@ -178,9 +201,17 @@ func (store *Store) Load(ctx context.Context) (err error) {
 			return err
 		}
 
-		genesii = iterator.Value()
+		genesii = ts
+		childHeight = height
+		if err := iterator.Next(); err != nil {
+			parents, _ := ts.Parents()
+			return errors.Wrapf(err, "chain has a gap below height %d, missing tipset %s", height, parents)
+		}
 	}
 	// Check genesis here.
+	if genesisHeight, _ := genesii.Height(); genesisHeight != 0 {
+		return errors.Errorf("chain has a gap, tipset %s at height %d has no parent", genesii.Key(), genesisHeight)
+	}
 	if genesii.Len() != 1 {
 		return errors.Errorf("load terminated with tipset of %d blocks, expected genesis with exactly 1", genesii.Len())
 	}
@@ -252,6 +283,155 @@ func (store *Store) GetTipSet(key block.TipSetKey) (block.TipSet, error) {
 	return store.tipIndex.GetTipSet(key)
 }
 
+// GetTipSets returns the tipsets identified by `keys`. Results and errors are
+// positional: the i-th tipset and error correspond to the i-th key, so a
+// single missing tipset does not fail the whole batch.
+func (store *Store) GetTipSets(keys []block.TipSetKey) ([]block.TipSet, []error) {
+	tipsets := make([]block.TipSet, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		tipsets[i], errs[i] = store.tipIndex.GetTipSet(key)
+	}
+	return tipsets, errs
+}
+
+// IterForward returns an iterator over the tipsets from `from` up to and
+// including `to` in ascending height order, holding at most
+// DefaultForwardWindow tipsets at once. See IterForward.
+func (store *Store) IterForward(ctx context.Context, from, to block.TipSetKey) (*TipsetIterator, error) {
+	return IterForward(ctx, store, from, to, DefaultForwardWindow)
+}
+
+// CommonAncestor returns the most recent tipset that is an ancestor of (or
+// equal to) both the tipsets identified by `a` and `b`, i.e. their fork point.
+// If one tipset is an ancestor of the other, that tipset is returned.
+func (store *Store) CommonAncestor(ctx context.Context, a, b block.TipSetKey) (block.TipSet, error) {
+	aTs, err := store.GetTipSet(a)
+	if err != nil {
+		return block.UndefTipSet, err
+	}
+	bTs, err := store.GetTipSet(b)
+	if err != nil {
+		return block.UndefTipSet, err
+	}
+
+	aIter := IterAncestors(ctx, store, aTs)
+	bIter := IterAncestors(ctx, store, bTs)
+	for !aIter.Complete() && !bIter.Complete() {
+		if aIter.Value().Equals(bIter.Value()) {
+			return aIter.Value(), nil
+		}
+
+		aHeight, err := aIter.Value().Height()
+		if err != nil {
+			return block.UndefTipSet, err
+		}
+		bHeight, err := bIter.Value().Height()
+		if err != nil {
+			return block.UndefTipSet, err
+		}
+
+		// Step back along the higher branch, or both when they are level.
+		if aHeight >= bHeight {
+			if err := aIter.Next(); err != nil {
+				return block.UndefTipSet, err
+			}
+		}
+		if bHeight >= aHeight {
+			if err := bIter.Next(); err != nil {
+				return block.UndefTipSet, err
+			}
+		}
+	}
+	return block.UndefTipSet, errors.Errorf("tipsets %s and %s share no common ancestor", a, b)
+}
+
+// RecentTipSets returns the head and up to `n`-1 of its ancestors, in
+// descending height order. Fewer than `n` tipsets are returned if the chain
+// is shorter.
+func (store *Store) RecentTipSets(ctx context.Context, n int) ([]block.TipSet, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	head, err := store.GetTipSet(store.GetHead())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load head")
+	}
+	var recent []block.TipSet
+	err = WalkAncestors(ctx, store, head, func(ts block.TipSet) (bool, error) {
+		recent = append(recent, ts)
+		return len(recent) >= n, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recent, nil
+}
+
+// TipSetContaining returns the canonical tipset containing the block with cid
+// `blockCid`: the largest indexed tipset with the block's parents and height
+// that includes it, ties broken by the lowest key.
+func (store *Store) TipSetContaining(ctx context.Context, blockCid cid.Cid) (block.TipSet, error) {
+	blk, err := store.stateAndBlockSource.GetBlock(ctx, blockCid)
+	if err != nil {
+		return block.UndefTipSet, err
+	}
+	candidates, err := store.tipIndex.GetByParentsAndHeight(blk.Parents, blk.Height)
+	if err != nil {
+		return block.UndefTipSet, errors.Wrapf(err, "no tipsets indexed at height %d with parents %s", blk.Height, blk.Parents)
+	}
+
+	best := block.UndefTipSet
+	for _, tsm := range candidates {
+		ts := tsm.TipSet
+		if !ts.Key().Has(blockCid) {
+			continue
+		}
+		if !best.Defined() || ts.Len() > best.Len() ||
+			(ts.Len() == best.Len() && ts.Key().String() < best.Key().String()) {
+			best = ts
+		}
+	}
+	if !best.Defined() {
+		return block.UndefTipSet, errors.Errorf("no indexed tipset contains block %s", blockCid)
+	}
+	return best, nil
+}
+
+// CanonicalTipSetAtBlockHeight returns the tipset on the canonical chain,
+// the head and its ancestors, at the height of the block with cid `blockCid`.
+// This is not the block's own tipset if the block was orphaned. It errors if
+// the canonical chain has no tipset at that height.
+func (store *Store) CanonicalTipSetAtBlockHeight(ctx context.Context, blockCid cid.Cid) (block.TipSet, error) {
+	blk, err := store.stateAndBlockSource.GetBlock(ctx, blockCid)
+	if err != nil {
+		return block.UndefTipSet, err
+	}
+	head, err := store.GetTipSet(store.GetHead())
+	if err != nil {
+		return block.UndefTipSet, errors.Wrap(err, "failed to load head")
+	}
+
+	found := block.UndefTipSet
+	err = WalkAncestors(ctx, store, head, func(ts block.TipSet) (bool, error) {
+		h, err := ts.Height()
+		if err != nil {
+			return false, err
+		}
+		if h == blk.Height {
+			found = ts
+		}
+		return h <= blk.Height, nil
+	})
+	if err != nil {
+		return block.UndefTipSet, err
+	}
+	if !found.Defined() {
+		return block.UndefTipSet, errors.Errorf("no canonical tipset at height %d", blk.Height)
+	}
+	return found, nil
+}
+
 // GetTipSetState returns the aggregate state of the tipset identified by `key`.
 func (store *Store) GetTipSetState(ctx context.Context, key block.TipSetKey) (state.Tree, error) {
 	stateCid, err := store.tipIndex.GetTipSetStateRoot(key)
@@ -322,6 +502,14 @@ func (store *Store) SetHead(ctx context.Context, ts block.TipSet) error {
 		logStore.Error(debug.Stack())
 	}
 
+	prevHead := store.GetHead()
+	old, common, err := store.reorgBase(ctx, prevHead, ts)
+	if err != nil {
+		return err
+	}
+	if err := store.checkFinality(old, ts, common); err != nil {
+		return err
+	}
 	noop, err := store.setHeadPersistent(ctx, ts)
 	if err != nil {
 		return err
@@ -330,6 +518,8 @@ func (store *Store) SetHead(ctx context.Context, ts block.TipSet) error {
 		// exit without sending head events if head was already set to ts
 		return nil
 	}
+	store.recordReorg(old, ts, common)
+	store.updateInclusions(ctx, ts)
 
 	h, err := ts.Height()
 	if err != nil {
@@ -342,6 +532,104 @@ func (store *Store) SetHead(ctx context.Context, ts block.TipSet) error {
 	return nil
 }
 
+// SetFinalityDepth makes SetHead refuse new heads whose common ancestor with
+// the current head is more than `depth` epochs below it. Zero removes the limit.
+func (store *Store) SetFinalityDepth(depth abi.ChainEpoch) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.finality = depth
+}
+
+// SetMessageInclusionIndex makes SetHead keep `idx` following the head.
+func (store *Store) SetMessageInclusionIndex(idx *MessageInclusionIndex) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.inclusions = idx
+}
+
+// updateInclusions moves the message inclusion index, if any, to the new head.
+// This loads at most a batch of tipsets, see MessageInclusionIndex. The index
+// is left unchanged if it fails to load them.
+func (store *Store) updateInclusions(ctx context.Context, head block.TipSet) {
+	store.mu.RLock()
+	inclusions := store.inclusions
+	store.mu.RUnlock()
+	if inclusions == nil {
+		return
+	}
+	if err := inclusions.SetHead(ctx, head); err != nil {
+		logStore.Warnf("failed to index messages of new head %s: %s", head.Key(), err)
+	}
+}
+
+// reorgBase loads the previous head and its common ancestor with newHead, for
+// the finality check and reorg reporting to share. Both are undefined if
+// neither needs them or there is no previous head.
+func (store *Store) reorgBase(ctx context.Context, prevHead block.TipSetKey, newHead block.TipSet) (block.TipSet, block.TipSet, error) {
+	store.mu.RLock()
+	finality := store.finality
+	store.mu.RUnlock()
+	_, recording := store.reporter.(ReorgRecorder)
+	if prevHead.Empty() || (finality <= 0 && !recording) {
+		return block.UndefTipSet, block.UndefTipSet, nil
+	}
+
+	fail := func(err error) (block.TipSet, block.TipSet, error) {
+		// Without a finality depth a failure only costs the reorg report.
+		if finality > 0 {
+			return block.UndefTipSet, block.UndefTipSet, err
+		}
+		logStore.Warnf("skipping reorg check: %s", err)
+		return block.UndefTipSet, block.UndefTipSet, nil
+	}
+	old, err := store.GetTipSet(prevHead)
+	if err != nil {
+		return fail(errors.Wrapf(err, "failed to load previous head %s", prevHead))
+	}
+	common, err := store.CommonAncestor(ctx, prevHead, newHead.Key())
+	if err != nil {
+		return fail(errors.Wrap(err, "failed to find common ancestor with previous head"))
+	}
+	return old, common, nil
+}
+
+// checkFinality returns a ReorgTooDeepError if moving the head from old to
+// newHead, with common ancestor common, reorgs deeper than the finality depth.
+func (store *Store) checkFinality(old, newHead, common block.TipSet) error {
+	store.mu.RLock()
+	finality := store.finality
+	store.mu.RUnlock()
+	if finality <= 0 || !old.Defined() {
+		return nil
+	}
+	if !IsReorg(old, newHead, common) {
+		return nil
+	}
+	dropped, _, err := ReorgDiff(old, newHead, common)
+	if err != nil {
+		return err
+	}
+	if dropped > finality {
+		return &ReorgTooDeepError{Depth: dropped, Finality: finality}
+	}
+	return nil
+}
+
+// recordReorg reports the depth of the reorg from old to newHead, if any, to
+// reporters which track reorgs.
+func (store *Store) recordReorg(old, newHead, common block.TipSet) {
+	recorder, ok := store.reporter.(ReorgRecorder)
+	if !ok || !old.Defined() {
+		return
+	}
+	if !IsReorg(old, newHead, common) {
+		return
+	}
+	if _, err := recorder.RecordReorg(old, newHead, common); err != nil {
+		logStore.Warnf("failed to record reorg: %s", err)
+	}
+}
+
 // ReadOnlyStateStore provides a read-only IPLD store for access to chain state.
 func (store *Store) ReadOnlyStateStore() cborutil.ReadOnlyIpldStore {
 	return cborutil.ReadOnlyIpldStore{IpldStore: store.stateAndBlockSource.cborStore}
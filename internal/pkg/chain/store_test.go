@@ -2,6 +2,7 @@ package chain_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/filecoin-project/go-address"
@@ -133,6 +134,289 @@ func TestGetByKey(t *testing.T) {
 	assert.Equal(t, link4.At(0).StateRoot.Cid, got4TSSR)
 }
 
+// Tipsets can be retrieved in bulk, with errors reported per key.
+func TestGetTipSets(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	r := repo.NewInMemoryRepo()
+	cs := newChainStore(r, genTS.At(0).Cid())
+
+	link1 := builder.AppendOn(genTS, 2)
+	link2 := builder.AppendOn(link1, 3)
+	requirePutTestChain(ctx, t, cs, link1.Key(), builder, 2)
+
+	// link2 is known to the builder but was never put to the store.
+	tss, errs := cs.GetTipSets([]block.TipSetKey{link1.Key(), link2.Key(), genTS.Key()})
+	require.Len(t, tss, 3)
+	require.Len(t, errs, 3)
+
+	assert.NoError(t, errs[0])
+	assert.Equal(t, link1, tss[0])
+	assert.Error(t, errs[1])
+	assert.False(t, tss[1].Defined())
+	assert.NoError(t, errs[2])
+	assert.Equal(t, genTS, tss[2])
+}
+
+func TestCommonAncestor(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	r := repo.NewInMemoryRepo()
+	cs := newChainStore(r, genTS.At(0).Cid())
+
+	// genesis -> link1 -> forkA1 -> forkA2
+	//                  \-> forkB1
+	link1 := builder.AppendOn(genTS, 2)
+	forkA1 := builder.AppendOn(link1, 1)
+	forkA2 := builder.AppendOn(forkA1, 1)
+	forkB1 := builder.AppendOn(link1, 2)
+	requirePutTestChain(ctx, t, cs, forkA2.Key(), builder, 4)
+	requirePutTestChain(ctx, t, cs, forkB1.Key(), builder, 3)
+
+	t.Run("forked branches", func(t *testing.T) {
+		ancestor, err := cs.CommonAncestor(ctx, forkA2.Key(), forkB1.Key())
+		require.NoError(t, err)
+		assert.Equal(t, link1, ancestor)
+
+		ancestor, err = cs.CommonAncestor(ctx, forkB1.Key(), forkA2.Key())
+		require.NoError(t, err)
+		assert.Equal(t, link1, ancestor)
+	})
+
+	t.Run("one is an ancestor of the other", func(t *testing.T) {
+		ancestor, err := cs.CommonAncestor(ctx, forkA2.Key(), forkA1.Key())
+		require.NoError(t, err)
+		assert.Equal(t, forkA1, ancestor)
+
+		ancestor, err = cs.CommonAncestor(ctx, genTS.Key(), forkB1.Key())
+		require.NoError(t, err)
+		assert.Equal(t, genTS, ancestor)
+	})
+
+	t.Run("same tipset", func(t *testing.T) {
+		ancestor, err := cs.CommonAncestor(ctx, forkB1.Key(), forkB1.Key())
+		require.NoError(t, err)
+		assert.Equal(t, forkB1, ancestor)
+	})
+}
+
+func TestRecentTipSets(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	r := repo.NewInMemoryRepo()
+	cs := newChainStore(r, genTS.At(0).Cid())
+
+	// genesis -> link1 -> link2 -> link3
+	link1 := builder.AppendOn(genTS, 2)
+	link2 := builder.AppendOn(link1, 1)
+	link3 := builder.AppendOn(link2, 3)
+	requirePutTestChain(ctx, t, cs, link3.Key(), builder, 4)
+	require.NoError(t, cs.SetHead(ctx, link3))
+
+	t.Run("fewer than the chain length", func(t *testing.T) {
+		recent, err := cs.RecentTipSets(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []block.TipSet{link3, link2}, recent)
+	})
+
+	t.Run("exactly the chain length", func(t *testing.T) {
+		recent, err := cs.RecentTipSets(ctx, 4)
+		require.NoError(t, err)
+		assert.Equal(t, []block.TipSet{link3, link2, link1, genTS}, recent)
+	})
+
+	t.Run("more than the chain length", func(t *testing.T) {
+		recent, err := cs.RecentTipSets(ctx, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []block.TipSet{link3, link2, link1, genTS}, recent)
+	})
+
+	t.Run("only the head", func(t *testing.T) {
+		recent, err := cs.RecentTipSets(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []block.TipSet{link3}, recent)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		recent, err := cs.RecentTipSets(ctx, 0)
+		require.NoError(t, err)
+		assert.Empty(t, recent)
+	})
+}
+
+func TestSetHeadRecordsReorgDepth(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	r := repo.NewInMemoryRepo()
+	sr := chain.NewStatusReporter()
+	cs := chain.NewStore(r.Datastore(), cbor.NewMemCborStore(), state.NewTreeLoader(), sr, genTS.At(0).Cid())
+
+	// genesis -> link1 -> link2 -> link3
+	//                  \-> fork1
+	link1 := builder.AppendOn(genTS, 1)
+	link3 := builder.AppendManyOn(2, link1)
+	fork1 := builder.AppendOn(link1, 2)
+	requirePutTestChain(ctx, t, cs, link3.Key(), builder, 4)
+	requirePutTestChain(ctx, t, cs, fork1.Key(), builder, 3)
+
+	require.NoError(t, cs.SetHead(ctx, genTS))
+	require.NoError(t, cs.SetHead(ctx, link3))
+	assert.Equal(t, abi.ChainEpoch(0), sr.LastReorgDepth())
+
+	require.NoError(t, cs.SetHead(ctx, fork1))
+	assert.Equal(t, abi.ChainEpoch(2), sr.LastReorgDepth())
+}
+
+func TestSetHeadFinalityDepth(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	cs := newChainStore(repo.NewInMemoryRepo(), genTS.At(0).Cid())
+	cs.SetFinalityDepth(2)
+
+	// genesis -> link1 -> link2 -> link3 -> link4
+	//                  |        \-> within
+	//                  \-> beyond
+	link1 := builder.AppendOn(genTS, 1)
+	link2 := builder.AppendOn(link1, 1)
+	link4 := builder.AppendManyOn(2, link2)
+	within := builder.AppendOn(link2, 2)
+	beyond := builder.AppendOn(link1, 2)
+	requirePutTestChain(ctx, t, cs, link4.Key(), builder, 5)
+	requirePutTestChain(ctx, t, cs, within.Key(), builder, 4)
+	requirePutTestChain(ctx, t, cs, beyond.Key(), builder, 3)
+
+	require.NoError(t, cs.SetHead(ctx, genTS))
+	require.NoError(t, cs.SetHead(ctx, link4))
+
+	t.Run("reorg beyond finality rejected", func(t *testing.T) {
+		err := cs.SetHead(ctx, beyond)
+		require.Error(t, err)
+		assert.Equal(t, &chain.ReorgTooDeepError{Depth: 3, Finality: 2}, err)
+		assert.Equal(t, link4.Key(), cs.GetHead())
+	})
+
+	t.Run("reorg within finality accepted", func(t *testing.T) {
+		require.NoError(t, cs.SetHead(ctx, within))
+		assert.Equal(t, within.Key(), cs.GetHead())
+	})
+
+	t.Run("no limit when unset", func(t *testing.T) {
+		cs.SetFinalityDepth(0)
+		require.NoError(t, cs.SetHead(ctx, beyond))
+		assert.Equal(t, beyond.Key(), cs.GetHead())
+	})
+}
+
+func TestTipSetContaining(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	r := repo.NewInMemoryRepo()
+	cst := cbor.NewMemCborStore()
+	cs := chain.NewStore(r.Datastore(), cst, state.NewTreeLoader(), chain.NewStatusReporter(), genTS.At(0).Cid())
+
+	link1 := builder.AppendOn(genTS, 3)
+	link2 := builder.AppendOn(link1, 2)
+	requirePutTestChain(ctx, t, cs, link2.Key(), builder, 3)
+	for _, ts := range []block.TipSet{genTS, link1, link2} {
+		requirePutBlocksToCborStore(t, cst, ts.ToSlice()...)
+	}
+
+	// A subset of link1 is indexed too, as when blocks arrive separately.
+	partial, err := block.NewTipSet(link1.At(0), link1.At(1))
+	require.NoError(t, err)
+	require.NoError(t, cs.PutTipSetMetadata(ctx, &chain.TipSetMetadata{
+		TipSet:          partial,
+		TipSetStateRoot: partial.At(0).StateRoot.Cid,
+		TipSetReceipts:  types.EmptyReceiptsCID,
+	}))
+
+	t.Run("returns all siblings", func(t *testing.T) {
+		for i := 0; i < link1.Len(); i++ {
+			ts, err := cs.TipSetContaining(ctx, link1.At(i).Cid())
+			require.NoError(t, err)
+			assert.Equal(t, link1, ts)
+		}
+		for i := 0; i < link2.Len(); i++ {
+			ts, err := cs.TipSetContaining(ctx, link2.At(i).Cid())
+			require.NoError(t, err)
+			assert.Equal(t, link2, ts)
+		}
+	})
+
+	t.Run("genesis", func(t *testing.T) {
+		ts, err := cs.TipSetContaining(ctx, genTS.At(0).Cid())
+		require.NoError(t, err)
+		assert.Equal(t, genTS, ts)
+	})
+
+	t.Run("unknown block fails", func(t *testing.T) {
+		_, err := cs.TipSetContaining(ctx, types.CidFromString(t, "unknown"))
+		assert.Error(t, err)
+	})
+}
+
+func TestCanonicalTipSetAtBlockHeight(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	r := repo.NewInMemoryRepo()
+	cst := cbor.NewMemCborStore()
+	cs := chain.NewStore(r.Datastore(), cst, state.NewTreeLoader(), chain.NewStatusReporter(), genTS.At(0).Cid())
+
+	// genesis -> link1 -> link2 is canonical, orphan is a fork off genesis at
+	// link1's height.
+	link1 := builder.AppendOn(genTS, 2)
+	link2 := builder.AppendOn(link1, 1)
+	orphan := builder.AppendOn(genTS, 1)
+	requirePutTestChain(ctx, t, cs, link2.Key(), builder, 3)
+	requirePutTestChain(ctx, t, cs, orphan.Key(), builder, 2)
+	for _, ts := range []block.TipSet{genTS, link1, link2, orphan} {
+		requirePutBlocksToCborStore(t, cst, ts.ToSlice()...)
+	}
+	require.NoError(t, cs.SetHead(ctx, link2))
+
+	t.Run("canonical block", func(t *testing.T) {
+		ts, err := cs.CanonicalTipSetAtBlockHeight(ctx, link1.At(1).Cid())
+		require.NoError(t, err)
+		assert.Equal(t, link1, ts)
+
+		ts, err = cs.CanonicalTipSetAtBlockHeight(ctx, link2.At(0).Cid())
+		require.NoError(t, err)
+		assert.Equal(t, link2, ts)
+	})
+
+	t.Run("orphaned block", func(t *testing.T) {
+		ts, err := cs.CanonicalTipSetAtBlockHeight(ctx, orphan.At(0).Cid())
+		require.NoError(t, err)
+		assert.Equal(t, link1, ts)
+	})
+
+	t.Run("unknown block fails", func(t *testing.T) {
+		_, err := cs.CanonicalTipSetAtBlockHeight(ctx, types.CidFromString(t, "unknown"))
+		assert.Error(t, err)
+	})
+}
+
 // Tipset state is loaded correctly
 func TestGetTipSetState(t *testing.T) {
 	ctx := context.Background()
@@ -415,6 +699,36 @@ func TestLoadAndReboot(t *testing.T) {
 	assert.Equal(t, link4.Key(), rebootChain.GetHead())
 }
 
+// Load fails, naming the gap, if the chain is missing an epoch range.
+func TestLoadWithGap(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	ds := repo.NewInMemoryRepo().Datastore()
+	cst := cborutil.NewIpldStore(bstore.NewBlockstore(ds))
+
+	link1 := builder.AppendOn(genTS, 1)
+	link2 := builder.AppendOn(link1, 1)
+	link3 := builder.AppendOn(link2, 1)
+
+	// Leave out the blocks at height 2.
+	requirePutBlocksToCborStore(t, cst, genTS.ToSlice()...)
+	requirePutBlocksToCborStore(t, cst, link1.ToSlice()...)
+	requirePutBlocksToCborStore(t, cst, link3.ToSlice()...)
+
+	chainStore := chain.NewStore(ds, cst, state.NewTreeLoader(), chain.NewStatusReporter(), genTS.At(0).Cid())
+	requirePutTestChain(ctx, t, chainStore, link3.Key(), builder, 4)
+	assertSetHead(t, chainStore, link3)
+	chainStore.Stop()
+
+	rebootChain := chain.NewStore(ds, cst, state.NewTreeLoader(), chain.NewStatusReporter(), genTS.At(0).Cid())
+	err := rebootChain.Load(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf("chain has a gap below height 3, missing tipset %s", link2.Key()))
+}
+
 type tipSetGetter interface {
 	GetTipSet(block.TipSetKey) (block.TipSet, error)
 }
@@ -58,6 +58,7 @@ type Builder struct {
 var _ BlockProvider = (*Builder)(nil)
 var _ TipSetProvider = (*Builder)(nil)
 var _ MessageProvider = (*Builder)(nil)
+var _ MessageWriter = (*Builder)(nil)
 
 // NewBuilder builds a new chain faker with default fake state building.
 func NewBuilder(t *testing.T, miner address.Address) *Builder {
@@ -167,6 +168,15 @@ func (f *Builder) BuildManyOn(height int, parent block.TipSet, build func(b *Blo
 	return parent
 }
 
+// Fork builds a branch of `length` single-block tipsets off `at`, competing with
+// any chain already built on it, and returns its head. Like all blocks the
+// builder makes, the branch's blocks take the next tickets of its sequence, so
+// they differ from their siblings' unless `mutator` overrides them. `mutator`,
+// if non-nil, is invoked to modify each block before it is stored.
+func (f *Builder) Fork(at block.TipSet, length int, mutator func(*BlockBuilder)) block.TipSet {
+	return f.BuildManyOn(length, at, mutator)
+}
+
 // Build creates and returns a new tipset child of `parent`.
 // The tipset carries `width` > 0 blocks with the same height and parents, but different tickets.
 // Note: the blocks will all have the same miner, which is unrealistic and forbidden by consensus;
@@ -594,6 +604,17 @@ func (f *Builder) LoadTxMeta(ctx context.Context, metaCid cid.Cid) (types.TxMeta
 	return f.messages.LoadTxMeta(ctx, metaCid)
 }
 
+// StoreMessages stores message collections and returns a commitment.
+func (f *Builder) StoreMessages(ctx context.Context, secpMessages []*types.SignedMessage, blsMessages []*types.UnsignedMessage) (cid.Cid, error) {
+	return f.messages.StoreMessages(ctx, secpMessages, blsMessages)
+}
+
+// ComputeMessageRoot stores messages and returns the root a block including
+// them commits to.
+func (f *Builder) ComputeMessageRoot(ctx context.Context, msgs []*types.SignedMessage) (cid.Cid, error) {
+	return f.messages.ComputeMessageRoot(ctx, msgs)
+}
+
 // StoreReceipts stores message receipts and returns a commitment.
 func (f *Builder) StoreReceipts(ctx context.Context, receipts []vm.MessageReceipt) (cid.Cid, error) {
 	return f.messages.StoreReceipts(ctx, receipts)
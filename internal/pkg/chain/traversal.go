@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 )
@@ -16,7 +17,7 @@ type TipSetProvider interface {
 // IterAncestors returns an iterator over tipset ancestors, yielding first the start tipset and
 // then its parent tipsets until (and including) the genesis tipset.
 func IterAncestors(ctx context.Context, store TipSetProvider, start block.TipSet) *TipsetIterator {
-	return &TipsetIterator{ctx, store, start}
+	return &TipsetIterator{ctx: ctx, store: store, value: start}
 }
 
 // TipsetIterator is an iterator over tipsets.
@@ -24,6 +25,9 @@ type TipsetIterator struct {
 	ctx   context.Context
 	store TipSetProvider
 	value block.TipSet
+	// forward holds the remaining path of a forward iterator, nil when
+	// iterating over ancestors.
+	forward *forwardPath
 }
 
 // Value returns the iterator's current value, if not Complete().
@@ -42,6 +46,11 @@ func (it *TipsetIterator) Next() error {
 	case <-it.ctx.Done():
 		return it.ctx.Err()
 	default:
+		if it.forward != nil {
+			var err error
+			it.value, err = it.forward.next(it.ctx, it.store)
+			return err
+		}
 		parentKey, err := it.value.Parents()
 		// Parents is empty (without error) for the genesis tipset.
 		if err != nil || parentKey.Len() == 0 {
@@ -53,6 +62,129 @@ func (it *TipsetIterator) Next() error {
 	}
 }
 
+// DefaultForwardWindow is the number of tipsets held at once by forward
+// iterators over the chain store.
+const DefaultForwardWindow = 1000
+
+// IterForward returns an iterator over the tipsets from `from` up to and
+// including `to` in ascending height order. `from` must be `to` or one of its
+// ancestors. The path is found by walking back from `to` before the iterator
+// is returned. At most `window` tipsets are held at once: for longer paths
+// only the key of every window-th tipset is kept and the tipsets between are
+// reloaded a window at a time as iteration proceeds. A window of zero holds
+// the whole path.
+func IterForward(ctx context.Context, store TipSetProvider, from, to block.TipSetKey, window int) (*TipsetIterator, error) {
+	fromTs, err := store.GetTipSet(from)
+	if err != nil {
+		return nil, err
+	}
+	fromHeight, err := fromTs.Height()
+	if err != nil {
+		return nil, err
+	}
+	toTs, err := store.GetTipSet(to)
+	if err != nil {
+		return nil, err
+	}
+
+	path := &forwardPath{window: window}
+	found := false
+	count := 0
+	err = WalkAncestors(ctx, store, toTs, func(ts block.TipSet) (bool, error) {
+		height, err := ts.Height()
+		if err != nil {
+			return false, err
+		}
+		if height < fromHeight {
+			return true, nil
+		}
+		if window > 0 && count%window == 0 {
+			path.chunks = append(path.chunks, ts.Key())
+			path.buffer = nil
+		}
+		path.buffer = append(path.buffer, ts)
+		count++
+		found = ts.Key().Equals(from)
+		return found, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.Errorf("tipset %s is not an ancestor of %s", from, to)
+	}
+
+	// The lowest chunk is already loaded.
+	if len(path.chunks) > 0 {
+		path.chunks = path.chunks[:len(path.chunks)-1]
+	}
+	reverseTipSets(path.buffer)
+
+	it := &TipsetIterator{ctx: ctx, store: store, forward: path}
+	it.value, err = path.next(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+// forwardPath is the remainder of a path being iterated in ascending height order.
+type forwardPath struct {
+	window int
+	// chunks holds the keys of the highest tipsets of the windows still to
+	// be loaded, in descending height order.
+	chunks []block.TipSetKey
+	// buffer holds the loaded tipsets still to be yielded, in ascending
+	// height order.
+	buffer []block.TipSet
+}
+
+func (p *forwardPath) next(ctx context.Context, store TipSetProvider) (block.TipSet, error) {
+	if len(p.buffer) == 0 {
+		if len(p.chunks) == 0 {
+			return block.UndefTipSet, nil
+		}
+		top, err := store.GetTipSet(p.chunks[len(p.chunks)-1])
+		if err != nil {
+			return block.UndefTipSet, err
+		}
+		p.chunks = p.chunks[:len(p.chunks)-1]
+		err = WalkAncestors(ctx, store, top, func(ts block.TipSet) (bool, error) {
+			p.buffer = append(p.buffer, ts)
+			return len(p.buffer) == p.window, nil
+		})
+		if err != nil {
+			return block.UndefTipSet, err
+		}
+		reverseTipSets(p.buffer)
+	}
+	ts := p.buffer[0]
+	p.buffer = p.buffer[1:]
+	return ts, nil
+}
+
+func reverseTipSets(tipsets []block.TipSet) {
+	for i, j := 0, len(tipsets)-1; i < j; i, j = i+1, j-1 {
+		tipsets[i], tipsets[j] = tipsets[j], tipsets[i]
+	}
+}
+
+// WalkAncestors calls `visit` with `start` and then each of its ancestors in
+// turn, until `visit` returns true or an error, or the genesis tipset has been
+// visited. An error loading an ancestor is returned.
+func WalkAncestors(ctx context.Context, store TipSetProvider, start block.TipSet, visit func(block.TipSet) (stop bool, err error)) error {
+	for iterator := IterAncestors(ctx, store, start); !iterator.Complete(); {
+		stop, err := visit(iterator.Value())
+		if err != nil || stop {
+			return err
+		}
+		if err := iterator.Next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // BlockProvider provides blocks.
 type BlockProvider interface {
 	GetBlock(ctx context.Context, cid cid.Cid) (*block.Block, error)
@@ -6,9 +6,12 @@ import (
 	"testing"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
 	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
@@ -74,3 +77,91 @@ func TestIterAncestors(t *testing.T) {
 		assert.Error(t, it.Next())
 	})
 }
+
+func TestWalkAncestors(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	head := builder.AppendManyOn(5, builder.NewGenesis())
+
+	t.Run("stops where the visitor asks", func(t *testing.T) {
+		var heights []abi.ChainEpoch
+		err := chain.WalkAncestors(ctx, builder, head, func(ts block.TipSet) (bool, error) {
+			h, err := ts.Height()
+			require.NoError(t, err)
+			heights = append(heights, h)
+			return h == 3, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []abi.ChainEpoch{5, 4, 3}, heights)
+	})
+
+	t.Run("walks to genesis", func(t *testing.T) {
+		visited := 0
+		err := chain.WalkAncestors(ctx, builder, head, func(block.TipSet) (bool, error) {
+			visited++
+			return false, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 6, visited)
+	})
+
+	t.Run("visitor error stops the walk", func(t *testing.T) {
+		visited := 0
+		err := chain.WalkAncestors(ctx, builder, head, func(block.TipSet) (bool, error) {
+			visited++
+			return false, errors.New("boom")
+		})
+		assert.EqualError(t, err, "boom")
+		assert.Equal(t, 1, visited)
+	})
+}
+
+func TestIterForward(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	tipsets := []block.TipSet{builder.NewGenesis()}
+	for i := 1; i < 10; i++ {
+		tipsets = append(tipsets, builder.AppendOn(tipsets[i-1], 1))
+	}
+
+	collect := func(it *chain.TipsetIterator) []block.TipSet {
+		var got []block.TipSet
+		for ; !it.Complete(); require.NoError(t, it.Next()) {
+			got = append(got, it.Value())
+		}
+		return got
+	}
+
+	t.Run("ascending between bounds", func(t *testing.T) {
+		for _, window := range []int{0, 1, 3, 7, 100} {
+			it, err := chain.IterForward(ctx, builder, tipsets[2].Key(), tipsets[8].Key(), window)
+			require.NoError(t, err)
+			assert.Equal(t, tipsets[2:9], collect(it), "window %d", window)
+		}
+	})
+
+	t.Run("from genesis", func(t *testing.T) {
+		it, err := chain.IterForward(ctx, builder, tipsets[0].Key(), tipsets[9].Key(), 4)
+		require.NoError(t, err)
+		assert.Equal(t, tipsets, collect(it))
+	})
+
+	t.Run("single tipset", func(t *testing.T) {
+		it, err := chain.IterForward(ctx, builder, tipsets[5].Key(), tipsets[5].Key(), 2)
+		require.NoError(t, err)
+		assert.Equal(t, tipsets[5:6], collect(it))
+	})
+
+	t.Run("non-ancestor errors", func(t *testing.T) {
+		fork := builder.AppendOn(tipsets[3], 2)
+		_, err := chain.IterForward(ctx, builder, fork.Key(), tipsets[8].Key(), 0)
+		assert.Error(t, err)
+
+		_, err = chain.IterForward(ctx, builder, tipsets[8].Key(), tipsets[2].Key(), 0)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,42 @@
+package chain
+
+import (
+	"context"
+
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+)
+
+// WeightContribution computes the weight a tipset adds to the chain it extends.
+type WeightContribution func(ctx context.Context, ts block.TipSet) (fbig.Int, error)
+
+// CumulativeWeight returns the total weight of the chain ending at `head`:
+// the sum of the contributions of `head` and each of its ancestors back to
+// genesis. A tipset's ParentWeight already sums those of its ancestors, so the
+// walk stops at the first tipset claiming a non-zero parent weight, and for a
+// chain of valid headers only the contribution of `head` is computed.
+func CumulativeWeight(ctx context.Context, head block.TipSet, reader TipSetProvider, contribution WeightContribution) (fbig.Int, error) {
+	total := fbig.Zero()
+	err := WalkAncestors(ctx, reader, head, func(ts block.TipSet) (bool, error) {
+		own, err := contribution(ctx, ts)
+		if err != nil {
+			return false, err
+		}
+		total = fbig.Add(total, own)
+
+		parentWeight, err := ts.ParentWeight()
+		if err != nil {
+			return false, err
+		}
+		if parentWeight.GreaterThan(fbig.Zero()) {
+			total = fbig.Add(total, parentWeight)
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return fbig.Zero(), err
+	}
+	return total, nil
+}
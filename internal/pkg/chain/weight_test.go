@@ -0,0 +1,64 @@
+package chain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestCumulativeWeight(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genesis := builder.NewGenesis()
+	link1 := builder.AppendOn(genesis, 2)
+	link2 := builder.AppendOn(link1, 3)
+	head := builder.AppendOn(link2, 1)
+
+	// The builder weighs each tipset by its number of blocks.
+	byWidth := func(_ context.Context, ts block.TipSet) (fbig.Int, error) {
+		return fbig.NewInt(int64(ts.Len())), nil
+	}
+
+	t.Run("matches parent weight plus own contribution", func(t *testing.T) {
+		provider := &countingTipSetProvider{TipSetProvider: builder}
+		weight, err := chain.CumulativeWeight(ctx, head, provider, byWidth)
+		require.NoError(t, err)
+
+		parentWeight, err := head.ParentWeight()
+		require.NoError(t, err)
+		assert.Equal(t, fbig.Add(parentWeight, fbig.NewInt(1)), weight)
+		assert.Equal(t, fbig.NewInt(7), weight)
+		// The parent weight stands in for the ancestors.
+		assert.Equal(t, 0, provider.loads)
+	})
+
+	t.Run("near genesis", func(t *testing.T) {
+		weight, err := chain.CumulativeWeight(ctx, link1, builder, byWidth)
+		require.NoError(t, err)
+		assert.Equal(t, fbig.NewInt(3), weight)
+
+		// genesis claims no parent weight.
+		weight, err = chain.CumulativeWeight(ctx, genesis, builder, byWidth)
+		require.NoError(t, err)
+		assert.Equal(t, fbig.NewInt(1), weight)
+	})
+
+	t.Run("contribution errors returned", func(t *testing.T) {
+		failing := func(context.Context, block.TipSet) (fbig.Int, error) {
+			return fbig.Zero(), errors.New("boom")
+		}
+		_, err := chain.CumulativeWeight(ctx, head, builder, failing)
+		assert.EqualError(t, err, "boom")
+	})
+}
@@ -19,6 +19,7 @@ import (
 type Config struct {
 	API           *APIConfig           `json:"api"`
 	Bootstrap     *BootstrapConfig     `json:"bootstrap"`
+	Chain         *ChainConfig         `json:"chain"`
 	Datastore     *DatastoreConfig     `json:"datastore"`
 	Heartbeat     *HeartbeatConfig     `json:"heartbeat"`
 	Mining        *MiningConfig        `json:"mining"`
@@ -200,19 +201,52 @@ func newDefaultTraceConfig() *TraceConfig {
 	}
 }
 
+// ChainConfig holds all configuration options related to the node's chain.
+type ChainConfig struct {
+	// FinalityDepth is the number of epochs below the head beyond which the
+	// node refuses to reorganize its chain. Zero removes the limit.
+	FinalityDepth uint64 `json:"finalityDepth"`
+}
+
+func newDefaultChainConfig() *ChainConfig {
+	return &ChainConfig{
+		FinalityDepth: 900,
+	}
+}
+
 // MessagePoolConfig holds all configuration options related to nodes message pool (mpool).
 type MessagePoolConfig struct {
-	// MaxPoolSize is the maximum number of pending messages will will allow in the message pool at any time
+	// MaxPoolSize is the maximum number of pending messages will will allow in the message pool at any time.
+	// When full, a new message evicts the lowest gas price pending message if it pays more.
 	MaxPoolSize uint `json:"maxPoolSize"`
 	// MaxNonceGap is the maximum nonce of a message past the last received on chain
 	MaxNonceGap uint64 `json:"maxNonceGap"`
+	// MinGasPrice is the lowest gas price a message must pay to enter the pool.
+	MinGasPrice types.AttoFIL `json:"minGasPrice"`
+	// FlaggedSenders are senders deprioritized as abusive, whose messages must
+	// pay at least FlaggedSenderMinGasPrice to enter the pool.
+	FlaggedSenders           []address.Address `json:"flaggedSenders"`
+	FlaggedSenderMinGasPrice types.AttoFIL     `json:"flaggedSenderMinGasPrice"`
 }
 
 func newDefaultMessagePoolConfig() *MessagePoolConfig {
 	return &MessagePoolConfig{
-		MaxPoolSize: 10000,
-		MaxNonceGap: 100,
+		MaxPoolSize:              10000,
+		MaxNonceGap:              100,
+		MinGasPrice:              types.ZeroAttoFIL,
+		FlaggedSenders:           []address.Address{},
+		FlaggedSenderMinGasPrice: types.ZeroAttoFIL,
+	}
+}
+
+// GasPriceFloor returns the global gas price floor: the lowest gas price a
+// message must pay to enter the pool, and the price estimated for the chain
+// when recent tipsets include no messages.
+func (cfg *MessagePoolConfig) GasPriceFloor() types.AttoFIL {
+	if cfg.MinGasPrice.Nil() {
+		return types.ZeroAttoFIL
 	}
+	return cfg.MinGasPrice
 }
 
 // SectorBaseConfig holds all configuration options related to the node's
@@ -235,6 +269,7 @@ func NewDefaultConfig() *Config {
 	return &Config{
 		API:           newDefaultAPIConfig(),
 		Bootstrap:     newDefaultBootstrapConfig(),
+		Chain:         newDefaultChainConfig(),
 		Datastore:     newDefaultDatastoreConfig(),
 		Swarm:         newDefaultSwarmConfig(),
 		Mining:        newDefaultMiningConfig(),
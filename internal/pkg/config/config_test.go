@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 )
 
 func TestDefaults(t *testing.T) {
@@ -62,6 +63,9 @@ func TestWriteFile(t *testing.T) {
 		"minPeerThreshold": 0,
 		"period": "1m"
 	},
+	"chain": {
+		"finalityDepth": 900
+	},
 	"datastore": {
 		"type": "badgerds",
 		"path": "badger"
@@ -79,7 +83,10 @@ func TestWriteFile(t *testing.T) {
 	},
 	"mpool": {
 		"maxPoolSize": 10000,
-		"maxNonceGap": 100
+		"maxNonceGap": 100,
+		"minGasPrice": "0",
+		"flaggedSenders": [],
+		"flaggedSenderMinGasPrice": "0"
 	},
 	"observability": {
 		"metrics": {
@@ -351,6 +358,19 @@ path = "mushroom-mushroom"}`
 	})
 }
 
+func TestMessagePoolGasPriceFloor(t *testing.T) {
+	tf.UnitTest(t)
+
+	cfg := NewDefaultConfig().Mpool
+	assert.Equal(t, types.ZeroAttoFIL, cfg.GasPriceFloor())
+
+	cfg.MinGasPrice = types.NewGasPrice(3)
+	assert.Equal(t, types.NewGasPrice(3), cfg.GasPriceFloor())
+
+	cfg.MinGasPrice = types.AttoFIL{}
+	assert.Equal(t, types.ZeroAttoFIL, cfg.GasPriceFloor())
+}
+
 func createConfigFile(content string) (string, func() error, error) {
 	dir, err := ioutil.TempDir("", "config")
 	if err != nil {
@@ -0,0 +1,65 @@
+package consensus
+
+import (
+	"sync"
+
+	"github.com/ipfs/go-cid"
+)
+
+// DefaultValidatedBlockCacheSize is the number of fully validated block CIDs
+// remembered by the expected consensus protocol.
+const DefaultValidatedBlockCacheSize = 2048
+
+// ValidatedBlockCache is a bounded set of the CIDs of blocks which have
+// passed full validation. Blocks are immutable so entries never need to be
+// invalidated; once the cache is full the oldest entry is evicted.
+type ValidatedBlockCache struct {
+	mu    sync.Mutex
+	size  int
+	order []cid.Cid
+	set   map[cid.Cid]struct{}
+}
+
+// NewValidatedBlockCache returns a cache holding at most `size` CIDs.
+func NewValidatedBlockCache(size int) *ValidatedBlockCache {
+	return &ValidatedBlockCache{
+		size:  size,
+		order: make([]cid.Cid, 0, size),
+		set:   make(map[cid.Cid]struct{}, size),
+	}
+}
+
+// Has returns true iff the block identified by `c` has been fully validated.
+func (vc *ValidatedBlockCache) Has(c cid.Cid) bool {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	_, ok := vc.set[c]
+	return ok
+}
+
+// Add records the block identified by `c` as fully validated. It must only
+// be called once every check on the block has passed.
+func (vc *ValidatedBlockCache) Add(c cid.Cid) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	if vc.size <= 0 {
+		return
+	}
+	if _, ok := vc.set[c]; ok {
+		return
+	}
+	if len(vc.order) >= vc.size {
+		oldest := vc.order[0]
+		vc.order = vc.order[1:]
+		delete(vc.set, oldest)
+	}
+	vc.order = append(vc.order, c)
+	vc.set[c] = struct{}{}
+}
+
+// Len returns the number of CIDs held by the cache.
+func (vc *ValidatedBlockCache) Len() int {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	return len(vc.order)
+}
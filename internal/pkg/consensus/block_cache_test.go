@@ -0,0 +1,49 @@
+package consensus_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+func TestValidatedBlockCache(t *testing.T) {
+	tf.UnitTest(t)
+
+	c1 := types.CidFromString(t, "block1")
+	c2 := types.CidFromString(t, "block2")
+	c3 := types.CidFromString(t, "block3")
+
+	t.Run("remembers added blocks", func(t *testing.T) {
+		cache := consensus.NewValidatedBlockCache(2)
+		assert.False(t, cache.Has(c1))
+		cache.Add(c1)
+		assert.True(t, cache.Has(c1))
+		assert.False(t, cache.Has(c2))
+
+		// adding twice does not take extra room
+		cache.Add(c1)
+		assert.Equal(t, 1, cache.Len())
+	})
+
+	t.Run("evicts the oldest block when full", func(t *testing.T) {
+		cache := consensus.NewValidatedBlockCache(2)
+		cache.Add(c1)
+		cache.Add(c2)
+		cache.Add(c3)
+
+		assert.Equal(t, 2, cache.Len())
+		assert.False(t, cache.Has(c1))
+		assert.True(t, cache.Has(c2))
+		assert.True(t, cache.Has(c3))
+	})
+
+	t.Run("zero size cache holds nothing", func(t *testing.T) {
+		cache := consensus.NewValidatedBlockCache(0)
+		cache.Add(c1)
+		assert.False(t, cache.Has(c1))
+	})
+}
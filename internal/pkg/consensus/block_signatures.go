@@ -0,0 +1,114 @@
+package consensus
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/minio/blake2b-simd"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// VerifyTipSetSignatures verifies the BlockSig of every block in `ts` against
+// the public key of the block's miner in `pubKeys`.
+// BLS signatures are first checked together with a single aggregate
+// verification; only if that fails are they checked one at a time.
+// Secp256k1 signatures are always checked individually. The returned error
+// identifies the first invalid block in tipset order.
+func VerifyTipSetSignatures(ts block.TipSet, pubKeys map[address.Address][]byte) error {
+	var blsKeys, blsData, blsSigs [][]byte
+	for i := 0; i < ts.Len(); i++ {
+		blk := ts.At(i)
+		if blk.BlockSig.Type != crypto.SigTypeBLS {
+			continue
+		}
+		blsKeys = append(blsKeys, pubKeys[blk.Miner])
+		blsData = append(blsData, blk.SignatureData())
+		blsSigs = append(blsSigs, blk.BlockSig.Data)
+	}
+
+	blsValid := len(blsSigs) == 0
+	if !blsValid {
+		if aggregate, err := crypto.AggregateBLS(blsSigs); err == nil {
+			blsValid = crypto.VerifyBLSAggregate(blsKeys, blsData, aggregate)
+		}
+	}
+
+	for i := 0; i < ts.Len(); i++ {
+		blk := ts.At(i)
+		pubKey, ok := pubKeys[blk.Miner]
+		if !ok {
+			return errors.Errorf("no public key for miner %s of block %s", blk.Miner, blk.Cid())
+		}
+
+		switch blk.BlockSig.Type {
+		case crypto.SigTypeSecp256k1:
+			hash := blake2b.Sum256(blk.SignatureData())
+			if !crypto.VerifySecp(pubKey, hash[:], blk.BlockSig.Data) {
+				return errors.Errorf("block %s has invalid signature", blk.Cid())
+			}
+		case crypto.SigTypeBLS:
+			if !blsValid && !crypto.VerifyBLS(pubKey, blk.SignatureData(), blk.BlockSig.Data) {
+				return errors.Errorf("block %s has invalid signature", blk.Cid())
+			}
+		default:
+			return errors.Errorf("block %s has unknown signature type %d", blk.Cid(), blk.BlockSig.Type)
+		}
+	}
+	return nil
+}
+
+// VerifyMessageSignatures verifies the signatures of `msgs` using up to
+// `parallelism` goroutines, or GOMAXPROCS if it is not positive. Messages are
+// taken in order and no more are started once one is found invalid, but
+// those already started are finished, so the returned error always
+// identifies the lowest invalid index.
+func VerifyMessageSignatures(ctx context.Context, msgs []*types.SignedMessage, parallelism int) error {
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	if parallelism > len(msgs) {
+		parallelism = len(msgs)
+	}
+
+	errs := make([]error, len(msgs))
+	next := int64(-1)
+	lowestInvalid := int64(len(msgs))
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				i := atomic.AddInt64(&next, 1)
+				if i >= atomic.LoadInt64(&lowestInvalid) {
+					return
+				}
+				if errs[i] = types.VerifySignedMessage(msgs[i]); errs[i] == nil {
+					continue
+				}
+				for {
+					lowest := atomic.LoadInt64(&lowestInvalid)
+					if i >= lowest || atomic.CompareAndSwapInt64(&lowestInvalid, lowest, i) {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if lowestInvalid < int64(len(msgs)) {
+		return errors.Wrapf(errs[lowestInvalid], "message %d has an invalid signature", lowestInvalid)
+	}
+	return nil
+}
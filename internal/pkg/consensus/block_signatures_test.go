@@ -0,0 +1,143 @@
+package consensus_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+func TestVerifyTipSetSignatures(t *testing.T) {
+	tf.UnitTest(t)
+
+	keys := append(types.MustGenerateKeyInfo(2, 42), crypto.NewBLSKeyRandom(), crypto.NewBLSKeyRandom())
+	signer := types.NewMockSigner(keys)
+	parents := block.NewTipSetKey(types.CidFromString(t, "parent"))
+
+	// Each block is mined (and signed) by the miner whose worker is the i-th signer address.
+	pubKeys := make(map[address.Address][]byte)
+	newBlocks := func() []*block.Block {
+		var blocks []*block.Block
+		for i, worker := range signer.Addresses {
+			blk := &block.Block{
+				Miner:        worker,
+				Ticket:       block.Ticket{VRFProof: []byte{byte(i)}},
+				Parents:      parents,
+				ParentWeight: fbig.Zero(),
+				Height:       10,
+			}
+			require.NoError(t, block.SignBlock(signer, blk, worker))
+			pubKeys[worker] = signer.PubKeys[i]
+			blocks = append(blocks, blk)
+		}
+		return blocks
+	}
+
+	t.Run("all valid", func(t *testing.T) {
+		ts := th.RequireNewTipSet(t, newBlocks()...)
+		assert.NoError(t, consensus.VerifyTipSetSignatures(ts, pubKeys))
+	})
+
+	t.Run("tampered secp block", func(t *testing.T) {
+		blocks := newBlocks()
+		blocks[0].BlockSig = blocks[1].BlockSig
+		ts := th.RequireNewTipSet(t, blocks...)
+		err := consensus.VerifyTipSetSignatures(ts, pubKeys)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), blocks[0].Cid().String())
+	})
+
+	t.Run("tampered bls block", func(t *testing.T) {
+		blocks := newBlocks()
+		blocks[3].BlockSig = blocks[2].BlockSig
+		ts := th.RequireNewTipSet(t, blocks...)
+		err := consensus.VerifyTipSetSignatures(ts, pubKeys)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), blocks[3].Cid().String())
+	})
+
+	t.Run("missing public key", func(t *testing.T) {
+		ts := th.RequireNewTipSet(t, newBlocks()...)
+		assert.Error(t, consensus.VerifyTipSetSignatures(ts, map[address.Address][]byte{}))
+	})
+}
+
+// signedMessages returns `n` validly signed messages.
+func signedMessages(t testing.TB, n int) []*types.SignedMessage {
+	signer, _ := types.NewMockSignersAndKeyInfo(1)
+	newMsg := types.NewSignedMessageForTestGetter(signer)
+	msgs := make([]*types.SignedMessage, n)
+	for i := range msgs {
+		msgs[i] = newMsg()
+	}
+	return msgs
+}
+
+// corrupt returns a copy of `msg` with an invalid signature.
+func corrupt(msg *types.SignedMessage) *types.SignedMessage {
+	bad := *msg
+	bad.Signature.Data = append([]byte{}, msg.Signature.Data...)
+	bad.Signature.Data[0] ^= 0xff
+	return &bad
+}
+
+func TestVerifyMessageSignatures(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+
+	t.Run("large valid set", func(t *testing.T) {
+		msgs := signedMessages(t, 500)
+		for _, parallelism := range []int{0, 1, 8} {
+			assert.NoError(t, consensus.VerifyMessageSignatures(ctx, msgs, parallelism))
+		}
+	})
+
+	t.Run("lowest invalid index reported", func(t *testing.T) {
+		msgs := signedMessages(t, 200)
+		msgs[137] = corrupt(msgs[137])
+		msgs[150] = corrupt(msgs[150])
+		msgs[199] = corrupt(msgs[199])
+		for i := 0; i < 10; i++ {
+			err := consensus.VerifyMessageSignatures(ctx, msgs, 8)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "message 137 has an invalid signature")
+		}
+	})
+
+	t.Run("empty set", func(t *testing.T) {
+		assert.NoError(t, consensus.VerifyMessageSignatures(ctx, nil, 4))
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		err := consensus.VerifyMessageSignatures(canceled, signedMessages(t, 10), 2)
+		assert.Equal(t, context.Canceled, err)
+	})
+}
+
+func BenchmarkVerifyMessageSignatures(b *testing.B) {
+	ctx := context.Background()
+	msgs := signedMessages(b, 1000)
+	for _, parallelism := range []int{1, 0} {
+		b.Run(fmt.Sprintf("parallelism=%d", parallelism), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := consensus.VerifyMessageSignatures(ctx, msgs, parallelism); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
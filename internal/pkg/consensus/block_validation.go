@@ -4,12 +4,22 @@ import (
 	"context"
 	"fmt"
 
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/pkg/errors"
+
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
 )
 
+// ErrParentWeightMismatch is returned when a block's claimed parent weight
+// doesn't match the computed weight of its parent tipset.
+var ErrParentWeightMismatch = errors.New("block parent weight does not match computed weight of parents")
+
+// GetWeight computes the weight of a tipset.
+type GetWeight func(context.Context, block.TipSet) (fbig.Int, error)
+
 // BlockValidator defines an interface used to validate a blocks syntax and
 // semantics.
 type BlockValidator interface {
@@ -105,7 +115,7 @@ func (dv *DefaultBlockValidator) ValidateSemantic(ctx context.Context, child *bl
 // TODO this is an incomplete implementation #3277
 func (dv *DefaultBlockValidator) ValidateSyntax(ctx context.Context, blk *block.Block) error {
 	// TODO special handling for genesis block #3121
-	if blk.Height == 0 {
+	if blk.IsGenesis() {
 		return nil
 	}
 	err := dv.NotFutureBlock(blk)
@@ -125,6 +135,12 @@ func (dv *DefaultBlockValidator) ValidateSyntax(ctx context.Context, blk *block.
 	if len(blk.Ticket.VRFProof) == 0 {
 		return fmt.Errorf("block %s has nil ticket", blk.Cid().String())
 	}
+	if blk.Parents.Len() > block.MaxTipSetSize {
+		return fmt.Errorf("block %s has %d parents, max is %d", blk.Cid().String(), blk.Parents.Len(), block.MaxTipSetSize)
+	}
+	if len(blk.Extension) > block.MaxExtensionLength {
+		return fmt.Errorf("block %s has extension of %d bytes, max is %d", blk.Cid().String(), len(blk.Extension), block.MaxExtensionLength)
+	}
 
 	return nil
 }
@@ -149,3 +165,17 @@ func (dv *DefaultBlockValidator) ValidateUnsignedMessagesSyntax(ctx context.Cont
 func (dv *DefaultBlockValidator) ValidateReceiptsSyntax(ctx context.Context, receipts []vm.MessageReceipt) error {
 	return nil
 }
+
+// ValidateParentWeight checks that the parent weight claimed by `b` equals
+// the weight of `parent` computed by `getWeight`, so that a miner can't
+// inflate the weight of the chain it extends.
+func ValidateParentWeight(ctx context.Context, b *block.Block, parent block.TipSet, getWeight GetWeight) error {
+	weight, err := getWeight(ctx, parent)
+	if err != nil {
+		return errors.Wrapf(err, "failed to compute weight of parent %s", parent.Key())
+	}
+	if !weight.Equals(b.ParentWeight) {
+		return errors.Wrapf(ErrParentWeightMismatch, "block %s claims parent weight %s, computed %s", b.Cid(), b.ParentWeight, weight)
+	}
+	return nil
+}
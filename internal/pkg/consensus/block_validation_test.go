@@ -6,7 +6,9 @@ import (
 	"time"
 
 	"github.com/filecoin-project/go-address"
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -143,4 +145,52 @@ func TestBlockValidSyntax(t *testing.T) {
 	blk.Ticket = validTi
 	require.NoError(t, validator.ValidateSyntax(ctx, blk))
 
+	// invalidate extension
+	blk.Extension = make([]byte, block.MaxExtensionLength+1)
+	require.Error(t, validator.ValidateSyntax(ctx, blk))
+	blk.Extension = make([]byte, block.MaxExtensionLength)
+	require.NoError(t, validator.ValidateSyntax(ctx, blk))
+
+	// invalidate parent count
+	newCid := types.NewCidForTestGetter()
+	parents := make([]cid.Cid, block.MaxTipSetSize+1)
+	for i := range parents {
+		parents[i] = newCid()
+	}
+	blk.Parents = block.NewTipSetKey(parents...)
+	require.Error(t, validator.ValidateSyntax(ctx, blk))
+	blk.Parents = block.NewTipSetKey(parents[:block.MaxTipSetSize]...)
+	require.NoError(t, validator.ValidateSyntax(ctx, blk))
+
+}
+
+func TestValidateParentWeight(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	parent := consensus.RequireNewTipSet(require.New(t), &block.Block{Height: 1, ParentWeight: fbig.Zero()})
+	getWeight := func(_ context.Context, ts block.TipSet) (fbig.Int, error) {
+		require.True(t, ts.Equals(parent))
+		return fbig.NewInt(42), nil
+	}
+
+	t.Run("correct weight passes", func(t *testing.T) {
+		child := &block.Block{Height: 2, Parents: parent.Key(), ParentWeight: fbig.NewInt(42)}
+		assert.NoError(t, consensus.ValidateParentWeight(ctx, child, parent, getWeight))
+	})
+
+	t.Run("inflated weight fails", func(t *testing.T) {
+		child := &block.Block{Height: 2, Parents: parent.Key(), ParentWeight: fbig.NewInt(43)}
+		err := consensus.ValidateParentWeight(ctx, child, parent, getWeight)
+		require.Error(t, err)
+		assert.Equal(t, consensus.ErrParentWeightMismatch, errors.Cause(err))
+	})
+
+	t.Run("weighing failure fails", func(t *testing.T) {
+		failing := func(context.Context, block.TipSet) (fbig.Int, error) {
+			return fbig.Zero(), errors.New("boom")
+		}
+		child := &block.Block{Height: 2, Parents: parent.Key(), ParentWeight: fbig.NewInt(42)}
+		assert.Error(t, consensus.ValidateParentWeight(ctx, child, parent, failing))
+	})
 }
@@ -51,6 +51,15 @@ func (c *ChainSelector) Weight(ctx context.Context, ts block.TipSet, pStateID ci
 	if ts.Len() > 0 && ts.At(0).Cid().Equals(c.genesisCid) {
 		return fbig.Zero(), nil
 	}
+	if !pStateID.Defined() {
+		return fbig.Zero(), errors.New("undefined state passed to chain selector new weight")
+	}
+	return TipSetWeight(ctx, ts, c.state.StateView(pStateID))
+}
+
+// TipSetWeight returns the EC weight of a non-genesis tipset `ts`, given
+// `view`, a view of the state of its parent, see ChainSelector.Weight.
+func TipSetWeight(ctx context.Context, ts block.TipSet, view PowerStateView) (fbig.Int, error) {
 	// Retrieve parent weight.
 	parentW, err := ts.ParentWeight()
 	if err != nil {
@@ -68,10 +77,7 @@ func (c *ChainSelector) Weight(ctx context.Context, ts block.TipSet, pStateID ci
 	innerTerm.Mul(floatECV, floatNumBlocks)
 
 	// Add bitnum(total storage power) to the weight's inner term
-	if !pStateID.Defined() {
-		return fbig.Zero(), errors.New("undefined state passed to chain selector new weight")
-	}
-	powerTableView := NewPowerTableView(c.state.StateView(pStateID))
+	powerTableView := NewPowerTableView(view)
 	totalBytes, err := powerTableView.Total(ctx)
 	if err != nil {
 		return fbig.Zero(), err
@@ -105,6 +111,12 @@ func (c *ChainSelector) IsHeavier(ctx context.Context, a, b block.TipSet, aState
 		return aW.GreaterThan(bW), nil
 	}
 
+	return heavierOnTie(a, b)
+}
+
+// heavierOnTie breaks a weight tie between tipsets a and b, returning true if
+// a has the smaller min ticket or, with equal tickets, the greater key.
+func heavierOnTie(a, b block.TipSet) (bool, error) {
 	// To break ties compare the min tickets.
 	aTicket, err := a.MinTicket()
 	if err != nil {
@@ -132,6 +144,39 @@ func (c *ChainSelector) IsHeavier(ctx context.Context, a, b block.TipSet, aState
 	return cmp == 1, nil
 }
 
+// HeaviestOf returns the heaviest of the candidate tipsets `tips` by the
+// weights `getWeight` computes, breaking ties like IsHeavier. Repeated
+// candidates are considered once.
+func HeaviestOf(ctx context.Context, tips []block.TipSet, getWeight GetWeight) (block.TipSet, error) {
+	if len(tips) == 0 {
+		return block.UndefTipSet, errors.New("no candidate tipsets")
+	}
+	best := tips[0]
+	bestW, err := getWeight(ctx, best)
+	if err != nil {
+		return block.UndefTipSet, err
+	}
+	for _, ts := range tips[1:] {
+		if ts.Equals(best) {
+			continue
+		}
+		w, err := getWeight(ctx, ts)
+		if err != nil {
+			return block.UndefTipSet, err
+		}
+		heavier := w.GreaterThan(bestW)
+		if w.Equals(bestW) {
+			if heavier, err = heavierOnTie(ts, best); err != nil {
+				return block.UndefTipSet, err
+			}
+		}
+		if heavier {
+			best, bestW = ts, w
+		}
+	}
+	return best, nil
+}
+
 func (c *ChainSelector) loadStateTree(ctx context.Context, id cid.Cid) (state.Tree, error) {
 	return state.NewTreeLoader().LoadStateTree(ctx, c.cstore, id)
 }
@@ -0,0 +1,63 @@
+package consensus_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestHeaviestOf(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	newTipSet := func(height int64, vrfProof byte) block.TipSet {
+		return th.RequireNewTipSet(t, &block.Block{
+			Height:       abi.ChainEpoch(height),
+			ParentWeight: fbig.Zero(),
+			Ticket:       block.Ticket{VRFProof: []byte{vrfProof}},
+		})
+	}
+	weights := map[block.TipSetKey]int64{}
+	getWeight := func(_ context.Context, ts block.TipSet) (fbig.Int, error) {
+		return fbig.NewInt(weights[ts.Key()]), nil
+	}
+
+	light := newTipSet(1, 1)
+	heavy := newTipSet(2, 2)
+	tiedHigh := newTipSet(3, 9)
+	tiedLow := newTipSet(4, 3)
+	weights[light.Key()] = 10
+	weights[heavy.Key()] = 20
+	weights[tiedHigh.Key()] = 30
+	weights[tiedLow.Key()] = 30
+
+	t.Run("clearly heaviest", func(t *testing.T) {
+		best, err := consensus.HeaviestOf(ctx, []block.TipSet{light, heavy, light}, getWeight)
+		require.NoError(t, err)
+		assert.True(t, heavy.Equals(best))
+	})
+
+	t.Run("tie broken by smaller min ticket", func(t *testing.T) {
+		best, err := consensus.HeaviestOf(ctx, []block.TipSet{tiedHigh, heavy, tiedLow}, getWeight)
+		require.NoError(t, err)
+		assert.True(t, tiedLow.Equals(best))
+
+		best, err = consensus.HeaviestOf(ctx, []block.TipSet{tiedLow, tiedHigh}, getWeight)
+		require.NoError(t, err)
+		assert.True(t, tiedLow.Equals(best))
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		_, err := consensus.HeaviestOf(ctx, nil, getWeight)
+		assert.Error(t, err)
+	})
+}
@@ -1,12 +1,16 @@
 package consensus
 
 import (
+	"context"
 	"encoding/binary"
 	"math/big"
 
 	"github.com/filecoin-project/go-address"
 	sector "github.com/filecoin-project/go-sectorbuilder"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	acrypto "github.com/filecoin-project/specs-actors/actors/crypto"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
 
 	ffi "github.com/filecoin-project/filecoin-ffi"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
@@ -17,17 +21,35 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/util/convert"
 )
 
+// VRFInputVersion selects the data signed to produce tickets and PoSt
+// randomness. Block producers and validators must agree on it, see Params.
+type VRFInputVersion uint64
+
+const (
+	// VRFInputsUntagged VRF inputs are the bare parent ticket for tickets,
+	// and the election ticket followed by the null block count for PoSt
+	// randomness.
+	VRFInputsUntagged VRFInputVersion = 0
+	// VRFInputsTagged VRF inputs blend the same data behind a distinct domain
+	// separation tag for each use, so that neither signature can be
+	// presented as the other.
+	VRFInputsTagged VRFInputVersion = 1
+)
+
 // ElectionMachine generates and validates PoSt partial tickets and PoSt
 // proofs.
-type ElectionMachine struct{}
+type ElectionMachine struct {
+	// VRFInputs is the version of the PoSt randomness VRF input.
+	VRFInputs VRFInputVersion
+}
 
 // GeneratePoStRandomness returns the PoStRandomness for the given epoch.
 func (em ElectionMachine) GeneratePoStRandomness(ticket block.Ticket, candidateAddr address.Address, signer types.Signer, nullBlockCount uint64) ([]byte, error) {
-	seedBuf := make([]byte, binary.MaxVarintLen64)
-	n := binary.PutUvarint(seedBuf, nullBlockCount)
-	buf := append(ticket.VRFProof, seedBuf[:n]...)
-
-	signature, err := signer.SignBytes(buf[:], candidateAddr)
+	input, err := postRandomnessInput(em.VRFInputs, ticket, nullBlockCount)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signer.SignBytes(input, candidateAddr)
 	if err != nil {
 		return nil, err
 	}
@@ -50,15 +72,52 @@ func (em ElectionMachine) GeneratePoSt(allSectorInfos ffi.SortedPublicSectorInfo
 // VerifyPoStRandomness verifies that the PoSt randomness is the result of the
 // candidate signing the ticket.
 func (em ElectionMachine) VerifyPoStRandomness(rand block.VRFPi, ticket block.Ticket, candidateAddr address.Address, nullBlockCount uint64) bool {
+	input, err := postRandomnessInput(em.VRFInputs, ticket, nullBlockCount)
+	if err != nil {
+		return false
+	}
+	return crypto.IsValidBLSSignature(input, candidateAddr, rand)
+}
+
+// postRandomnessInput returns the data signed to produce the PoSt randomness
+// from an election ticket.
+func postRandomnessInput(version VRFInputVersion, ticket block.Ticket, nullBlockCount uint64) ([]byte, error) {
 	seedBuf := make([]byte, binary.MaxVarintLen64)
 	n := binary.PutUvarint(seedBuf, nullBlockCount)
-	buf := append(ticket.VRFProof, seedBuf[:n]...)
-
-	return crypto.IsValidBLSSignature(buf[:], candidateAddr, rand)
+	return vrfInput(version, acrypto.DomainSeparationTag_ElectionPoStChallengeSeed, ticket.VRFProof, seedBuf[:n])
 }
 
 // CandidateWins returns true if the input candidate wins the election
 func (em ElectionMachine) CandidateWins(challengeTicket []byte, sectorNum, faultNum, networkPower, sectorSize uint64) bool {
+	challenge, threshold := electionTerms(challengeTicket, sectorNum, faultNum, networkPower, sectorSize)
+	return challenge.Cmp(threshold) == -1
+}
+
+// WinExplanation breaks down the outcome of an election for a single candidate.
+// The candidate wins when Challenge is strictly less than Threshold.
+type WinExplanation struct {
+	// Challenge is the challenge ticket scaled by network power and the number of sectors sampled.
+	Challenge *big.Int
+	// Threshold is the miner's sector power scaled by the ticket domain, its sector count and the
+	// expected number of leaders per epoch.
+	Threshold *big.Int
+	// Wins is true iff the candidate won the election.
+	Wins bool
+}
+
+// ExplainWin computes the same election as CandidateWins, additionally reporting the values
+// compared so tooling can show how far a candidate was from winning.
+func (em ElectionMachine) ExplainWin(challengeTicket []byte, sectorNum, faultNum, networkPower, sectorSize uint64) WinExplanation {
+	challenge, threshold := electionTerms(challengeTicket, sectorNum, faultNum, networkPower, sectorSize)
+	return WinExplanation{
+		Challenge: challenge,
+		Threshold: threshold,
+		Wins:      challenge.Cmp(threshold) == -1,
+	}
+}
+
+// electionTerms returns both sides of the election inequality.
+func electionTerms(challengeTicket []byte, sectorNum, faultNum, networkPower, sectorSize uint64) (*big.Int, *big.Int) {
 	numSectorsSampled := sector.ElectionPostChallengeCount(sectorNum, faultNum)
 
 	lhs := new(big.Int).SetBytes(challengeTicket[:])
@@ -73,7 +132,7 @@ func (em ElectionMachine) CandidateWins(challengeTicket []byte, sectorNum, fault
 	// lhs < rhs?
 	// (challengeTicket / maxChallengeTicket) < expectedLeadersPerEpoch * (effective miner power) / networkPower
 	// effective miner power = sectorSize * numberSectors / numSectorsSampled
-	return lhs.Cmp(rhs) == -1
+	return lhs, rhs
 }
 
 // VerifyPoSt verifies a PoSt proof.
@@ -92,12 +151,19 @@ func (em ElectionMachine) VerifyPoSt(ep verification.PoStVerifier, allSectorInfo
 
 // TicketMachine uses a VRF and VDF to generate deterministic, unpredictable
 // and time delayed tickets and validates these tickets.
-type TicketMachine struct{}
+type TicketMachine struct {
+	// VRFInputs is the version of the ticket VRF input.
+	VRFInputs VRFInputVersion
+}
 
 // NextTicket creates a new ticket from a parent ticket by running a verifiable
 // randomness function on the parent.
 func (tm TicketMachine) NextTicket(parent block.Ticket, signerAddr address.Address, signer types.Signer) (block.Ticket, error) {
-	vrfPi, err := signer.SignBytes(parent.VRFProof[:], signerAddr)
+	input, err := vrfInput(tm.VRFInputs, acrypto.DomainSeparationTag_TicketProduction, parent.VRFProof, nil)
+	if err != nil {
+		return block.Ticket{}, err
+	}
+	vrfPi, err := signer.SignBytes(input, signerAddr)
 	if err != nil {
 		return block.Ticket{}, err
 	}
@@ -110,5 +176,65 @@ func (tm TicketMachine) NextTicket(parent block.Ticket, signerAddr address.Addre
 // IsValidTicket verifies that the ticket's proof of randomness and delay are
 // valid with respect to its parent.
 func (tm TicketMachine) IsValidTicket(parent, ticket block.Ticket, signerAddr address.Address) bool {
-	return crypto.IsValidBLSSignature(parent.VRFProof[:], signerAddr, ticket.VRFProof)
+	input, err := vrfInput(tm.VRFInputs, acrypto.DomainSeparationTag_TicketProduction, parent.VRFProof, nil)
+	if err != nil {
+		return false
+	}
+	return crypto.IsValidBLSSignature(input, signerAddr, ticket.VRFProof)
+}
+
+// ValidateTicketChain verifies that the ticket of `b` was generated, by the
+// holder of the BLS public key `pubKey`, from the min ticket of `parent`.
+func ValidateTicketChain(b *block.Block, parent block.TipSet, pubKey []byte) error {
+	signerAddr, err := address.NewBLSAddress(pubKey)
+	if err != nil {
+		return errors.Wrap(err, "invalid ticket signer key")
+	}
+	return validateTicketChain(TicketMachine{}, b, parent, signerAddr)
+}
+
+// validateTicketChain verifies with `tv` that the ticket of `b` was generated
+// by `signerAddr` from the min ticket of `parent`.
+func validateTicketChain(tv TicketValidator, b *block.Block, parent block.TipSet, signerAddr address.Address) error {
+	parentTicket, err := parent.MinTicket()
+	if err != nil {
+		return errors.Wrap(err, "failed to read parent min ticket")
+	}
+	if !tv.IsValidTicket(parentTicket, b.Ticket, signerAddr) {
+		return errors.Errorf("invalid ticket: %s in block %s", b.Ticket.String(), b.Cid().String())
+	}
+	return nil
+}
+
+// ValidateWinnersOwnership errors unless every winner in `info` claims a
+// sector in the proving set of `miner` as recorded in `view`.
+func ValidateWinnersOwnership(ctx context.Context, info block.EPoStInfo, view PowerStateView, miner address.Address) error {
+	owned := make(map[abi.SectorNumber]struct{})
+	err := view.MinerProvingSetForEach(ctx, miner, func(id abi.SectorNumber, _ cid.Cid) error {
+		owned[id] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to load proving set of miner %s", miner)
+	}
+	for _, winner := range info.Winners {
+		if _, ok := owned[abi.SectorNumber(winner.SectorID)]; !ok {
+			return errors.Errorf("winner sector %d not in proving set of miner %s", winner.SectorID, miner)
+		}
+	}
+	return nil
+}
+
+// vrfInput returns the data signed to draw randomness for the use tagged by
+// `tag` from `seed` and `entropy`, in the layout of `version`.
+func vrfInput(version VRFInputVersion, tag acrypto.DomainSeparationTag, seed, entropy []byte) ([]byte, error) {
+	switch version {
+	case VRFInputsUntagged:
+		input := append([]byte{}, seed...)
+		return append(input, entropy...), nil
+	case VRFInputsTagged:
+		return crypto.BlendEntropy(tag, seed, entropy)
+	default:
+		return nil, errors.Errorf("unknown VRF input version %d", version)
+	}
 }
@@ -1,14 +1,18 @@
 package consensus_test
 
 import (
+	"context"
+	"math/big"
 	"testing"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	appstate "github.com/filecoin-project/go-filecoin/internal/pkg/state"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
 
@@ -66,8 +70,184 @@ func TestNextTicketFailsWithInvalidSigner(t *testing.T) {
 	assert.Nil(t, badTicket.VRFProof)
 }
 
+func TestTicketAndPoStRandomnessDomainsSeparated(t *testing.T) {
+	tf.UnitTest(t)
+
+	ki := crypto.NewBLSKeyRandom()
+	signer := types.NewMockSigner([]crypto.KeyInfo{ki})
+	addr := requireAddress(t, &ki)
+	ticket := consensus.MakeFakeTicketForTest()
+	// A ticket drawn from a parent ending in the encoded null block count
+	// signs the same bytes as the PoSt randomness without separation.
+	lookalike := block.Ticket{VRFProof: append(append(block.VRFPi{}, ticket.VRFProof...), 0)}
+
+	t.Run("tagged inputs separated", func(t *testing.T) {
+		em := consensus.ElectionMachine{VRFInputs: consensus.VRFInputsTagged}
+		tm := consensus.TicketMachine{VRFInputs: consensus.VRFInputsTagged}
+
+		postRand, err := em.GeneratePoStRandomness(ticket, addr, signer, 0)
+		require.NoError(t, err)
+		require.True(t, em.VerifyPoStRandomness(postRand, ticket, addr, 0))
+		next, err := tm.NextTicket(lookalike, addr, signer)
+		require.NoError(t, err)
+		require.True(t, tm.IsValidTicket(lookalike, next, addr))
+
+		assert.NotEqual(t, []byte(next.VRFProof), postRand)
+		assert.False(t, em.VerifyPoStRandomness(next.VRFProof, ticket, addr, 0))
+		assert.False(t, tm.IsValidTicket(lookalike, block.Ticket{VRFProof: postRand}, addr))
+	})
+
+	t.Run("untagged inputs unchanged", func(t *testing.T) {
+		em := consensus.ElectionMachine{}
+		tm := consensus.TicketMachine{}
+		assert.Equal(t, consensus.VRFInputsUntagged, consensus.DefaultParams().VRFInputs)
+
+		postRand, err := em.GeneratePoStRandomness(ticket, addr, signer, 0)
+		require.NoError(t, err)
+		next, err := tm.NextTicket(lookalike, addr, signer)
+		require.NoError(t, err)
+		assert.Equal(t, []byte(next.VRFProof), postRand)
+
+		// Tagged validators reject untagged signatures.
+		tagged := consensus.ElectionMachine{VRFInputs: consensus.VRFInputsTagged}
+		assert.False(t, tagged.VerifyPoStRandomness(postRand, ticket, addr, 0))
+	})
+
+	t.Run("unknown version rejected", func(t *testing.T) {
+		em := consensus.ElectionMachine{VRFInputs: consensus.VRFInputsTagged + 1}
+		_, err := em.GeneratePoStRandomness(ticket, addr, signer, 0)
+		assert.Error(t, err)
+		_, err = consensus.TicketMachine{VRFInputs: consensus.VRFInputsTagged + 1}.NextTicket(ticket, addr, signer)
+		assert.Error(t, err)
+	})
+}
+
+func TestValidateTicketChain(t *testing.T) {
+	tf.UnitTest(t)
+
+	ki := crypto.NewBLSKeyRandom()
+	signer := types.NewMockSigner([]crypto.KeyInfo{ki})
+	addr := requireAddress(t, &ki)
+	tm := consensus.TicketMachine{}
+
+	newParent := func(tickets ...block.Ticket) block.TipSet {
+		var blks []*block.Block
+		for _, ticket := range tickets {
+			blks = append(blks, &block.Block{Ticket: ticket, Height: 1})
+		}
+		parent, err := block.NewTipSet(blks...)
+		require.NoError(t, err)
+		return parent
+	}
+	parent := newParent(
+		block.Ticket{VRFProof: []byte{0x2}},
+		block.Ticket{VRFProof: []byte{0x1}},
+	)
+	other := newParent(block.Ticket{VRFProof: []byte{0x3}})
+
+	minTicket, err := parent.MinTicket()
+	require.NoError(t, err)
+	ticket, err := tm.NextTicket(minTicket, addr, signer)
+	require.NoError(t, err)
+	blk := &block.Block{Ticket: ticket, Height: 2}
+
+	t.Run("ticket chained from parent min ticket valid", func(t *testing.T) {
+		assert.NoError(t, consensus.ValidateTicketChain(blk, parent, addr.Payload()))
+	})
+
+	t.Run("ticket chained from another parent invalid", func(t *testing.T) {
+		assert.Error(t, consensus.ValidateTicketChain(blk, other, addr.Payload()))
+	})
+
+	t.Run("ticket chained from non-min parent ticket invalid", func(t *testing.T) {
+		ticket, err := tm.NextTicket(parent.At(1).Ticket, addr, signer)
+		require.NoError(t, err)
+		blk := &block.Block{Ticket: ticket, Height: 2}
+		assert.Error(t, consensus.ValidateTicketChain(blk, parent, addr.Payload()))
+	})
+
+	t.Run("ticket from another signer invalid", func(t *testing.T) {
+		otherKey := crypto.NewBLSKeyRandom()
+		otherAddr := requireAddress(t, &otherKey)
+		assert.Error(t, consensus.ValidateTicketChain(blk, parent, otherAddr.Payload()))
+	})
+}
+
+func TestExplainWin(t *testing.T) {
+	tf.UnitTest(t)
+
+	em := consensus.ElectionMachine{}
+	sectorNum, networkPower, sectorSize := uint64(10), uint64(1)<<40, uint64(1024)
+
+	// sectorSize * 2^256 * sectorNum * expectedLeadersPerEpoch
+	expectedThreshold := new(big.Int).Lsh(big.NewInt(int64(sectorSize)), 256)
+	expectedThreshold.Mul(expectedThreshold, big.NewInt(int64(sectorNum)))
+	expectedThreshold.Mul(expectedThreshold, big.NewInt(5))
+
+	t.Run("winning ticket", func(t *testing.T) {
+		ticket := make([]byte, 32)
+		explanation := em.ExplainWin(ticket, sectorNum, 0, networkPower, sectorSize)
+		assert.True(t, explanation.Wins)
+		assert.Equal(t, em.CandidateWins(ticket, sectorNum, 0, networkPower, sectorSize), explanation.Wins)
+		assert.Equal(t, 0, explanation.Challenge.Sign())
+		assert.Equal(t, 0, expectedThreshold.Cmp(explanation.Threshold))
+	})
+
+	t.Run("losing ticket", func(t *testing.T) {
+		ticket := make([]byte, 32)
+		for i := range ticket {
+			ticket[i] = 0xff
+		}
+		explanation := em.ExplainWin(ticket, sectorNum, 0, networkPower, sectorSize)
+		assert.False(t, explanation.Wins)
+		assert.Equal(t, em.CandidateWins(ticket, sectorNum, 0, networkPower, sectorSize), explanation.Wins)
+		assert.Equal(t, 0, expectedThreshold.Cmp(explanation.Threshold))
+
+		// the challenge is at least the ticket scaled by network power
+		minChallenge := new(big.Int).Mul(new(big.Int).SetBytes(ticket), big.NewInt(int64(networkPower)))
+		assert.True(t, explanation.Challenge.Cmp(minChallenge) >= 0)
+		assert.True(t, explanation.Challenge.Cmp(explanation.Threshold) >= 0)
+	})
+}
+
 func requireAddress(t *testing.T, ki *crypto.KeyInfo) address.Address {
 	addr, err := ki.Address()
 	require.NoError(t, err)
 	return addr
 }
+
+func TestValidateWinnersOwnership(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	minerAddr := vmaddr.RequireIDAddress(t, 1000)
+	view := appstate.NewFakeStateView(abi.NewStoragePower(16))
+	view.Miners[minerAddr] = &appstate.FakeMinerState{
+		ProvingSet: []appstate.FakeSectorInfo{
+			{ID: 1, SealedCID: types.CidFromString(t, "sector1")},
+			{ID: 3, SealedCID: types.CidFromString(t, "sector3")},
+		},
+	}
+	infoWithWinners := func(sectorIDs ...uint64) block.EPoStInfo {
+		var winners []block.EPoStCandidate
+		for _, id := range sectorIDs {
+			winners = append(winners, block.NewEPoStCandidate(id, []byte{0xf}, 0))
+		}
+		return block.NewEPoStInfo(consensus.MakeFakePoStForTest(), consensus.MakeFakeVRFProofForTest(), winners...)
+	}
+
+	t.Run("owned sectors", func(t *testing.T) {
+		assert.NoError(t, consensus.ValidateWinnersOwnership(ctx, infoWithWinners(1, 3), view, minerAddr))
+	})
+
+	t.Run("non-owned sector", func(t *testing.T) {
+		err := consensus.ValidateWinnersOwnership(ctx, infoWithWinners(1, 2), view, minerAddr)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "winner sector 2")
+	})
+
+	t.Run("unknown miner", func(t *testing.T) {
+		err := consensus.ValidateWinnersOwnership(ctx, infoWithWinners(1), view, vmaddr.RequireIDAddress(t, 1001))
+		assert.Error(t, err)
+	})
+}
@@ -8,8 +8,6 @@ import (
 	address "github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
-	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
-	"github.com/filecoin-project/specs-actors/actors/builtin/power"
 	cid "github.com/ipfs/go-cid"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	cbor "github.com/ipfs/go-ipld-cbor"
@@ -19,10 +17,10 @@ import (
 
 	ffi "github.com/filecoin-project/filecoin-ffi"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics/tracing"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/proofs/verification"
-	"github.com/filecoin-project/go-filecoin/internal/pkg/sampling"
 	appstate "github.com/filecoin-project/go-filecoin/internal/pkg/state"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/util/hasher"
@@ -61,13 +59,13 @@ const challengeBits = 256
 const expectedLeadersPerEpoch = 5
 
 // AncestorRoundsNeeded is the number of rounds of the ancestor chain needed
-// to process all state transitions.
+// to process all state transitions under the default parameters.
 //
 // TODO: If the following PR is merged - and the network doesn't define a
 // largest sector size - this constant will need to be reconsidered.
 // https://github.com/filecoin-project/specs/pull/318
 // NOTE(anorth): This height is excessive, but safe, with the Rational PoSt construction.
-var AncestorRoundsNeeded = max(miner.ProvingPeriod+power.WindowedPostChallengeDuration, miner.ElectionLookback)
+var AncestorRoundsNeeded = DefaultParams().AncestorRoundsNeeded()
 
 // A Processor processes all the messages in a block or tip set.
 type Processor interface {
@@ -114,32 +112,42 @@ type Expected struct {
 	// state provides produces snapshots
 	state StateViewer
 
-	blockTime time.Duration
+	params Params
 
 	// postVerifier verifies PoSt proofs and associated data
 	postVerifier verification.PoStVerifier
+
+	// validatedBlocks remembers blocks that passed the expensive mining checks
+	// so blocks received more than once are not re-verified.
+	validatedBlocks *ValidatedBlockCache
+
+	// sigBudgets ration the signature verifications made validating each
+	// peer's blocks.
+	sigBudgets *PeerSignatureBudgets
 }
 
 // Ensure Expected satisfies the Protocol interface at compile time.
 var _ Protocol = (*Expected)(nil)
 
 // NewExpected is the constructor for the Expected consenus.Protocol module.
-func NewExpected(cs cbor.IpldStore, bs blockstore.Blockstore, processor Processor, state StateViewer, bt time.Duration, ev ElectionValidator, tv TicketValidator, pv verification.PoStVerifier) *Expected {
+func NewExpected(cs cbor.IpldStore, bs blockstore.Blockstore, processor Processor, state StateViewer, params Params, ev ElectionValidator, tv TicketValidator, pv verification.PoStVerifier) *Expected {
 	return &Expected{
 		cstore:            cs,
-		blockTime:         bt,
+		params:            params,
 		bstore:            bs,
 		processor:         processor,
 		state:             state,
 		ElectionValidator: ev,
 		TicketValidator:   tv,
 		postVerifier:      pv,
+		validatedBlocks:   NewValidatedBlockCache(DefaultValidatedBlockCacheSize),
+		sigBudgets:        NewPeerSignatureBudgets(clock.NewSystemClock(), DefaultSignatureRate, DefaultSignatureBurst),
 	}
 }
 
 // BlockTime returns the block time used by the consensus protocol.
 func (c *Expected) BlockTime() time.Duration {
-	return c.blockTime
+	return c.params.BlockTime
 }
 
 // RunStateTransition applies the messages in a tipset to a state, and persists that new state.
@@ -189,14 +197,10 @@ func (c *Expected) validateMining(
 	parentWeight fbig.Int,
 	parentReceiptRoot cid.Cid) error {
 
-	electionTicket, err := sampling.SampleNthTicket(int(miner.ElectionLookback-1), ancestors)
+	electionTicket, err := c.params.ElectionTicket(ancestors)
 	if err != nil {
 		return errors.Wrap(err, "failed to sample election ticket from ancestors")
 	}
-	prevTicket, err := parentTs.MinTicket()
-	if err != nil {
-		return errors.Wrap(err, "failed to read parent min ticket")
-	}
 	prevHeight, err := parentTs.Height()
 	if err != nil {
 		return errors.Wrap(err, "failed to read parent height")
@@ -220,25 +224,35 @@ func (c *Expected) validateMining(
 		if !parentWeight.Equals(blk.ParentWeight) {
 			return errors.Errorf("block %s has invalid parent weight %d", blk.Cid().String(), parentWeight)
 		}
+
+		// Signature, election and proof checks only depend on the block's
+		// content, so they can be skipped for blocks already fully validated.
+		if c.validatedBlocks.Has(blk.Cid()) {
+			continue
+		}
+
 		workerAddr, err := powerTable.WorkerAddr(ctx, blk.Miner)
 		if err != nil {
 			return errors.Wrap(err, "failed to read worker address of block miner")
 		}
+		// Wait for the sender's budget to afford the block, BLS aggregate and
+		// secp message signature checks.
+		if err := c.sigBudgets.For(senderOf(ctx)).Wait(ctx, 2+len(secpMsgs[i])); err != nil {
+			return errors.Wrap(err, "waiting to verify signatures")
+		}
 		// Validate block signature
 		if valid := crypto.IsValidSignature(blk.SignatureData(), workerAddr, blk.BlockSig); !valid {
 			return errors.New("block signature invalid")
 		}
 
 		// Verify that the BLS signature is correct
-		if err := verifyBLSMessageAggregate(blk.BLSAggregateSig.Data, blsMsgs[i]); err != nil {
+		if err := VerifyBLSMessageAggregate(blk.BLSAggregateSig.Data, blsMsgs[i]); err != nil {
 			return errors.Wrapf(err, "bls message verification failed for block %s", blk.Cid())
 		}
 
 		// Verify that all secp message signatures are correct
-		for i, msg := range secpMsgs[i] {
-			if !msg.VerifySignature() {
-				return errors.Errorf("secp message signature invalid for message, %d, in block %s", i, blk.Cid())
-			}
+		if err := VerifyMessageSignatures(ctx, secpMsgs[i], 0); err != nil {
+			return errors.Wrapf(err, "secp message signature invalid in block %s", blk.Cid())
 		}
 
 		// Verify PoStRandomness
@@ -293,9 +307,11 @@ func (c *Expected) validateMining(
 		}
 
 		// Ticket was correctly generated by miner
-		if !c.IsValidTicket(prevTicket, blk.Ticket, workerAddr) {
-			return errors.Errorf("invalid ticket: %s in block %s", blk.Ticket.String(), blk.Cid().String())
+		if err := validateTicketChain(c.TicketValidator, blk, parentTs, workerAddr); err != nil {
+			return err
 		}
+
+		c.validatedBlocks.Add(blk.Cid())
 	}
 	return nil
 }
@@ -350,8 +366,8 @@ func (p *PowerStateViewer) StateView(root cid.Cid) PowerStateView {
 	return p.Viewer.StateView(root)
 }
 
-// verifyBLSMessageAggregate errors if the bls signature is not a valid aggregate of message signatures
-func verifyBLSMessageAggregate(sig []byte, msgs []*types.UnsignedMessage) error {
+// VerifyBLSMessageAggregate errors if the bls signature is not a valid aggregate of message signatures
+func VerifyBLSMessageAggregate(sig []byte, msgs []*types.UnsignedMessage) error {
 	pubKeys := [][]byte{}
 	marshalledMsgs := [][]byte{}
 	for _, msg := range msgs {
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/filecoin-project/specs-actors/actors/builtin"
 	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
@@ -22,6 +23,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/proofs"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/proofs/verification"
 	appstate "github.com/filecoin-project/go-filecoin/internal/pkg/state"
 	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
@@ -53,7 +55,7 @@ func TestExpected_RunStateTransition_validateMining(t *testing.T) {
 		nextRoot, miners, m2w := setTree(ctx, t, kis, cistore, bstore, genesisBlock.StateRoot.Cid)
 
 		views := consensus.AsPowerStateViewer(appstate.NewViewer(cistore))
-		exp := consensus.NewExpected(cistore, bstore, th.NewFakeProcessor(), &views, th.BlockTimeTest, &consensus.FakeElectionMachine{}, &consensus.FakeTicketMachine{}, &proofs.ElectionPoster{})
+		exp := consensus.NewExpected(cistore, bstore, th.NewFakeProcessor(), &views, testParams(), &consensus.FakeElectionMachine{}, &consensus.FakeTicketMachine{}, &proofs.ElectionPoster{})
 
 		nextBlocks := requireMakeNBlocks(t, 3, pTipSet, nextRoot, types.EmptyReceiptsCID, miners, m2w, mockSigner)
 		tipSet := th.RequireNewTipSet(t, nextBlocks...)
@@ -72,7 +74,7 @@ func TestExpected_RunStateTransition_validateMining(t *testing.T) {
 
 		miners, minerToWorker := minerToWorkerFromAddrs(ctx, t, state.NewTree(cistore), vm.NewStorage(bstore), kis)
 		views := consensus.AsPowerStateViewer(appstate.NewViewer(cistore))
-		exp := consensus.NewExpected(cistore, bstore, consensus.NewDefaultProcessor(&consensus.FakeSampler{}), &views, th.BlockTimeTest, &consensus.FailingElectionValidator{}, &consensus.FakeTicketMachine{}, &proofs.ElectionPoster{})
+		exp := consensus.NewExpected(cistore, bstore, consensus.NewDefaultProcessor(&consensus.FakeSampler{}), &views, testParams(), &consensus.FailingElectionValidator{}, &consensus.FakeTicketMachine{}, &proofs.ElectionPoster{})
 
 		nextBlocks := requireMakeNBlocks(t, 3, pTipSet, genesisBlock.StateRoot.Cid, types.EmptyReceiptsCID, miners, minerToWorker, mockSigner)
 		tipSet := th.RequireNewTipSet(t, nextBlocks...)
@@ -114,7 +116,7 @@ func TestExpected_RunStateTransition_validateMining(t *testing.T) {
 		}
 		mockTicketGen := consensus.NewMockTicketMachine(isOneBack)
 
-		exp := consensus.NewExpected(cistore, bstore, th.NewFakeProcessor(), &views, th.BlockTimeTest, mockElection, mockTicketGen, &proofs.ElectionPoster{})
+		exp := consensus.NewExpected(cistore, bstore, th.NewFakeProcessor(), &views, testParams(), mockElection, mockTicketGen, &proofs.ElectionPoster{})
 
 		nextBlocks := requireMakeNBlocks(t, 3, pTipSet, nextRoot, types.EmptyReceiptsCID, miners, m2w, mockSigner)
 		tipSet := th.RequireNewTipSet(t, nextBlocks...)
@@ -131,7 +133,7 @@ func TestExpected_RunStateTransition_validateMining(t *testing.T) {
 
 		miners, minerToWorker := minerToWorkerFromAddrs(ctx, t, state.NewTree(cistore), vm.NewStorage(bstore), kis)
 		views := consensus.AsPowerStateViewer(appstate.NewViewer(cistore))
-		exp := consensus.NewExpected(cistore, bstore, th.NewFakeProcessor(), &views, th.BlockTimeTest, &consensus.FakeElectionMachine{}, &consensus.FakeTicketMachine{}, &proofs.ElectionPoster{})
+		exp := consensus.NewExpected(cistore, bstore, th.NewFakeProcessor(), &views, testParams(), &consensus.FakeElectionMachine{}, &consensus.FakeTicketMachine{}, &proofs.ElectionPoster{})
 
 		pTipSet := th.RequireNewTipSet(t, genesisBlock)
 		nextBlocks := requireMakeNBlocks(t, 3, pTipSet, genesisBlock.StateRoot.Cid, types.EmptyReceiptsCID, miners, minerToWorker, mockSigner)
@@ -160,7 +162,7 @@ func TestExpected_RunStateTransition_validateMining(t *testing.T) {
 
 		miners, minerToWorker := minerToWorkerFromAddrs(ctx, t, state.NewTree(cistore), vm.NewStorage(bstore), kis)
 		views := consensus.AsPowerStateViewer(appstate.NewViewer(cistore))
-		exp := consensus.NewExpected(cistore, bstore, th.NewFakeProcessor(), &views, th.BlockTimeTest, &consensus.FakeElectionMachine{}, &consensus.FakeTicketMachine{}, &proofs.ElectionPoster{})
+		exp := consensus.NewExpected(cistore, bstore, th.NewFakeProcessor(), &views, testParams(), &consensus.FakeElectionMachine{}, &consensus.FakeTicketMachine{}, &proofs.ElectionPoster{})
 
 		pTipSet := th.RequireNewTipSet(t, genesisBlock)
 		nextBlocks := requireMakeNBlocks(t, 3, pTipSet, genesisBlock.StateRoot.Cid, types.EmptyReceiptsCID, miners, minerToWorker, mockSigner)
@@ -196,7 +198,7 @@ func TestExpected_RunStateTransition_validateMining(t *testing.T) {
 
 		miners, minerToWorker := minerToWorkerFromAddrs(ctx, t, state.NewTree(cistore), vm.NewStorage(bstore), kis)
 		views := consensus.AsPowerStateViewer(appstate.NewViewer(cistore))
-		exp := consensus.NewExpected(cistore, bstore, th.NewFakeProcessor(), &views, th.BlockTimeTest, &consensus.FakeElectionMachine{}, &consensus.FailingTicketValidator{}, &proofs.ElectionPoster{})
+		exp := consensus.NewExpected(cistore, bstore, th.NewFakeProcessor(), &views, testParams(), &consensus.FakeElectionMachine{}, &consensus.FailingTicketValidator{}, &proofs.ElectionPoster{})
 
 		pTipSet := th.RequireNewTipSet(t, genesisBlock)
 		nextBlocks := requireMakeNBlocks(t, 3, pTipSet, genesisBlock.StateRoot.Cid, types.EmptyReceiptsCID, miners, minerToWorker, mockSigner)
@@ -216,7 +218,7 @@ func TestExpected_RunStateTransition_validateMining(t *testing.T) {
 
 		miners, minerToWorker := minerToWorkerFromAddrs(ctx, t, state.NewTree(cistore), vm.NewStorage(bstore), kis)
 		views := consensus.AsPowerStateViewer(appstate.NewViewer(cistore))
-		exp := consensus.NewExpected(cistore, bstore, th.NewFakeProcessor(), &views, th.BlockTimeTest, &consensus.FakeElectionMachine{}, &consensus.FakeTicketMachine{}, &proofs.ElectionPoster{})
+		exp := consensus.NewExpected(cistore, bstore, th.NewFakeProcessor(), &views, testParams(), &consensus.FakeElectionMachine{}, &consensus.FakeTicketMachine{}, &proofs.ElectionPoster{})
 
 		pTipSet := th.RequireNewTipSet(t, genesisBlock)
 		nextBlocks := requireMakeNBlocks(t, 3, pTipSet, genesisBlock.StateRoot.Cid, types.EmptyReceiptsCID, miners, minerToWorker, mockSigner)
@@ -231,6 +233,31 @@ func TestExpected_RunStateTransition_validateMining(t *testing.T) {
 		assert.EqualError(t, err, "block signature invalid")
 	})
 
+	t.Run("skips expensive checks for blocks already validated", func(t *testing.T) {
+		cistore, bstore := setupCborBlockstore()
+		genesisBlock, err := th.DefaultGenesis(cistore, bstore)
+		require.NoError(t, err)
+
+		pTipSet := th.RequireNewTipSet(t, genesisBlock)
+		nextRoot, miners, m2w := setTree(ctx, t, kis, cistore, bstore, genesisBlock.StateRoot.Cid)
+
+		views := consensus.AsPowerStateViewer(appstate.NewViewer(cistore))
+		election := &countingElectionMachine{}
+		exp := consensus.NewExpected(cistore, bstore, th.NewFakeProcessor(), &views, testParams(), election, &consensus.FakeTicketMachine{}, &proofs.ElectionPoster{})
+
+		nextBlocks := requireMakeNBlocks(t, 3, pTipSet, nextRoot, types.EmptyReceiptsCID, miners, m2w, mockSigner)
+		tipSet := th.RequireNewTipSet(t, nextBlocks...)
+
+		emptyBLSMessages, emptyMessages := emptyMessages(len(nextBlocks))
+		_, _, err = exp.RunStateTransition(ctx, tipSet, emptyBLSMessages, emptyMessages, []block.TipSet{pTipSet}, nextBlocks[0].ParentWeight, nextBlocks[0].StateRoot.Cid, nextBlocks[0].MessageReceipts.Cid)
+		require.NoError(t, err)
+		assert.Equal(t, 3, election.postVerifications)
+
+		_, _, err = exp.RunStateTransition(ctx, tipSet, emptyBLSMessages, emptyMessages, []block.TipSet{pTipSet}, nextBlocks[0].ParentWeight, nextBlocks[0].StateRoot.Cid, nextBlocks[0].MessageReceipts.Cid)
+		require.NoError(t, err)
+		assert.Equal(t, 3, election.postVerifications)
+	})
+
 	t.Run("returns nil + error when parent weight invalid", func(t *testing.T) {
 		cistore, bstore := setupCborBlockstore()
 		genesisBlock, err := th.DefaultGenesis(cistore, bstore)
@@ -238,7 +265,7 @@ func TestExpected_RunStateTransition_validateMining(t *testing.T) {
 
 		miners, minerToWorker := minerToWorkerFromAddrs(ctx, t, state.NewTree(cistore), vm.NewStorage(bstore), kis)
 		views := consensus.AsPowerStateViewer(appstate.NewViewer(cistore))
-		exp := consensus.NewExpected(cistore, bstore, th.NewFakeProcessor(), &views, th.BlockTimeTest, &consensus.FakeElectionMachine{}, &consensus.FakeTicketMachine{}, &proofs.ElectionPoster{})
+		exp := consensus.NewExpected(cistore, bstore, th.NewFakeProcessor(), &views, testParams(), &consensus.FakeElectionMachine{}, &consensus.FakeTicketMachine{}, &proofs.ElectionPoster{})
 
 		pTipSet := th.RequireNewTipSet(t, genesisBlock)
 		nextBlocks := requireMakeNBlocks(t, 3, pTipSet, genesisBlock.StateRoot.Cid, types.EmptyReceiptsCID, miners, minerToWorker, mockSigner)
@@ -253,6 +280,82 @@ func TestExpected_RunStateTransition_validateMining(t *testing.T) {
 	})
 }
 
+func TestExpectedElectionLookback(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	cistore, bstore := setupCborBlockstore()
+	signer, kis := types.NewMockSignersAndKeyInfo(1)
+	workerAddr, err := kis[0].Address()
+	require.NoError(t, err)
+	minerAddr := vmaddr.NewForTestGetter()()
+
+	stateRoot := types.CidFromString(t, "state")
+	view := appstate.NewFakeStateView(abi.NewStoragePower(4096))
+	view.Miners[minerAddr] = &appstate.FakeMinerState{
+		Worker:       workerAddr,
+		SectorSize:   1024,
+		ClaimedPower: abi.NewStoragePower(1024),
+	}
+	views := &consensus.FakePowerStateViewer{Views: map[cid.Cid]*appstate.FakeStateView{stateRoot: view}}
+
+	// Ancestors from the parent down, each with a distinct ticket.
+	var ancestors []block.TipSet
+	for i := 5; i > 0; i-- {
+		ancestors = append(ancestors, th.RequireNewTipSet(t, &block.Block{
+			Height:       abi.ChainEpoch(i),
+			ParentWeight: fbig.Zero(),
+			Ticket:       block.Ticket{VRFProof: []byte{byte(i)}},
+		}))
+	}
+	next := th.RequireSignedTestBlockFromTipSet(t, ancestors[0], stateRoot, types.EmptyReceiptsCID, 6, minerAddr, workerAddr, signer)
+	tipSet := th.RequireNewTipSet(t, next)
+
+	params := testParams()
+	params.ElectionLookback = 3
+	election := &recordingElectionMachine{}
+	exp := consensus.NewExpected(cistore, bstore, th.NewFakeProcessor(), views, params, election, &consensus.FakeTicketMachine{}, &proofs.ElectionPoster{})
+	assert.Equal(t, th.BlockTimeTest, exp.BlockTime())
+
+	emptyBLSMessages, emptyMessages := emptyMessages(1)
+	_, _, err = exp.RunStateTransition(ctx, tipSet, emptyBLSMessages, emptyMessages, ancestors, next.ParentWeight, stateRoot, types.EmptyReceiptsCID)
+	assert.EqualError(t, err, "PoStRandomness invalid")
+
+	expected, err := ancestors[params.ElectionLookback-1].MinTicket()
+	require.NoError(t, err)
+	assert.Equal(t, expected, election.electionTicket)
+}
+
+// recordingElectionMachine records the election ticket PoSt randomness is
+// verified against, and rejects it.
+type recordingElectionMachine struct {
+	consensus.FakeElectionMachine
+	electionTicket block.Ticket
+}
+
+func (rem *recordingElectionMachine) VerifyPoStRandomness(_ block.VRFPi, ticket block.Ticket, _ address.Address, _ uint64) bool {
+	rem.electionTicket = ticket
+	return false
+}
+
+// countingElectionMachine accepts all election proofs and counts PoSt verifications.
+type countingElectionMachine struct {
+	consensus.FakeElectionMachine
+	postVerifications int
+}
+
+func (cem *countingElectionMachine) VerifyPoSt(ep verification.PoStVerifier, allSectorInfos bls.SortedPublicSectorInfo, sectorSize uint64, challengeSeed []byte, proof []byte, candidates []block.EPoStCandidate, proverID address.Address) (bool, error) {
+	cem.postVerifications++
+	return true, nil
+}
+
+// testParams returns the default consensus parameters with the test block time.
+func testParams() consensus.Params {
+	params := consensus.DefaultParams()
+	params.BlockTime = th.BlockTimeTest
+	return params
+}
+
 func emptyMessages(numBlocks int) ([][]*types.UnsignedMessage, [][]*types.SignedMessage) {
 	var emptyBLSMessages [][]*types.UnsignedMessage
 	var emptyMessages [][]*types.SignedMessage
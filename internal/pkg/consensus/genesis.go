@@ -252,7 +252,7 @@ func MakeGenesisFunc(opts ...GenOption) GenesisInitFunc {
 		}
 
 		emptyBLSSignature := bls.Aggregate([]bls.Signature{})
-		emptyMeta := types.TxMeta{SecpRoot: e.NewCid(emptyAMTCid), BLSRoot: e.NewCid(emptyAMTCid)}
+		emptyMeta := types.TxMeta{SecpRoot: e.NewCid(emptyAMTCid), BLSRoot: e.NewCid(emptyAMTCid), Version: types.CurrentTxMetaVersion}
 		emptyMetaCid, err := cst.Put(ctx, emptyMeta)
 		if err != nil {
 			return nil, err
@@ -0,0 +1,65 @@
+package consensus
+
+import (
+	"time"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/builtin/power"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/sampling"
+)
+
+// Params gathers the consensus parameters shared by block production and
+// validation, so that testnets and tests can vary them together.
+type Params struct {
+	// ElectionLookback is the number of tipsets back from a new block at
+	// which its election ticket is sampled.
+	ElectionLookback abi.ChainEpoch
+	// BlockTime is the duration of an epoch.
+	BlockTime time.Duration
+	// ProvingPeriod and ChallengeDuration bound how far back sector
+	// challenges reach, and so how many ancestor rounds a state transition
+	// needs.
+	ProvingPeriod     abi.ChainEpoch
+	ChallengeDuration abi.ChainEpoch
+	// VRFInputs is the version of the data signed for tickets and PoSt
+	// randomness.
+	VRFInputs VRFInputVersion
+}
+
+// DefaultParams returns the mainnet consensus parameters.
+func DefaultParams() Params {
+	return Params{
+		ElectionLookback:  miner.ElectionLookback,
+		BlockTime:         clock.DefaultEpochDuration,
+		ProvingPeriod:     miner.ProvingPeriod,
+		ChallengeDuration: power.WindowedPostChallengeDuration,
+		VRFInputs:         VRFInputsUntagged,
+	}
+}
+
+// AncestorRoundsNeeded returns the number of rounds of the ancestor chain
+// needed to process all state transitions.
+func (p Params) AncestorRoundsNeeded() abi.ChainEpoch {
+	return max(p.ProvingPeriod+p.ChallengeDuration, p.ElectionLookback)
+}
+
+// ElectionTicket samples the election ticket for a new block from its
+// ancestors, ordered from the parent down.
+func (p Params) ElectionTicket(ancestors []block.TipSet) (block.Ticket, error) {
+	return sampling.SampleNthTicket(int(p.ElectionLookback-1), ancestors)
+}
+
+// ElectionEpoch returns the epoch of the tipset ElectionTicket samples from
+// `ancestors`, ordered from the parent down. The min ticket drawn from the
+// chain at this epoch is the election ticket.
+func (p Params) ElectionEpoch(ancestors []block.TipSet) (abi.ChainEpoch, error) {
+	ts, err := sampling.SampleNthTipSet(int(p.ElectionLookback-1), ancestors)
+	if err != nil {
+		return 0, err
+	}
+	return ts.Height()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
 	"go.opencensus.io/trace"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
@@ -36,6 +37,20 @@ type chainSampler interface {
 type DefaultProcessor struct {
 	actors  vm.ActorCodeLoader
 	sampler chainSampler
+	// hook is called around each message applied, if set.
+	hook vm.MessageHook
+	// roots records the state root after each message applied, if set.
+	roots StateRootRecorder
+}
+
+// StateRootRecorder keeps the state roots reached while applying the messages
+// of a tipset, so that a failed computation can resume from them.
+type StateRootRecorder interface {
+	// RecordStateRoot records the root after the message at `messageIndex`,
+	// in application order, of the tipset identified by `key`.
+	RecordStateRoot(key block.TipSetKey, messageIndex int, root cid.Cid)
+	// ForgetStateRoots drops the roots of a tipset once fully computed.
+	ForgetStateRoots(key block.TipSetKey)
 }
 
 var _ Processor = (*DefaultProcessor)(nil)
@@ -56,6 +71,20 @@ func NewConfiguredProcessor(actors vm.ActorCodeLoader, sampler chainSampler) *De
 	}
 }
 
+// SetMessageHook registers `hook` to be called before and after each message
+// the processor applies, e.g. to stream execution events to an indexer. It
+// must be set before the processor is used.
+func (p *DefaultProcessor) SetMessageHook(hook vm.MessageHook) {
+	p.hook = hook
+}
+
+// SetStateRootRecorder makes the processor record the state root after each
+// message it applies to `roots`, forgetting them once the tipset's state is
+// computed. It must be set before the processor is used.
+func (p *DefaultProcessor) SetStateRootRecorder(roots StateRootRecorder) {
+	p.roots = roots
+}
+
 // ProcessTipSet computes the state transition specified by the messages in all blocks in a TipSet.
 func (p *DefaultProcessor) ProcessTipSet(ctx context.Context, st state.Tree, vms vm.Storage, ts block.TipSet, msgs []vm.BlockMessagesInfo) (results []vm.MessageReceipt, err error) {
 	ctx, span := trace.StartSpan(ctx, "DefaultProcessor.ProcessTipSet")
@@ -77,9 +106,30 @@ func (p *DefaultProcessor) ProcessTipSet(ctx context.Context, st state.Tree, vms
 		sampler: p.sampler,
 		head:    parent,
 	}}
-	v := vm.NewVM(st, &vms)
+	var checkpoints vm.CheckpointHook
+	if p.roots != nil {
+		checkpoints = tipSetCheckpoints{key: ts.Key(), roots: p.roots}
+	}
+	v := vm.NewVMWithHooks(st, &vms, p.hook, checkpoints)
+
+	receipts, err := v.ApplyTipSetMessages(msgs, epoch, &rnd)
+	if err != nil {
+		return nil, err
+	}
+	if p.roots != nil {
+		p.roots.ForgetStateRoots(ts.Key())
+	}
+	return receipts, nil
+}
+
+// tipSetCheckpoints records the state roots of one tipset's messages.
+type tipSetCheckpoints struct {
+	key   block.TipSetKey
+	roots StateRootRecorder
+}
 
-	return v.ApplyTipSetMessages(msgs, epoch, &rnd)
+func (c tipSetCheckpoints) Checkpoint(messageIndex int, root cid.Cid) {
+	c.roots.RecordStateRoot(c.key, messageIndex, root)
 }
 
 // A chain sampler with a specific head tipset key.
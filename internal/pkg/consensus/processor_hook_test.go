@@ -0,0 +1,153 @@
+package consensus_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
+)
+
+// newProcessingFixture returns a genesis state with a funded sender, a tipset
+// on it, and `sends` messages sending value from the sender.
+func newProcessingFixture(t *testing.T, sends int) (*cborutil.IpldStore, blockstore.Blockstore, cid.Cid, block.TipSet, []*types.SignedMessage) {
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	cst := cborutil.NewIpldStore(bs)
+
+	signer, _ := types.NewMockSignersAndKeyInfo(2)
+	sender, recipient := signer.Addresses[0], signer.Addresses[1]
+	genesis, err := consensus.MakeGenesisFunc(consensus.ActorAccount(sender, abi.NewTokenAmount(1000000)))(cst, bs)
+	require.NoError(t, err)
+
+	var smsgs []*types.SignedMessage
+	for i := 0; i < sends; i++ {
+		msg := types.NewMeteredMessage(sender, recipient, uint64(i), abi.NewTokenAmount(100), builtin.MethodSend, nil, types.NewGasPrice(1), types.GasUnits(10000))
+		smsg, err := types.NewSignedMessage(*msg, &signer)
+		require.NoError(t, err)
+		smsgs = append(smsgs, smsg)
+	}
+
+	ts := th.RequireNewTipSet(t, &block.Block{
+		Miner:           vmaddr.RequireIDAddress(t, 100),
+		Ticket:          block.Ticket{VRFProof: []byte{0x1}},
+		Parents:         block.NewTipSetKey(genesis.Cid()),
+		ParentWeight:    fbig.Zero(),
+		Height:          1,
+		StateRoot:       genesis.StateRoot,
+		Messages:        e.NewCid(types.EmptyTxMetaCID),
+		MessageReceipts: e.NewCid(types.EmptyReceiptsCID),
+	})
+	return cst, bs, genesis.StateRoot.Cid, ts, smsgs
+}
+
+// recordingRoots records the state roots given to it.
+type recordingRoots struct {
+	roots     map[int]cid.Cid
+	forgotten []block.TipSetKey
+}
+
+func (r *recordingRoots) RecordStateRoot(key block.TipSetKey, messageIndex int, root cid.Cid) {
+	r.roots[messageIndex] = root
+}
+
+func (r *recordingRoots) ForgetStateRoots(key block.TipSetKey) {
+	r.forgotten = append(r.forgotten, key)
+}
+
+func TestProcessTipSetRecordsStateRoots(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	cst, bs, genRoot, ts, smsgs := newProcessingFixture(t, 2)
+	recipient := smsgs[0].Message.To
+
+	roots := &recordingRoots{roots: make(map[int]cid.Cid)}
+	processor := consensus.NewDefaultProcessor(&consensus.FakeSampler{})
+	processor.SetStateRootRecorder(roots)
+
+	st, err := state.NewTreeLoader().LoadStateTree(ctx, cst, genRoot)
+	require.NoError(t, err)
+	_, err = processor.ProcessTipSet(ctx, st, vm.NewStorage(bs), ts, []vm.BlockMessagesInfo{{
+		SECPMessages: smsgs,
+		BLSMessages:  []*types.UnsignedMessage{},
+		Miner:        ts.At(0).Miner,
+	}})
+	require.NoError(t, err)
+
+	// Each root holds the state after the sends applied so far.
+	require.Len(t, roots.roots, len(smsgs))
+	for i := range smsgs {
+		after, err := state.NewTreeLoader().LoadStateTree(ctx, cst, roots.roots[i])
+		require.NoError(t, err)
+		act, err := after.GetActor(ctx, recipient)
+		require.NoError(t, err)
+		assert.Equal(t, abi.NewTokenAmount(int64(100*(i+1))), act.Balance)
+	}
+	assert.Equal(t, []block.TipSetKey{ts.Key()}, roots.forgotten)
+}
+
+type hookCall struct {
+	after bool
+	msg   types.UnsignedMessage
+	code  exitcode.ExitCode
+}
+
+// recordingHook records the calls made to it in order.
+type recordingHook struct {
+	calls []hookCall
+}
+
+func (h *recordingHook) BeforeApply(msg *types.UnsignedMessage) {
+	h.calls = append(h.calls, hookCall{msg: *msg})
+}
+
+func (h *recordingHook) AfterApply(msg *types.UnsignedMessage, receipt vm.MessageReceipt) {
+	h.calls = append(h.calls, hookCall{after: true, msg: *msg, code: receipt.ExitCode})
+}
+
+func TestProcessTipSetCallsMessageHook(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	cst, bs, genRoot, ts, smsgs := newProcessingFixture(t, 2)
+
+	hook := &recordingHook{}
+	processor := consensus.NewDefaultProcessor(&consensus.FakeSampler{})
+	processor.SetMessageHook(hook)
+
+	st, err := state.NewTreeLoader().LoadStateTree(ctx, cst, genRoot)
+	require.NoError(t, err)
+	// The repeated message is applied, and hooked, once.
+	receipts, err := processor.ProcessTipSet(ctx, st, vm.NewStorage(bs), ts, []vm.BlockMessagesInfo{{
+		SECPMessages: []*types.SignedMessage{smsgs[0], smsgs[1], smsgs[0]},
+		BLSMessages:  []*types.UnsignedMessage{},
+		Miner:        ts.At(0).Miner,
+	}})
+	require.NoError(t, err)
+	require.Len(t, receipts, len(smsgs))
+
+	assert.Equal(t, []hookCall{
+		{msg: smsgs[0].Message},
+		{after: true, msg: smsgs[0].Message, code: receipts[0].ExitCode},
+		{msg: smsgs[1].Message},
+		{after: true, msg: smsgs[1].Message, code: receipts[1].ExitCode},
+	}, hook.calls)
+}
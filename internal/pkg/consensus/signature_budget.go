@@ -0,0 +1,154 @@
+package consensus
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+)
+
+// DefaultSignatureRate is the number of signature verifications per second
+// afforded to validating each peer's blocks by the expected consensus protocol.
+const DefaultSignatureRate = 5000
+
+// DefaultSignatureBurst is the number of signature verifications validating
+// a peer's blocks may make at once after a quiet period.
+const DefaultSignatureBurst = 10000
+
+// SignatureBudget is a token bucket rationing the CPU spent verifying block
+// and message signatures, so that a flood of blocks, valid or not, can't
+// starve the rest of the node. Each verification costs one token; tokens
+// accrue at a fixed rate up to a maximum burst.
+type SignatureBudget struct {
+	lk     sync.Mutex
+	clock  clock.Clock
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewSignatureBudget returns a full budget accruing `rate` verifications per
+// second up to `burst`. Both must be positive.
+func NewSignatureBudget(clk clock.Clock, rate float64, burst int) *SignatureBudget {
+	return &SignatureBudget{
+		clock:  clk,
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   clk.Now(),
+	}
+}
+
+// TryTake spends `cost` verifications if the budget allows, returning false
+// without spending anything otherwise.
+func (sb *SignatureBudget) TryTake(cost int) bool {
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+	sb.refill()
+	if sb.tokens < float64(cost) {
+		return false
+	}
+	sb.tokens -= float64(cost)
+	return true
+}
+
+// Wait blocks until the budget affords `cost` verifications and spends them,
+// or returns the context's error if it is done first. Costs above the burst
+// are charged as the burst so that they can eventually proceed.
+func (sb *SignatureBudget) Wait(ctx context.Context, cost int) error {
+	want := math.Min(float64(cost), sb.burst)
+	for {
+		sb.lk.Lock()
+		sb.refill()
+		if sb.tokens >= want {
+			sb.tokens -= want
+			sb.lk.Unlock()
+			return nil
+		}
+		delay := time.Duration(math.Ceil((want - sb.tokens) / sb.rate * float64(time.Second)))
+		sb.lk.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-sb.clock.After(delay):
+		}
+	}
+}
+
+// refill accrues tokens for the time elapsed since the last refill.
+// It must be called with the lock held.
+func (sb *SignatureBudget) refill() {
+	now := sb.clock.Now()
+	elapsed := now.Sub(sb.last).Seconds()
+	sb.tokens = math.Min(sb.burst, sb.tokens+elapsed*sb.rate)
+	sb.last = now
+}
+
+// full reports whether the budget has accrued its whole burst.
+func (sb *SignatureBudget) full() bool {
+	sb.lk.Lock()
+	defer sb.lk.Unlock()
+	sb.refill()
+	return sb.tokens >= sb.burst
+}
+
+// PeerSignatureBudgets keeps a SignatureBudget for each peer, so that a peer
+// sending many blocks can't spend the verifications afforded to others.
+type PeerSignatureBudgets struct {
+	lk      sync.Mutex
+	clock   clock.Clock
+	rate    float64
+	burst   int
+	budgets map[peer.ID]*SignatureBudget
+}
+
+// NewPeerSignatureBudgets returns budgets giving each peer `rate`
+// verifications per second up to `burst`.
+func NewPeerSignatureBudgets(clk clock.Clock, rate float64, burst int) *PeerSignatureBudgets {
+	return &PeerSignatureBudgets{
+		clock:   clk,
+		rate:    rate,
+		burst:   burst,
+		budgets: make(map[peer.ID]*SignatureBudget),
+	}
+}
+
+// For returns the budget of peer `p`, starting a full one if it has none.
+// Budgets that have refilled are dropped when a new one is started, as a
+// fresh budget is equivalent.
+func (pb *PeerSignatureBudgets) For(p peer.ID) *SignatureBudget {
+	pb.lk.Lock()
+	defer pb.lk.Unlock()
+	if budget, ok := pb.budgets[p]; ok {
+		return budget
+	}
+	for id, budget := range pb.budgets {
+		if budget.full() {
+			delete(pb.budgets, id)
+		}
+	}
+	budget := NewSignatureBudget(pb.clock, pb.rate, pb.burst)
+	pb.budgets[p] = budget
+	return budget
+}
+
+type senderKey struct{}
+
+// WithSender returns a context recording `p` as the peer that sent the blocks
+// validated under it, whose budget pays for their signature checks.
+func WithSender(ctx context.Context, p peer.ID) context.Context {
+	return context.WithValue(ctx, senderKey{}, p)
+}
+
+// senderOf returns the peer recorded by WithSender, or the empty ID for
+// blocks with no known sender.
+func senderOf(ctx context.Context) peer.ID {
+	p, _ := ctx.Value(senderKey{}).(peer.ID)
+	return p
+}
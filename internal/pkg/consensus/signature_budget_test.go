@@ -0,0 +1,111 @@
+package consensus_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestSignatureBudget(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("throttles beyond the configured rate", func(t *testing.T) {
+		clk := th.NewFakeClock(time.Unix(1234567890, 0))
+		budget := consensus.NewSignatureBudget(clk, 10, 5)
+
+		// The full burst is available at once.
+		for i := 0; i < 5; i++ {
+			assert.True(t, budget.TryTake(1))
+		}
+		assert.False(t, budget.TryTake(1))
+
+		// Tokens accrue at 10 per second, up to the burst.
+		clk.Advance(200 * time.Millisecond)
+		assert.True(t, budget.TryTake(2))
+		assert.False(t, budget.TryTake(1))
+
+		clk.Advance(time.Hour)
+		assert.True(t, budget.TryTake(5))
+		assert.False(t, budget.TryTake(1))
+	})
+
+	t.Run("failed take spends nothing", func(t *testing.T) {
+		clk := th.NewFakeClock(time.Unix(1234567890, 0))
+		budget := consensus.NewSignatureBudget(clk, 10, 5)
+
+		assert.False(t, budget.TryTake(6))
+		assert.True(t, budget.TryTake(5))
+	})
+
+	t.Run("wait blocks until tokens accrue", func(t *testing.T) {
+		clk := th.NewFakeClock(time.Unix(1234567890, 0))
+		budget := consensus.NewSignatureBudget(clk, 10, 5)
+		require.True(t, budget.TryTake(5))
+
+		done := make(chan error)
+		go func() {
+			done <- budget.Wait(context.Background(), 3)
+		}()
+
+		clk.BlockUntil(1)
+		select {
+		case <-done:
+			t.Fatal("wait returned before tokens accrued")
+		default:
+		}
+		// A second refills the burst, of which the waiter takes 3.
+		clk.Advance(time.Second)
+		require.NoError(t, <-done)
+		assert.True(t, budget.TryTake(2))
+		assert.False(t, budget.TryTake(1))
+	})
+
+	t.Run("wait charges oversized costs as the burst", func(t *testing.T) {
+		clk := th.NewFakeClock(time.Unix(1234567890, 0))
+		budget := consensus.NewSignatureBudget(clk, 10, 5)
+
+		require.NoError(t, budget.Wait(context.Background(), 100))
+		assert.False(t, budget.TryTake(1))
+	})
+
+	t.Run("wait returns when the context is done", func(t *testing.T) {
+		clk := th.NewFakeClock(time.Unix(1234567890, 0))
+		budget := consensus.NewSignatureBudget(clk, 10, 5)
+		require.True(t, budget.TryTake(5))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.Equal(t, context.Canceled, budget.Wait(ctx, 1))
+	})
+}
+
+func TestPeerSignatureBudgets(t *testing.T) {
+	tf.UnitTest(t)
+
+	clk := th.NewFakeClock(time.Unix(1234567890, 0))
+	budgets := consensus.NewPeerSignatureBudgets(clk, 10, 5)
+	flooder, other := peer.ID("flooder"), peer.ID("other")
+
+	t.Run("peers have separate budgets", func(t *testing.T) {
+		assert.True(t, budgets.For(flooder).TryTake(5))
+		assert.False(t, budgets.For(flooder).TryTake(1))
+
+		assert.True(t, budgets.For(other).TryTake(5))
+		assert.False(t, budgets.For(other).TryTake(1))
+	})
+
+	t.Run("exhausted budget kept for its peer", func(t *testing.T) {
+		clk.Advance(200 * time.Millisecond)
+		budgets.For(peer.ID("newcomer"))
+		assert.True(t, budgets.For(flooder).TryTake(2))
+		assert.False(t, budgets.For(flooder).TryTake(1))
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 
 	secp256k1 "github.com/ipsn/go-secp256k1"
+	"github.com/pkg/errors"
 
 	bls "github.com/filecoin-project/filecoin-ffi"
 )
@@ -59,6 +60,19 @@ func VerifyBLS(pubKey, msg, signature []byte) bool {
 	return bls.Verify(&blsSig, []bls.Digest{bls.Hash(msg)}, []bls.PublicKey{blsPubKey})
 }
 
+// AggregateBLS aggregates the given BLS signatures into a single signature.
+func AggregateBLS(signatures [][]byte) ([]byte, error) {
+	sigs := make([]bls.Signature, len(signatures))
+	for i, signature := range signatures {
+		copy(sigs[i][:], signature)
+	}
+	aggregate := bls.Aggregate(sigs)
+	if aggregate == nil {
+		return nil, errors.New("could not aggregate signatures")
+	}
+	return aggregate[:], nil
+}
+
 // VerifyBLSAggregate checks the given signature is a valid aggregate signature over all messages and public keys
 func VerifyBLSAggregate(pubKeys, msgs [][]byte, signature []byte) bool {
 	digests := []bls.Digest{}
@@ -70,6 +84,7 @@ func VerifyBLSAggregate(pubKeys, msgs [][]byte, signature []byte) bool {
 	for _, pubKey := range pubKeys {
 		var blsPubKey bls.PublicKey
 		copy(blsPubKey[:], pubKey)
+		keys = append(keys, blsPubKey)
 	}
 
 	var blsSig bls.Signature
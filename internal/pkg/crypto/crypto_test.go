@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"testing"
 
+	"github.com/filecoin-project/go-address"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -85,3 +87,46 @@ func TestBLSSigning(t *testing.T) {
 	require.False(t, valid)
 
 }
+
+func TestRegisterSignatureScheme(t *testing.T) {
+	tf.UnitTest(t)
+
+	const dummyType = crypto.SigType(42)
+	addr, err := address.NewIDAddress(100)
+	require.NoError(t, err)
+	data := []byte("data")
+
+	var calls int
+	require.NoError(t, crypto.RegisterSignatureScheme(dummyType, func(d []byte, a address.Address, sig []byte) error {
+		calls++
+		assert.Equal(t, data, d)
+		assert.Equal(t, addr, a)
+		if !bytes.Equal(sig, []byte("valid")) {
+			return errors.New("invalid dummy signature")
+		}
+		return nil
+	}))
+	defer func() {
+		require.NoError(t, crypto.UnregisterSignatureScheme(dummyType))
+	}()
+
+	assert.NoError(t, crypto.VerifySignature(data, addr, crypto.Signature{Type: dummyType, Data: []byte("valid")}))
+	assert.True(t, crypto.IsValidSignature(data, addr, crypto.Signature{Type: dummyType, Data: []byte("valid")}))
+	assert.False(t, crypto.IsValidSignature(data, addr, crypto.Signature{Type: dummyType, Data: []byte("forged")}))
+	assert.Equal(t, 3, calls)
+
+	// Unregistered schemes are rejected.
+	assert.Error(t, crypto.VerifySignature(data, addr, crypto.Signature{Type: crypto.SigType(43), Data: []byte("valid")}))
+
+	// Built-in schemes can't be replaced or removed.
+	forge := func([]byte, address.Address, []byte) error { return nil }
+	for _, builtin := range []crypto.SigType{crypto.SigTypeSecp256k1, crypto.SigTypeBLS} {
+		assert.Error(t, crypto.RegisterSignatureScheme(builtin, forge))
+		assert.Error(t, crypto.UnregisterSignatureScheme(builtin))
+	}
+	assert.False(t, crypto.IsValidSignature(data, addr, crypto.Signature{Type: crypto.SigTypeSecp256k1, Data: []byte("forged")}))
+
+	// An unregistered scheme is no longer verified.
+	require.NoError(t, crypto.UnregisterSignatureScheme(dummyType))
+	assert.Error(t, crypto.VerifySignature(data, addr, crypto.Signature{Type: dummyType, Data: []byte("valid")}))
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"math"
 
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/crypto"
@@ -16,8 +17,27 @@ type RandomnessSource interface {
 	Randomness(tag crypto.DomainSeparationTag, epoch abi.ChainEpoch, entropy []byte) (abi.Randomness, error)
 }
 
+// RandomSeed is a seed drawn from the chain from which randomness is derived.
 type RandomSeed []byte
 
+// Bytes returns the seed as a byte slice.
+func (r RandomSeed) Bytes() []byte {
+	return r
+}
+
+// Int63 reduces the seed to a non-negative int64 by reading its first eight
+// bytes as a big-endian integer and clearing the sign bit. Seeds shorter than
+// eight bytes are treated as if left-padded with zeros.
+func (r RandomSeed) Int63() int64 {
+	var buf [8]byte
+	if len(r) >= len(buf) {
+		copy(buf[:], r[:len(buf)])
+	} else {
+		copy(buf[len(buf)-len(r):], r)
+	}
+	return int64(binary.BigEndian.Uint64(buf[:]) & math.MaxInt64)
+}
+
 type ChainSampler interface {
 	Sample(epoch abi.ChainEpoch) (RandomSeed, error)
 }
@@ -32,7 +52,7 @@ func (c *ChainRandomnessSource) Randomness(tag crypto.DomainSeparationTag, epoch
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to sample chain for randomness")
 	}
-	return blendEntropy(tag, seed, entropy)
+	return BlendEntropy(tag, seed, entropy)
 }
 
 // A randomness source for use when computing genesis state (the state that the genesis block points to as parent state).
@@ -44,10 +64,12 @@ func (g *GenesisRandomnessSource) Randomness(tag crypto.DomainSeparationTag, epo
 		return nil, fmt.Errorf("invalid use of genesis randomness source for epoch %d", epoch)
 	}
 	seed := []byte{}
-	return blendEntropy(tag, seed, entropy)
+	return BlendEntropy(tag, seed, entropy)
 }
 
-func blendEntropy(tag crypto.DomainSeparationTag, seed RandomSeed, entropy []byte) (abi.Randomness, error) {
+// BlendEntropy hashes `seed` and `entropy` behind the domain separation tag
+// `tag`, so that the same seed yields unrelated randomness for each use.
+func BlendEntropy(tag crypto.DomainSeparationTag, seed RandomSeed, entropy []byte) (abi.Randomness, error) {
 	buffer := bytes.Buffer{}
 	err := binary.Write(&buffer, binary.BigEndian, int64(tag))
 	if err != nil {
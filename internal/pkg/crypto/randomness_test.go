@@ -0,0 +1,35 @@
+package crypto_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestRandomSeedInt63(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("reads the first eight bytes big-endian", func(t *testing.T) {
+		seed := crypto.RandomSeed{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0xff, 0xff}
+		assert.Equal(t, int64(0x0102030405060708), seed.Int63())
+	})
+
+	t.Run("clears the sign bit", func(t *testing.T) {
+		seed := crypto.RandomSeed{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+		assert.Equal(t, int64(math.MaxInt64), seed.Int63())
+	})
+
+	t.Run("short seeds are left-padded", func(t *testing.T) {
+		assert.Equal(t, int64(0x0102), crypto.RandomSeed{0x01, 0x02}.Int63())
+		assert.Equal(t, int64(0), crypto.RandomSeed{}.Int63())
+	})
+
+	t.Run("bytes returns the seed", func(t *testing.T) {
+		seed := crypto.RandomSeed{0x0a, 0x0b}
+		assert.Equal(t, []byte{0x0a, 0x0b}, seed.Bytes())
+	})
+}
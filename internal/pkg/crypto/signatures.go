@@ -2,11 +2,13 @@ package crypto
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/crypto"
 	log "github.com/ipfs/go-log"
 	"github.com/minio/blake2b-simd"
+	"github.com/pkg/errors"
 )
 
 //
@@ -40,17 +42,73 @@ func Sign(data []byte, secretKey []byte, sigtype SigType) (Signature, error) {
 	}, err
 }
 
+// SignatureVerifier returns an error unless `signature` is a valid signature
+// of `data` by the key belonging to `addr`.
+type SignatureVerifier func(data []byte, addr address.Address, signature []byte) error
+
+var (
+	verifiersLk sync.RWMutex
+	verifiers   = map[SigType]SignatureVerifier{
+		SigTypeSecp256k1: func(data []byte, addr address.Address, signature []byte) error {
+			if !IsValidSecpSignature(data, addr, signature) {
+				return errors.New("invalid secp256k1 signature")
+			}
+			return nil
+		},
+		SigTypeBLS: func(data []byte, addr address.Address, signature []byte) error {
+			if !IsValidBLSSignature(data, addr, signature) {
+				return errors.New("invalid bls signature")
+			}
+			return nil
+		},
+	}
+)
+
+// RegisterSignatureScheme registers `verifier` as the verifier of signatures
+// of type `t`, replacing any previously registered for it. The built-in
+// secp256k1 and BLS schemes can't be replaced.
+func RegisterSignatureScheme(t SigType, verifier SignatureVerifier) error {
+	if isBuiltinSigType(t) {
+		return errors.Errorf("signature type %d is built in", t)
+	}
+	verifiersLk.Lock()
+	defer verifiersLk.Unlock()
+	verifiers[t] = verifier
+	return nil
+}
+
+// UnregisterSignatureScheme removes the verifier registered for signatures of
+// type `t`, if any. The built-in schemes can't be removed.
+func UnregisterSignatureScheme(t SigType) error {
+	if isBuiltinSigType(t) {
+		return errors.Errorf("signature type %d is built in", t)
+	}
+	verifiersLk.Lock()
+	defer verifiersLk.Unlock()
+	delete(verifiers, t)
+	return nil
+}
+
+func isBuiltinSigType(t SigType) bool {
+	return t == SigTypeSecp256k1 || t == SigTypeBLS
+}
+
+// VerifySignature verifies `sig` over `data` for `addr` with the verifier
+// registered for the signature's type.
+func VerifySignature(data []byte, addr address.Address, sig Signature) error {
+	verifiersLk.RLock()
+	verifier, ok := verifiers[sig.Type]
+	verifiersLk.RUnlock()
+	if !ok {
+		return errors.Errorf("unknown signature type %d", sig.Type)
+	}
+	return verifier(data, addr, sig.Data)
+}
+
 // IsValidSignature cryptographically verifies that 'sig' is the signed hash of 'data' with
 // the public key belonging to `addr`.
 func IsValidSignature(data []byte, addr address.Address, sig Signature) bool {
-	switch addr.Protocol() {
-	case address.SECP256K1:
-		return sig.Type == SigTypeSecp256k1 && IsValidSecpSignature(data, addr, sig.Data)
-	case address.BLS:
-		return sig.Type == SigTypeBLS && IsValidBLSSignature(data, addr, sig.Data)
-	default:
-		return false
-	}
+	return VerifySignature(data, addr, sig) == nil
 }
 
 func IsValidSecpSignature(data []byte, addr address.Address, signature []byte) bool {
@@ -98,17 +98,15 @@ func (ib *Inbox) HandleNewHead(ctx context.Context, oldChain, newChain []block.T
 	var removeCids []cid.Cid
 	for _, tipset := range newChain {
 		for i := 0; i < tipset.Len(); i++ {
-			secpMsgs, _, err := ib.messageProvider.LoadMessages(ctx, tipset.At(i).Messages.Cid)
+			secpMsgs, blsMsgs, err := ib.messageProvider.LoadMessages(ctx, tipset.At(i).Messages.Cid)
 			if err != nil {
 				return err
 			}
-			for _, msg := range secpMsgs {
-				cid, err := msg.Cid()
-				if err != nil {
-					return err
-				}
-				removeCids = append(removeCids, cid)
+			cids, err := chain.MessageCids(secpMsgs, blsMsgs)
+			if err != nil {
+				return err
 			}
+			removeCids = append(removeCids, cids...)
 		}
 	}
 	for _, c := range removeCids {
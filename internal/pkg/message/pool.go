@@ -2,20 +2,30 @@ package message
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
 )
 
 var mpSize = metrics.NewInt64Gauge("message_pool_size", "The size of the message pool")
 
+// PendingKey is the key at which the pool's pending messages are persisted in the datastore.
+var PendingKey = datastore.NewKey("/mpool/pending")
+
 // PoolValidator defines a validator that ensures a message can go through the pool.
 type PoolValidator interface {
 	Validate(ctx context.Context, msg *types.SignedMessage) error
@@ -42,6 +52,15 @@ type timedmessage struct {
 	addedAt abi.ChainEpoch
 }
 
+// persistedMessage is the datastore representation of a pending message.
+type persistedMessage struct {
+	// control field for encoding struct as an array
+	_ struct{} `cbor:",toarray"`
+
+	Message *types.SignedMessage
+	AddedAt abi.ChainEpoch
+}
+
 type addressNonce struct {
 	addr  address.Address
 	nonce uint64
@@ -62,12 +81,14 @@ func NewPool(cfg *config.MessagePoolConfig, validator PoolValidator) *Pool {
 }
 
 // Add adds a message to the pool, tagged with the block height at which it was received.
-// Does nothing if the message is already in the pool.
+// Does nothing if the message is already in the pool. If the pool is full, the
+// message evicts the lowest gas price message among the highest-nonce pending
+// messages of each sender if it pays a higher one, and is rejected otherwise.
 func (pool *Pool) Add(ctx context.Context, msg *types.SignedMessage, height abi.ChainEpoch) (cid.Cid, error) {
 	pool.lk.Lock()
 	defer pool.lk.Unlock()
 
-	c, err := msg.Cid()
+	c, err := msg.OnChainCid()
 	if err != nil {
 		return cid.Undef, errors.Wrap(err, "failed to create CID")
 	}
@@ -78,16 +99,68 @@ func (pool *Pool) Add(ctx context.Context, msg *types.SignedMessage, height abi.
 		return c, nil
 	}
 
+	// When the pool is full, the new message may only displace the cheapest
+	// sender tail, and only by paying a strictly higher gas price.
+	evict := cid.Undef
+	if uint(len(pool.pending)) >= pool.cfg.MaxPoolSize {
+		cheapest, ok := pool.cheapestTail()
+		if !ok || !msg.Message.GasPrice.GreaterThan(pool.pending[cheapest].message.Message.GasPrice) {
+			return cid.Undef, errors.Errorf("message pool is full (%d messages)", pool.cfg.MaxPoolSize)
+		}
+		evict = cheapest
+	}
+
+	if floor := pool.SenderGasFloor(msg.Message.From); msg.Message.GasPrice.LessThan(floor) {
+		return cid.Undef, errors.Errorf("gas price %s below minimum %s for sender %s", msg.Message.GasPrice, floor, msg.Message.From)
+	}
+
 	if err = pool.validateMessage(ctx, msg); err != nil {
 		return cid.Undef, errors.Wrap(err, "validation error adding message to pool")
 	}
 
+	if evict.Defined() {
+		log.Infof("evicting message %s from full pool for higher fee message %s", evict, c)
+		delete(pool.addressNonces, newAddressNonce(pool.pending[evict].message))
+		delete(pool.pending, evict)
+	}
 	pool.pending[c] = &timedmessage{message: msg, addedAt: height}
 	pool.addressNonces[newAddressNonce(msg)] = true
 	mpSize.Set(ctx, int64(len(pool.pending)))
 	return c, nil
 }
 
+// AddWithFeeCap adds a message to the pool like Add, but first rejects it if
+// the sender's balance in `st` can't cover the message value plus its fee cap,
+// the gas limit at the gas price.
+func (pool *Pool) AddWithFeeCap(ctx context.Context, msg *types.SignedMessage, height abi.ChainEpoch, st state.Tree) (cid.Cid, error) {
+	act, err := st.GetActor(ctx, msg.Message.From)
+	if err != nil {
+		return cid.Undef, errors.Wrapf(err, "failed to load sender actor %s", msg.Message.From)
+	}
+	cost := maxCost(msg)
+	if cost.GreaterThan(act.Balance) {
+		return cid.Undef, errors.Errorf("sender %s balance %s can't cover worst-case message cost %s", msg.Message.From, act.Balance, cost)
+	}
+	return pool.Add(ctx, msg, height)
+}
+
+// SenderGasFloor returns the lowest gas price the pool admits from `addr`:
+// the global floor, see config.MessagePoolConfig.GasPriceFloor, raised for
+// senders flagged as abusive.
+func (pool *Pool) SenderGasFloor(addr address.Address) types.AttoFIL {
+	floor := pool.cfg.GasPriceFloor()
+	flaggedFloor := pool.cfg.FlaggedSenderMinGasPrice
+	if flaggedFloor.Nil() || !flaggedFloor.GreaterThan(floor) {
+		return floor
+	}
+	for _, flagged := range pool.cfg.FlaggedSenders {
+		if flagged == addr {
+			return flaggedFloor
+		}
+	}
+	return floor
+}
+
 // Pending returns all pending messages.
 func (pool *Pool) Pending() []*types.SignedMessage {
 	pool.lk.Lock()
@@ -101,6 +174,17 @@ func (pool *Pool) Pending() []*types.SignedMessage {
 	return out
 }
 
+// AddedAt returns the height at which the message with CID `c` was added to the pool.
+func (pool *Pool) AddedAt(c cid.Cid) (abi.ChainEpoch, bool) {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+	value, ok := pool.pending[c]
+	if !ok {
+		return 0, false
+	}
+	return value.addedAt, true
+}
+
 // Get retrieves a message from the pool by CID.
 func (pool *Pool) Get(c cid.Cid) (*types.SignedMessage, bool) {
 	pool.lk.RLock()
@@ -141,6 +225,59 @@ func (pool *Pool) LargestNonce(address address.Address) (largest uint64, found b
 	return
 }
 
+// SelectReady returns the pending messages which may be applied on top of
+// the state tree `st`, ordered by decreasing gas price. A sender's messages
+// are ready while their nonces run on contiguously from the sender's nonce in
+// `st` and its balance covers their value and gas cost. Messages from a
+// single sender are returned in nonce order. At most `maxCount` messages are
+// returned, with total gas limit at most `maxGas`.
+func (pool *Pool) SelectReady(ctx context.Context, st state.Tree, maxGas gas.Unit, maxCount int) []*types.SignedMessage {
+	bySender := make(map[address.Address][]*types.SignedMessage)
+	for _, m := range pool.Pending() {
+		bySender[m.Message.From] = append(bySender[m.Message.From], m)
+	}
+
+	var queues [][]*types.SignedMessage
+	for from, msgs := range bySender {
+		ready, err := readyMessages(ctx, st, from, msgs)
+		if err != nil {
+			log.Warnf("skipping pending messages from %s: %s", from, err)
+			continue
+		}
+		if len(ready) > 0 {
+			queues = append(queues, ready)
+		}
+	}
+
+	// Repeatedly take the best of the senders' next messages. A sender whose
+	// next message exceeds the remaining gas is dropped, since its later
+	// messages can't be applied without it.
+	var out []*types.SignedMessage
+	gasLeft := maxGas.AsBigInt()
+	for len(out) < maxCount && len(queues) > 0 {
+		best := 0
+		for i := 1; i < len(queues); i++ {
+			if queues[i][0].HigherFeeThan(queues[best][0]) {
+				best = i
+			}
+		}
+		next := queues[best][0]
+		limit := big.NewInt(int64(next.Message.GasLimit))
+		if limit.GreaterThan(gasLeft) {
+			queues = append(queues[:best], queues[best+1:]...)
+			continue
+		}
+		gasLeft = big.Sub(gasLeft, limit)
+		out = append(out, next)
+		if len(queues[best]) == 1 {
+			queues = append(queues[:best], queues[best+1:]...)
+		} else {
+			queues[best] = queues[best][1:]
+		}
+	}
+	return out
+}
+
 // PendingBefore returns the CIDs of messages added with height less than `minimumHeight`.
 func (pool *Pool) PendingBefore(minimumHeight abi.ChainEpoch) []cid.Cid {
 	pool.lk.RLock()
@@ -155,13 +292,54 @@ func (pool *Pool) PendingBefore(minimumHeight abi.ChainEpoch) []cid.Cid {
 	return cids
 }
 
-// validateMessage validates that too many messages aren't added to the pool and the ones that are
-// have a high probability of making it through processing.
-func (pool *Pool) validateMessage(ctx context.Context, message *types.SignedMessage) error {
-	if uint(len(pool.pending)) >= pool.cfg.MaxPoolSize {
-		return errors.Errorf("message pool is full (%d messages)", pool.cfg.MaxPoolSize)
+// Persist writes all pending messages to `ds` so they can be restored after
+// a restart.
+func (pool *Pool) Persist(ds repo.Datastore) error {
+	pool.lk.RLock()
+	msgs := make([]persistedMessage, 0, len(pool.pending))
+	for _, tm := range pool.pending {
+		msgs = append(msgs, persistedMessage{Message: tm.message, AddedAt: tm.addedAt})
+	}
+	pool.lk.RUnlock()
+
+	val, err := encoding.Encode(msgs)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode pending messages")
+	}
+	if err := ds.Put(PendingKey, val); err != nil {
+		return errors.Wrap(err, "failed to write pending messages")
+	}
+	return nil
+}
+
+// Restore adds the messages persisted in `ds` by Persist back to the pool.
+// Messages that no longer pass validation, e.g. because their nonce was used
+// or their sender can no longer afford them, are dropped.
+func (pool *Pool) Restore(ctx context.Context, ds repo.Datastore) error {
+	val, err := ds.Get(PendingKey)
+	if err == datastore.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to read pending messages")
+	}
+
+	var msgs []persistedMessage
+	if err := encoding.Decode(val, &msgs); err != nil {
+		return errors.Wrap(err, "failed to decode pending messages")
 	}
 
+	for _, pm := range msgs {
+		if _, err := pool.Add(ctx, pm.Message, pm.AddedAt); err != nil {
+			log.Infof("dropping persisted message from %s with nonce %d: %s", pm.Message.Message.From, pm.Message.Message.CallSeqNum, err)
+		}
+	}
+	return nil
+}
+
+// validateMessage validates that messages added to the pool have a high
+// probability of making it through processing.
+func (pool *Pool) validateMessage(ctx context.Context, message *types.SignedMessage) error {
 	// check that message with this nonce does not already exist
 	_, found := pool.addressNonces[newAddressNonce(message)]
 	if found {
@@ -171,3 +349,69 @@ func (pool *Pool) validateMessage(ctx context.Context, message *types.SignedMess
 	// check that the message is likely to succeed in processing
 	return pool.validator.Validate(ctx, message)
 }
+
+// cheapestTail returns the CID of the message paying the lowest gas price
+// among the highest-nonce pending messages of each sender, the first to be
+// evicted from a full pool. Only evicting sender tails keeps the remaining
+// messages of a sender free of nonce gaps. It must be called with the lock
+// held.
+func (pool *Pool) cheapestTail() (cid.Cid, bool) {
+	tails := make(map[address.Address]cid.Cid)
+	for c, tm := range pool.pending {
+		from := tm.message.Message.From
+		tail, ok := tails[from]
+		if !ok || tm.message.Message.CallSeqNum > pool.pending[tail].message.Message.CallSeqNum {
+			tails[from] = c
+		}
+	}
+
+	var lowest cid.Cid
+	var lowestMsg *types.SignedMessage
+	for _, c := range tails {
+		msg := pool.pending[c].message
+		if lowestMsg == nil || lowestMsg.HigherFeeThan(msg) {
+			lowest, lowestMsg = c, msg
+		}
+	}
+	return lowest, lowestMsg != nil
+}
+
+// readyMessages returns the prefix of `msgs`, all sent by `from`, which may be
+// applied in nonce order to the sender's actor in `st`.
+func readyMessages(ctx context.Context, st state.Tree, from address.Address, msgs []*types.SignedMessage) ([]*types.SignedMessage, error) {
+	act, err := st.GetActor(ctx, from)
+	if state.IsActorNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load sender actor")
+	}
+
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].Message.CallSeqNum < msgs[j].Message.CallSeqNum })
+	nonce := act.CallSeqNum
+	balance := act.Balance
+	var ready []*types.SignedMessage
+	for _, m := range msgs {
+		if m.Message.CallSeqNum < nonce {
+			// Already applied; the pool will drop it when it sees the block.
+			continue
+		}
+		if m.Message.CallSeqNum > nonce {
+			break
+		}
+		cost := maxCost(m)
+		if cost.GreaterThan(balance) {
+			break
+		}
+		balance = big.Sub(balance, cost)
+		nonce++
+		ready = append(ready, m)
+	}
+	return ready, nil
+}
+
+// maxCost returns the most a message can cost its sender: its value plus the
+// fee for consuming its entire gas limit.
+func maxCost(msg *types.SignedMessage) abi.TokenAmount {
+	return big.Add(msg.Message.Value, gas.NewLegacyGas(msg.Message.GasLimit).ToTokens(msg.Message.GasPrice))
+}
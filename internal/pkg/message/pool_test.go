@@ -5,16 +5,25 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
 	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
 )
 
 var mockSigner, _ = types.NewMockSignersAndKeyInfo(10)
@@ -207,6 +216,281 @@ func TestLargestNonce(t *testing.T) {
 	})
 }
 
+func TestMessagePoolPersistRestore(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := repo.NewInMemoryRepo().Datastore()
+
+	t.Run("restoring without persisted messages is a nop", func(t *testing.T) {
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		require.NoError(t, pool.Restore(context.Background(), ds))
+		assert.Len(t, pool.Pending(), 0)
+	})
+
+	t.Run("valid messages survive and stale ones are dropped", func(t *testing.T) {
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		stale := mustSetNonce(mockSigner, newSignedMessage(), 0)
+		fresh1 := mustSetNonce(mockSigner, newSignedMessage(), 1)
+		fresh2 := mustSetNonce(mockSigner, newSignedMessage(), 2)
+		reqAdd(t, pool, 5, stale, fresh1, fresh2)
+		require.NoError(t, pool.Persist(ds))
+
+		// nonce 0 was mined while the node was down
+		restored := message.NewPool(config.NewDefaultConfig().Mpool, &minNonceValidator{minNonce: 1})
+		require.NoError(t, restored.Restore(context.Background(), ds))
+		assert.Len(t, restored.Pending(), 2)
+
+		for _, msg := range []*types.SignedMessage{fresh1, fresh2} {
+			c, err := msg.Cid()
+			require.NoError(t, err)
+			got, ok := restored.Get(c)
+			require.True(t, ok)
+			assert.Equal(t, msg, got)
+		}
+		staleCid, err := stale.Cid()
+		require.NoError(t, err)
+		_, ok := restored.Get(staleCid)
+		assert.False(t, ok)
+
+		// the height the messages were received at is preserved
+		assert.Len(t, restored.PendingBefore(5), 0)
+		assert.Len(t, restored.PendingBefore(6), 2)
+	})
+}
+
+// minNonceValidator rejects messages with a nonce below minNonce.
+type minNonceValidator struct {
+	minNonce uint64
+}
+
+func (v *minNonceValidator) Validate(ctx context.Context, msg *types.SignedMessage) error {
+	if msg.Message.CallSeqNum < v.minNonce {
+		return errors.Errorf("nonce %d too low, expected at least %d", msg.Message.CallSeqNum, v.minNonce)
+	}
+	return nil
+}
+
+func TestMessagePoolSelectReady(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	a, b, c := mockSigner.Addresses[0], mockSigner.Addresses[1], mockSigner.Addresses[2]
+	to := mockSigner.Addresses[3]
+	send := func(from address.Address, nonce uint64, value, price int64) *types.SignedMessage {
+		msg := types.NewMeteredMessage(from, to, nonce, abi.NewTokenAmount(value), builtin.MethodSend, nil, types.NewGasPrice(price), types.GasUnits(1))
+		smsg, err := signMessage(mockSigner, *msg)
+		require.NoError(t, err)
+		return smsg
+	}
+
+	actorA := actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(100))
+	actorA.CallSeqNum = 1
+	actorB := actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(20))
+	cst := cborutil.NewIpldStore(blockstore.NewBlockstore(datastore.NewMapDatastore()))
+	_, st := th.RequireMakeStateTree(t, cst, map[address.Address]*actor.Actor{a: actorA, b: actorB})
+
+	// a has already applied nonce 0 and is missing nonce 3.
+	a0, a1, a2, a4 := send(a, 0, 1, 2), send(a, 1, 1, 2), send(a, 2, 1, 2), send(a, 4, 1, 2)
+	// b can afford only one message costing 6 + 5*1.
+	b0, b1 := send(b, 0, 6, 5), send(b, 1, 6, 5)
+	// c has no actor.
+	c0 := send(c, 0, 1, 9)
+
+	pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+	reqAdd(t, pool, 0, a4, a2, a1, a0, b1, b0, c0)
+
+	t.Run("ready messages ordered by fee", func(t *testing.T) {
+		ready := pool.SelectReady(ctx, st, gas.NewGas(100), 100)
+		assert.Equal(t, []*types.SignedMessage{b0, a1, a2}, ready)
+	})
+
+	t.Run("count budget", func(t *testing.T) {
+		ready := pool.SelectReady(ctx, st, gas.NewGas(100), 2)
+		assert.Equal(t, []*types.SignedMessage{b0, a1}, ready)
+	})
+
+	t.Run("gas budget", func(t *testing.T) {
+		ready := pool.SelectReady(ctx, st, gas.NewGas(1), 100)
+		assert.Equal(t, []*types.SignedMessage{b0}, ready)
+	})
+
+	t.Run("pool unchanged", func(t *testing.T) {
+		assert.Len(t, pool.Pending(), 7)
+	})
+}
+
+func TestMessagePoolAddWithFeeCap(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	from, to := mockSigner.Addresses[0], mockSigner.Addresses[1]
+	send := func(nonce uint64, value, price int64, limit types.GasUnits) *types.SignedMessage {
+		msg := types.NewMeteredMessage(from, to, nonce, abi.NewTokenAmount(value), builtin.MethodSend, nil, types.NewGasPrice(price), limit)
+		smsg, err := signMessage(mockSigner, *msg)
+		require.NoError(t, err)
+		return smsg
+	}
+
+	cst := cborutil.NewIpldStore(blockstore.NewBlockstore(datastore.NewMapDatastore()))
+	_, st := th.RequireMakeStateTree(t, cst, map[address.Address]*actor.Actor{
+		from: actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(100)),
+	})
+
+	t.Run("affordable message added", func(t *testing.T) {
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		// Costs exactly the balance: 10 + 9*10.
+		msg := send(0, 10, 9, 10)
+		c, err := pool.AddWithFeeCap(ctx, msg, 0, st)
+		require.NoError(t, err)
+		got, found := pool.Get(c)
+		require.True(t, found)
+		assert.Equal(t, msg, got)
+	})
+
+	t.Run("unaffordable message rejected", func(t *testing.T) {
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		// The value alone is affordable but the fee cap is not: 10 + 10*10.
+		_, err := pool.AddWithFeeCap(ctx, send(0, 10, 10, 10), 0, st)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "can't cover worst-case message cost 110")
+		assert.Len(t, pool.Pending(), 0)
+	})
+
+	t.Run("unknown sender rejected", func(t *testing.T) {
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		msg := types.NewMeteredMessage(to, from, 0, abi.NewTokenAmount(0), builtin.MethodSend, nil, types.NewGasPrice(1), types.GasUnits(1))
+		smsg, err := signMessage(mockSigner, *msg)
+		require.NoError(t, err)
+		_, err = pool.AddWithFeeCap(ctx, smsg, 0, st)
+		assert.Error(t, err)
+	})
+}
+
+func TestMessagePoolEviction(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	to := mockSigner.Addresses[9]
+	send := func(from address.Address, price int64) *types.SignedMessage {
+		msg := types.NewMeteredMessage(from, to, 0, abi.NewTokenAmount(1), builtin.MethodSend, nil, types.NewGasPrice(price), types.GasUnits(1))
+		smsg, err := signMessage(mockSigner, *msg)
+		require.NoError(t, err)
+		return smsg
+	}
+	newFullPool := func() (*message.Pool, []*types.SignedMessage) {
+		mpoolCfg := config.NewDefaultConfig().Mpool
+		mpoolCfg.MaxPoolSize = 3
+		pool := message.NewPool(mpoolCfg, th.NewMockMessagePoolValidator())
+		msgs := []*types.SignedMessage{
+			send(mockSigner.Addresses[0], 5),
+			send(mockSigner.Addresses[1], 2),
+			send(mockSigner.Addresses[2], 8),
+		}
+		reqAdd(t, pool, 0, msgs...)
+		return pool, msgs
+	}
+
+	t.Run("higher fee message evicts cheapest", func(t *testing.T) {
+		pool, msgs := newFullPool()
+		pricey := send(mockSigner.Addresses[3], 3)
+		reqAdd(t, pool, 0, pricey)
+		assert.ElementsMatch(t, []*types.SignedMessage{msgs[0], msgs[2], pricey}, pool.Pending())
+
+		// The evicted message's sender and nonce may be used again.
+		_, err := pool.Add(ctx, send(mockSigner.Addresses[1], 4), 0)
+		assert.NoError(t, err)
+	})
+
+	t.Run("lower fee message rejected", func(t *testing.T) {
+		pool, msgs := newFullPool()
+		_, err := pool.Add(ctx, send(mockSigner.Addresses[3], 1), 0)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "message pool is full")
+		assert.ElementsMatch(t, msgs, pool.Pending())
+	})
+
+	t.Run("equal fee message rejected", func(t *testing.T) {
+		pool, msgs := newFullPool()
+		_, err := pool.Add(ctx, send(mockSigner.Addresses[3], 2), 0)
+		require.Error(t, err)
+		assert.ElementsMatch(t, msgs, pool.Pending())
+	})
+
+	t.Run("only sender tails evicted", func(t *testing.T) {
+		mpoolCfg := config.NewDefaultConfig().Mpool
+		mpoolCfg.MaxPoolSize = 3
+		pool := message.NewPool(mpoolCfg, th.NewMockMessagePoolValidator())
+		sendNonce := func(from address.Address, nonce uint64, price int64) *types.SignedMessage {
+			msg := types.NewMeteredMessage(from, to, nonce, abi.NewTokenAmount(1), builtin.MethodSend, nil, types.NewGasPrice(price), types.GasUnits(1))
+			smsg, err := signMessage(mockSigner, *msg)
+			require.NoError(t, err)
+			return smsg
+		}
+		// The cheapest message is followed by a pricier one from its sender.
+		first := sendNonce(mockSigner.Addresses[0], 0, 1)
+		tail := sendNonce(mockSigner.Addresses[0], 1, 5)
+		other := sendNonce(mockSigner.Addresses[1], 0, 3)
+		reqAdd(t, pool, 0, first, tail, other)
+
+		pricey := sendNonce(mockSigner.Addresses[2], 0, 4)
+		reqAdd(t, pool, 0, pricey)
+		assert.ElementsMatch(t, []*types.SignedMessage{first, tail, pricey}, pool.Pending())
+	})
+
+	t.Run("invalid message evicts nothing", func(t *testing.T) {
+		pool, msgs := newFullPool()
+		// Reuses the nonce of a pending message from the same sender.
+		_, err := pool.Add(ctx, send(mockSigner.Addresses[0], 9), 0)
+		require.Error(t, err)
+		assert.ElementsMatch(t, msgs, pool.Pending())
+	})
+}
+
+func TestMessagePoolSenderGasFloor(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	to := mockSigner.Addresses[9]
+	flagged, normal := mockSigner.Addresses[0], mockSigner.Addresses[1]
+	send := func(from address.Address, price int64) *types.SignedMessage {
+		msg := types.NewMeteredMessage(from, to, 0, abi.NewTokenAmount(1), builtin.MethodSend, nil, types.NewGasPrice(price), types.GasUnits(1))
+		smsg, err := signMessage(mockSigner, *msg)
+		require.NoError(t, err)
+		return smsg
+	}
+	newPool := func() *message.Pool {
+		mpoolCfg := config.NewDefaultConfig().Mpool
+		mpoolCfg.MinGasPrice = types.NewGasPrice(2)
+		mpoolCfg.FlaggedSenders = []address.Address{flagged}
+		mpoolCfg.FlaggedSenderMinGasPrice = types.NewGasPrice(10)
+		return message.NewPool(mpoolCfg, th.NewMockMessagePoolValidator())
+	}
+
+	t.Run("floors", func(t *testing.T) {
+		pool := newPool()
+		assert.Equal(t, types.NewGasPrice(10), pool.SenderGasFloor(flagged))
+		assert.Equal(t, types.NewGasPrice(2), pool.SenderGasFloor(normal))
+	})
+
+	t.Run("flagged sender requires higher price", func(t *testing.T) {
+		pool := newPool()
+		_, err := pool.Add(ctx, send(flagged, 5), 0)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "below minimum")
+		assert.Empty(t, pool.Pending())
+
+		reqAdd(t, pool, 0, send(flagged, 10))
+	})
+
+	t.Run("normal sender uses global floor", func(t *testing.T) {
+		pool := newPool()
+		_, err := pool.Add(ctx, send(normal, 1), 0)
+		assert.Error(t, err)
+
+		reqAdd(t, pool, 0, send(normal, 5))
+	})
+}
+
 func mustSetNonce(signer types.Signer, message *types.SignedMessage, nonce uint64) *types.SignedMessage {
 	return mustResignMessage(signer, message, func(m *types.UnsignedMessage) {
 		m.CallSeqNum = nonce
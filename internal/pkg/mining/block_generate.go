@@ -10,13 +10,16 @@ import (
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
 	"github.com/pkg/errors"
 
 	bls "github.com/filecoin-project/filecoin-ffi"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
 	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
 )
 
 // Generate returns a new block created from the messages in the pool.
@@ -60,34 +63,29 @@ func (w *DefaultWorker) Generate(
 
 	// Construct list of message candidates for inclusion.
 	// These messages will be processed, and those that fail excluded from the block.
-	pending := w.messageSource.Pending()
-	mq := NewMessageQueue(pending)
-	candidateMsgs := orderMessageCandidates(mq.Drain())
+	candidateMsgs, err := w.selectMessages(ctx, baseTipSet, blockHeight)
+	if err != nil {
+		return nil, errors.Wrap(err, "select messages")
+	}
+	candidateMsgs = orderMessageCandidates(candidateMsgs)
 
 	// Dragons: ask something to select and order messages to include
 
 	var blsAccepted []*types.SignedMessage
-	var secpAccepted []*types.SignedMessage
-
-	// Align the results with the candidate signed messages to accumulate the messages lists
-	// to include in the block, and handle failed messages.
 	for _, msg := range candidateMsgs {
 		if msg.Message.From.Protocol() == address.BLS {
 			blsAccepted = append(blsAccepted, msg)
-		} else {
-			secpAccepted = append(secpAccepted, msg)
 		}
-
 	}
 
 	// Create an aggregage signature for messages
-	unwrappedBLSMessages, blsAggregateSig, err := aggregateBLS(blsAccepted)
+	_, blsAggregateSig, err := aggregateBLS(blsAccepted)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not aggregate bls messages")
 	}
 
 	// Persist messages to ipld storage
-	txMetaCid, err := w.messageStore.StoreMessages(ctx, secpAccepted, unwrappedBLSMessages)
+	txMetaCid, err := w.messageStore.ComputeMessageRoot(ctx, candidateMsgs)
 	if err != nil {
 		return nil, errors.Wrap(err, "error persisting messages")
 	}
@@ -118,22 +116,76 @@ func (w *DefaultWorker) Generate(
 		BLSAggregateSig: blsAggregateSig,
 	}
 
-	view, err := w.api.PowerStateView(baseTipSet.Key())
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to read state view")
-	}
-	_, workerAddr, err := view.MinerControlAddresses(ctx, w.minerAddr)
+	workerAddr, err := w.WorkerAddressAt(ctx, baseTipSet.Key())
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to read workerAddr during block generation")
 	}
-	next.BlockSig, err = w.workerSigner.SignBytes(next.SignatureData(), workerAddr)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to sign block")
+	if err := block.SignBlock(w.workerSigner, next, workerAddr); err != nil {
+		return nil, err
 	}
 
 	return next, nil
 }
 
+// selectMessages returns the pending messages to include in a block at
+// `height` on `base`. Under the HighestFee policy a source able to select
+// ready messages is asked for those fitting within the block limits.
+// Messages already included in recent ancestors of `base` are dropped.
+func (w *DefaultWorker) selectMessages(ctx context.Context, base block.TipSet, height abi.ChainEpoch) ([]*types.SignedMessage, error) {
+	var selected []*types.SignedMessage
+	if ready, ok := w.messageSource.(ReadyMessageSource); ok && w.selection == HighestFee {
+		st, err := w.getStateTree(ctx, base.Key())
+		if err != nil {
+			return nil, errors.Wrap(err, "get state tree")
+		}
+		selected = ready.SelectReady(ctx, st, gas.NewLegacyGas(types.BlockGasLimit), types.BlockMessageLimit)
+	} else {
+		ages, _ := w.messageSource.(MessageAgeSource)
+		selected = SelectMessages(w.selection, w.messageSource.Pending(), height, ages)
+	}
+	return w.dropIncluded(ctx, base, height, selected)
+}
+
+// dropIncluded returns `msgs` without those already included in the
+// tipsets up to the worker's recent message depth below and including `base`.
+func (w *DefaultWorker) dropIncluded(ctx context.Context, base block.TipSet, height abi.ChainEpoch, msgs []*types.SignedMessage) ([]*types.SignedMessage, error) {
+	if w.messages == nil || len(msgs) == 0 {
+		return msgs, nil
+	}
+	ancestors, err := w.getAncestors(ctx, base, height)
+	if err != nil {
+		return nil, errors.Wrap(err, "get ancestors")
+	}
+	if len(ancestors) > w.recentDepth {
+		ancestors = ancestors[:w.recentDepth]
+	}
+
+	included := make(map[cid.Cid]struct{})
+	for _, ts := range ancestors {
+		cids, err := chain.TipSetMessageCids(ctx, w.messages, ts)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range cids {
+			included[c] = struct{}{}
+		}
+	}
+
+	var kept []*types.SignedMessage
+	for _, m := range msgs {
+		c, err := m.OnChainCid()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := included[c]; ok {
+			log.Debugf("dropping message %s already included in a recent block", c)
+			continue
+		}
+		kept = append(kept, m)
+	}
+	return kept, nil
+}
+
 func aggregateBLS(blsMessages []*types.SignedMessage) ([]*types.UnsignedMessage, crypto.Signature, error) {
 	var sigs []bls.Signature
 	var unwrappedMsgs []*types.UnsignedMessage
@@ -0,0 +1,200 @@
+package mining
+
+import (
+	"context"
+	"testing"
+
+	bls "github.com/filecoin-project/filecoin-ffi"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	appstate "github.com/filecoin-project/go-filecoin/internal/pkg/state"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+// pendingSource is a message source with a fixed set of pending messages.
+type pendingSource []*types.SignedMessage
+
+func (s pendingSource) Pending() []*types.SignedMessage {
+	return s
+}
+
+func (s pendingSource) Remove(cid.Cid) {}
+
+func TestSelectMessagesDropsIncluded(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signer, _ := types.NewMockSignersAndKeyInfo(1)
+	newMsg := types.NewSignedMessageForTestGetter(signer)
+	old, recent, pending := newMsg(), newMsg(), newMsg()
+
+	messages := chain.NewMessageStore(blockstore.NewBlockstore(datastore.NewMapDatastore()))
+	// tipSetWith returns a tipset at `height` whose block includes `msgs`.
+	tipSetWith := func(height abi.ChainEpoch, msgs ...*types.SignedMessage) block.TipSet {
+		meta, err := messages.StoreMessages(ctx, msgs, nil)
+		require.NoError(t, err)
+		ts, err := block.NewTipSet(&block.Block{Height: height, Messages: e.NewCid(meta)})
+		require.NoError(t, err)
+		return ts
+	}
+	// Ancestors from the base down.
+	ancestors := []block.TipSet{
+		tipSetWith(3),
+		tipSetWith(2, recent),
+		tipSetWith(1, old),
+	}
+
+	newWorker := func(provider chain.MessageProvider, depth int) *DefaultWorker {
+		return NewDefaultWorker(WorkerParameters{
+			MessageSource:      pendingSource{old, recent, pending},
+			MessageProvider:    provider,
+			RecentMessageDepth: depth,
+			SelectionPolicy:    FIFO,
+			GetAncestors: func(context.Context, block.TipSet, abi.ChainEpoch) ([]block.TipSet, error) {
+				return ancestors, nil
+			},
+		})
+	}
+
+	t.Run("messages in recent ancestors dropped", func(t *testing.T) {
+		selected, err := newWorker(messages, 3).selectMessages(ctx, ancestors[0], 4)
+		require.NoError(t, err)
+		assert.Equal(t, []*types.SignedMessage{pending}, selected)
+	})
+
+	t.Run("ancestors beyond depth not checked", func(t *testing.T) {
+		selected, err := newWorker(messages, 2).selectMessages(ctx, ancestors[0], 4)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []*types.SignedMessage{old, pending}, selected)
+	})
+
+	t.Run("nothing dropped without a provider", func(t *testing.T) {
+		selected, err := newWorker(nil, 3).selectMessages(ctx, ancestors[0], 4)
+		require.NoError(t, err)
+		assert.Len(t, selected, 3)
+	})
+}
+
+func TestAggregateBLS(t *testing.T) {
+	tf.UnitTest(t)
+
+	signer := types.NewMockSigner(types.MustGenerateMixedKeyInfo(2, 2))
+	blsA, secp, blsB := signer.Addresses[0], signer.Addresses[1], signer.Addresses[2]
+	newMsg := func(from address.Address, nonce uint64) *types.SignedMessage {
+		msg := types.NewMeteredMessage(from, secp, nonce, types.ZeroAttoFIL, builtin.MethodSend, []byte{}, types.NewAttoFILFromFIL(1), 300)
+		smsg, err := types.NewSignedMessage(*msg, signer)
+		require.NoError(t, err)
+		return smsg
+	}
+	verify := func(msgs []*types.UnsignedMessage, sig crypto.Signature) bool {
+		var pubKeys, data [][]byte
+		for _, msg := range msgs {
+			msgBytes, err := msg.Marshal()
+			require.NoError(t, err)
+			pubKeys = append(pubKeys, msg.From.Payload())
+			data = append(data, msgBytes)
+		}
+		return crypto.VerifyBLSAggregate(pubKeys, data, sig.Data)
+	}
+
+	t.Run("aggregate of bls messages verifies", func(t *testing.T) {
+		unwrapped, sig, err := aggregateBLS([]*types.SignedMessage{newMsg(blsA, 0), newMsg(blsB, 0), newMsg(blsA, 1)})
+		require.NoError(t, err)
+		require.Len(t, unwrapped, 3)
+		assert.Equal(t, crypto.SigTypeBLS, sig.Type)
+		assert.NotEmpty(t, sig.Data)
+		assert.True(t, verify(unwrapped, sig))
+		assert.False(t, verify(unwrapped[:2], sig))
+	})
+
+	t.Run("no bls messages gives empty aggregate", func(t *testing.T) {
+		unwrapped, sig, err := aggregateBLS(nil)
+		require.NoError(t, err)
+		assert.Empty(t, unwrapped)
+		assert.Equal(t, crypto.SigTypeBLS, sig.Type)
+		assert.Equal(t, (*bls.Aggregate([]bls.Signature{}))[:], sig.Data)
+	})
+
+	t.Run("non-bls signature rejected", func(t *testing.T) {
+		_, _, err := aggregateBLS([]*types.SignedMessage{newMsg(secp, 0)})
+		assert.Error(t, err)
+	})
+}
+
+// fixedRoots reports the same state and receipt roots for every tipset.
+type fixedRoots struct{}
+
+func (fixedRoots) GetTipSetStateRoot(block.TipSetKey) (cid.Cid, error) {
+	return types.EmptyMessagesCID, nil
+}
+
+func (fixedRoots) GetTipSetReceiptsRoot(block.TipSetKey) (cid.Cid, error) {
+	return types.EmptyReceiptsCID, nil
+}
+
+func TestGenerateMessageRoot(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signer := types.NewMockSigner(types.MustGenerateMixedKeyInfo(2, 2))
+	blsAddr, secpAddr, workerAddr := signer.Addresses[0], signer.Addresses[1], signer.Addresses[3]
+	newMsg := func(from address.Address) *types.SignedMessage {
+		msg := types.NewMeteredMessage(from, workerAddr, 0, types.ZeroAttoFIL, builtin.MethodSend, []byte{}, types.NewAttoFILFromFIL(1), 300)
+		smsg, err := types.NewSignedMessage(*msg, signer)
+		require.NoError(t, err)
+		return smsg
+	}
+	secpMsg, blsMsg := newMsg(secpAddr), newMsg(blsAddr)
+
+	minerAddr := vmaddr.NewForTestGetter()()
+	view := appstate.NewFakeStateView(abi.NewStoragePower(4096))
+	view.Miners[minerAddr] = &appstate.FakeMinerState{
+		Worker:       workerAddr,
+		SectorSize:   1024,
+		ClaimedPower: abi.NewStoragePower(2048),
+	}
+	base, err := block.NewTipSet(&block.Block{Miner: minerAddr, Height: 5, ParentWeight: fbig.Zero()})
+	require.NoError(t, err)
+
+	messages := chain.NewMessageStore(blockstore.NewBlockstore(datastore.NewMapDatastore()))
+	worker := NewDefaultWorker(WorkerParameters{
+		API:            &viewAPI{view: view},
+		MinerAddr:      minerAddr,
+		WorkerSigner:   signer,
+		TipSetMetadata: fixedRoots{},
+		GetWeight: func(context.Context, block.TipSet) (fbig.Int, error) {
+			return fbig.Zero(), nil
+		},
+		MessageSource:   pendingSource{secpMsg, blsMsg},
+		SelectionPolicy: FIFO,
+		MessageStore:    messages,
+		Clock:           clock.NewSystemClock(),
+	})
+
+	blk, err := worker.Generate(ctx, base, block.Ticket{VRFProof: []byte("ticket")}, 0, block.EPoStInfo{})
+	require.NoError(t, err)
+
+	expected, err := messages.StoreMessages(ctx, []*types.SignedMessage{secpMsg}, []*types.UnsignedMessage{&blsMsg.Message})
+	require.NoError(t, err)
+	assert.Equal(t, expected, blk.Messages.Cid)
+
+	secp, unsigned, err := messages.LoadMessages(ctx, blk.Messages.Cid)
+	require.NoError(t, err)
+	assert.Equal(t, []*types.SignedMessage{secpMsg}, secp)
+	assert.Equal(t, []*types.UnsignedMessage{&blsMsg.Message}, unsigned)
+}
@@ -0,0 +1,125 @@
+package mining
+
+import (
+	"context"
+	"fmt"
+
+	ffi "github.com/filecoin-project/filecoin-ffi"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/postgenerator"
+)
+
+// ProvingRetriesExhaustedError is returned when candidate or PoSt generation
+// still fails after the worker's configured retries.
+type ProvingRetriesExhaustedError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *ProvingRetriesExhaustedError) Error() string {
+	return fmt.Sprintf("proving failed after %d attempts: %s", e.Attempts, e.Err)
+}
+
+// Unwrap returns the error of the last attempt.
+func (e *ProvingRetriesExhaustedError) Unwrap() error {
+	return e.Err
+}
+
+// candidateResult carries the outcome of a candidate generation call.
+type candidateResult struct {
+	candidates []ffi.Candidate
+	err        error
+}
+
+// generateCandidates generates election PoSt candidates, holding one of the
+// worker's candidate generation slots for the duration of the call so that
+// overlapping mining runs can't exhaust proving resources. It returns early
+// with the context's error if ctx is canceled, but the slot is only released
+// once the underlying call completes.
+func (w *DefaultWorker) generateCandidates(ctx context.Context, postRandomness []byte, sectorInfos ffi.SortedPublicSectorInfo) ([]ffi.Candidate, error) {
+	select {
+	case w.candidateSlots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	resCh := make(chan candidateResult, 1)
+	go func() {
+		defer func() { <-w.candidateSlots }()
+		var candidates []ffi.Candidate
+		err := w.withRetries(ctx, func() error {
+			return w.withPoster(ctx, func(poster postgenerator.PoStGenerator) error {
+				var err error
+				candidates, err = w.election.GenerateCandidates(postRandomness, sectorInfos, poster)
+				return err
+			})
+		})
+		resCh <- candidateResult{candidates: candidates, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-resCh:
+		return res.candidates, res.err
+	}
+}
+
+// generatePoSt generates the election PoSt proving the winning candidates.
+func (w *DefaultWorker) generatePoSt(ctx context.Context, sectorInfos ffi.SortedPublicSectorInfo, postRandomness []byte, winners []ffi.Candidate) ([]byte, error) {
+	var post []byte
+	err := w.withRetries(ctx, func() error {
+		return w.withPoster(ctx, func(poster postgenerator.PoStGenerator) error {
+			var err error
+			post, err = w.election.GeneratePoSt(sectorInfos, postRandomness, winners, poster)
+			return err
+		})
+	})
+	return post, err
+}
+
+// withPoster calls f with the worker's poster and, if that fails with a
+// recoverable error and a fallback poster is configured, again with the
+// fallback. If the fallback fails too its error is returned.
+func (w *DefaultWorker) withPoster(ctx context.Context, f func(postgenerator.PoStGenerator) error) error {
+	err := f(w.poster)
+	if err == nil || w.fallbackPoster == nil || !recoverableProvingError(ctx, err) {
+		return err
+	}
+	log.Warnf("PoSt generator failed, retrying with fallback: %s", err)
+	return f(w.fallbackPoster)
+}
+
+// recoverableProvingError returns true if another PoSt generator could
+// succeed where one failed with `err`, which is the case unless the mining
+// run was canceled or timed out.
+func recoverableProvingError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	cause := errors.Cause(err)
+	return cause != context.Canceled && cause != context.DeadlineExceeded
+}
+
+// withRetries calls f until it succeeds or the worker's proving retries are
+// used up, doubling the delay between attempts from the base retry delay.
+// Without retries configured f's error is returned as is.
+func (w *DefaultWorker) withRetries(ctx context.Context, f func() error) error {
+	err := f()
+	delay := w.provingRetryDelay
+	for attempt := 0; err != nil && attempt < w.provingRetries; attempt++ {
+		log.Warnf("proving failed, retrying in %s: %s", delay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-w.clock.After(delay):
+		}
+		err = f()
+		delay *= 2
+	}
+	if err != nil && w.provingRetries > 0 {
+		return &ProvingRetriesExhaustedError{Attempts: w.provingRetries + 1, Err: err}
+	}
+	return err
+}
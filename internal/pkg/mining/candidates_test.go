@@ -0,0 +1,411 @@
+package mining
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	ffi "github.com/filecoin-project/filecoin-ffi"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/postgenerator"
+	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// concurrencyTrackingElection blocks candidate generation until released and
+// records the largest number of concurrent calls.
+type concurrencyTrackingElection struct {
+	release chan struct{}
+
+	mu      sync.Mutex
+	active  int
+	maxSeen int
+	started chan struct{}
+}
+
+func (e *concurrencyTrackingElection) GeneratePoStRandomness(block.Ticket, address.Address, types.Signer, uint64) ([]byte, error) {
+	return nil, nil
+}
+
+func (e *concurrencyTrackingElection) GenerateCandidates([]byte, ffi.SortedPublicSectorInfo, postgenerator.PoStGenerator) ([]ffi.Candidate, error) {
+	e.mu.Lock()
+	e.active++
+	if e.active > e.maxSeen {
+		e.maxSeen = e.active
+	}
+	e.mu.Unlock()
+	e.started <- struct{}{}
+
+	<-e.release
+
+	e.mu.Lock()
+	e.active--
+	e.mu.Unlock()
+	return []ffi.Candidate{}, nil
+}
+
+func (e *concurrencyTrackingElection) GeneratePoSt(ffi.SortedPublicSectorInfo, []byte, []ffi.Candidate, postgenerator.PoStGenerator) ([]byte, error) {
+	return nil, nil
+}
+
+func (e *concurrencyTrackingElection) CandidateWins([]byte, uint64, uint64, uint64, uint64) bool {
+	return false
+}
+
+func TestGenerateCandidatesConcurrencyLimit(t *testing.T) {
+	tf.UnitTest(t)
+
+	const limit = 2
+	const runs = 5
+	election := &concurrencyTrackingElection{
+		release: make(chan struct{}),
+		started: make(chan struct{}, runs),
+	}
+	worker := NewDefaultWorker(WorkerParameters{
+		Election:             election,
+		CandidateConcurrency: limit,
+	})
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := worker.generateCandidates(ctx, nil, ffi.SortedPublicSectorInfo{})
+			assert.NoError(t, err)
+		}()
+	}
+
+	// Only `limit` calls may start before any is released.
+	for i := 0; i < limit; i++ {
+		<-election.started
+	}
+	select {
+	case <-election.started:
+		t.Fatal("candidate generation exceeded the concurrency limit")
+	default:
+	}
+
+	for i := 0; i < runs; i++ {
+		election.release <- struct{}{}
+	}
+	wg.Wait()
+
+	election.mu.Lock()
+	defer election.mu.Unlock()
+	assert.Equal(t, limit, election.maxSeen)
+}
+
+func TestGenerateCandidatesCanceled(t *testing.T) {
+	tf.UnitTest(t)
+
+	election := &concurrencyTrackingElection{
+		release: make(chan struct{}),
+		started: make(chan struct{}, 1),
+	}
+	worker := NewDefaultWorker(WorkerParameters{
+		Election:             election,
+		CandidateConcurrency: 1,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := worker.generateCandidates(ctx, nil, ffi.SortedPublicSectorInfo{})
+		errCh <- err
+	}()
+	<-election.started
+	cancel()
+	require.Equal(t, context.Canceled, <-errCh)
+
+	// The slot is held until the canceled call completes.
+	blocked, blockedCancel := context.WithCancel(context.Background())
+	blockedCancel()
+	_, err := worker.generateCandidates(blocked, nil, ffi.SortedPublicSectorInfo{})
+	assert.Equal(t, context.Canceled, err)
+
+	election.release <- struct{}{}
+}
+
+// fakePoster is a PoSt generator identified by name.
+type fakePoster struct {
+	name string
+}
+
+func (p *fakePoster) GenerateEPostCandidates(ffi.SortedPublicSectorInfo, [32]byte, []abi.SectorNumber) ([]ffi.Candidate, error) {
+	return nil, nil
+}
+
+func (p *fakePoster) ComputeElectionPoSt(ffi.SortedPublicSectorInfo, []byte, []ffi.Candidate) ([]byte, error) {
+	return nil, nil
+}
+
+// posterElection fails unless called with the working poster, and records
+// the posters it was called with. Failures return `failure` if set.
+type posterElection struct {
+	working postgenerator.PoStGenerator
+	failure error
+	used    []postgenerator.PoStGenerator
+}
+
+func (e *posterElection) fail(poster postgenerator.PoStGenerator) error {
+	if e.failure != nil {
+		return e.failure
+	}
+	return errors.Errorf("%s prover unavailable", poster.(*fakePoster).name)
+}
+
+func (e *posterElection) GeneratePoStRandomness(block.Ticket, address.Address, types.Signer, uint64) ([]byte, error) {
+	return nil, nil
+}
+
+func (e *posterElection) GenerateCandidates(_ []byte, _ ffi.SortedPublicSectorInfo, poster postgenerator.PoStGenerator) ([]ffi.Candidate, error) {
+	e.used = append(e.used, poster)
+	if poster != e.working {
+		return nil, e.fail(poster)
+	}
+	return []ffi.Candidate{{SectorNum: 1}}, nil
+}
+
+func (e *posterElection) GeneratePoSt(_ ffi.SortedPublicSectorInfo, _ []byte, _ []ffi.Candidate, poster postgenerator.PoStGenerator) ([]byte, error) {
+	e.used = append(e.used, poster)
+	if poster != e.working {
+		return nil, e.fail(poster)
+	}
+	return []byte("post"), nil
+}
+
+func (e *posterElection) CandidateWins([]byte, uint64, uint64, uint64, uint64) bool {
+	return true
+}
+
+func TestFallbackPoster(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	primary := &fakePoster{name: "gpu"}
+	fallback := &fakePoster{name: "cpu"}
+
+	t.Run("fallback used when primary fails", func(t *testing.T) {
+		election := &posterElection{working: fallback}
+		worker := NewDefaultWorker(WorkerParameters{
+			Election:       election,
+			Poster:         primary,
+			FallbackPoster: fallback,
+		})
+
+		candidates, err := worker.generateCandidates(ctx, nil, ffi.SortedPublicSectorInfo{})
+		require.NoError(t, err)
+		assert.Len(t, candidates, 1)
+
+		post, err := worker.generatePoSt(ctx, ffi.SortedPublicSectorInfo{}, nil, candidates)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("post"), post)
+
+		assert.Equal(t, []postgenerator.PoStGenerator{primary, fallback, primary, fallback}, election.used)
+	})
+
+	t.Run("fallback unused when primary succeeds", func(t *testing.T) {
+		election := &posterElection{working: primary}
+		worker := NewDefaultWorker(WorkerParameters{
+			Election:       election,
+			Poster:         primary,
+			FallbackPoster: fallback,
+		})
+
+		_, err := worker.generatePoSt(ctx, ffi.SortedPublicSectorInfo{}, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []postgenerator.PoStGenerator{primary}, election.used)
+	})
+
+	t.Run("primary error returned without fallback", func(t *testing.T) {
+		election := &posterElection{working: fallback}
+		worker := NewDefaultWorker(WorkerParameters{
+			Election: election,
+			Poster:   primary,
+		})
+
+		_, err := worker.generateCandidates(ctx, nil, ffi.SortedPublicSectorInfo{})
+		assert.EqualError(t, err, "gpu prover unavailable")
+	})
+
+	t.Run("fallback unused when mining is canceled", func(t *testing.T) {
+		election := &posterElection{working: fallback}
+		worker := NewDefaultWorker(WorkerParameters{
+			Election:       election,
+			Poster:         primary,
+			FallbackPoster: fallback,
+		})
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := worker.generatePoSt(canceled, ffi.SortedPublicSectorInfo{}, nil, nil)
+		assert.EqualError(t, err, "gpu prover unavailable")
+		assert.Equal(t, []postgenerator.PoStGenerator{primary}, election.used)
+	})
+
+	t.Run("fallback unused when primary is canceled", func(t *testing.T) {
+		election := &posterElection{working: fallback, failure: context.Canceled}
+		worker := NewDefaultWorker(WorkerParameters{
+			Election:       election,
+			Poster:         primary,
+			FallbackPoster: fallback,
+		})
+
+		_, err := worker.generatePoSt(ctx, ffi.SortedPublicSectorInfo{}, nil, nil)
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, []postgenerator.PoStGenerator{primary}, election.used)
+	})
+
+	t.Run("block produced with fallback", func(t *testing.T) {
+		params, base := newFakeMiningParameters(t)
+		params.Election = &posterElection{working: fallback}
+		params.Poster = primary
+		params.FallbackPoster = fallback
+		worker := NewDefaultWorker(params)
+
+		outCh := make(chan Output, 1)
+		require.True(t, worker.Mine(ctx, base, 0, outCh))
+		out := <-outCh
+		require.NoError(t, out.Err)
+		assert.NotNil(t, out.NewBlock)
+	})
+}
+
+// flakyElection fails candidate and PoSt generation a set number of times
+// before succeeding.
+type flakyElection struct {
+	failures int
+	calls    int
+}
+
+func (e *flakyElection) GeneratePoStRandomness(block.Ticket, address.Address, types.Signer, uint64) ([]byte, error) {
+	return nil, nil
+}
+
+func (e *flakyElection) GenerateCandidates([]byte, ffi.SortedPublicSectorInfo, postgenerator.PoStGenerator) ([]ffi.Candidate, error) {
+	e.calls++
+	if e.calls <= e.failures {
+		return nil, errors.New("transient ffi failure")
+	}
+	return []ffi.Candidate{{SectorNum: 1}}, nil
+}
+
+func (e *flakyElection) GeneratePoSt(ffi.SortedPublicSectorInfo, []byte, []ffi.Candidate, postgenerator.PoStGenerator) ([]byte, error) {
+	e.calls++
+	if e.calls <= e.failures {
+		return nil, errors.New("transient ffi failure")
+	}
+	return []byte("post"), nil
+}
+
+func (e *flakyElection) CandidateWins([]byte, uint64, uint64, uint64, uint64) bool {
+	return true
+}
+
+func TestProvingRetries(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	const delay = time.Second
+
+	// retrying runs f, advancing the clock through `retries` backoffs.
+	retrying := func(clk th.FakeClock, retries int, f func()) {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			f()
+		}()
+		for i := 0; i < retries; i++ {
+			clk.BlockUntil(1)
+			clk.Advance(delay << uint(i))
+		}
+		<-done
+	}
+	newWorker := func(election electionUtil, clk th.FakeClock, retries int) *DefaultWorker {
+		return NewDefaultWorker(WorkerParameters{
+			Election:          election,
+			Clock:             clk,
+			ProvingRetries:    retries,
+			ProvingRetryDelay: delay,
+		})
+	}
+
+	t.Run("succeeds after retries", func(t *testing.T) {
+		clk := th.NewFakeClock(time.Unix(1234567890, 0))
+		election := &flakyElection{failures: 2}
+		worker := newWorker(election, clk, 3)
+
+		var candidates []ffi.Candidate
+		var err error
+		retrying(clk, 2, func() {
+			candidates, err = worker.generateCandidates(ctx, nil, ffi.SortedPublicSectorInfo{})
+		})
+		require.NoError(t, err)
+		assert.Len(t, candidates, 1)
+		assert.Equal(t, 3, election.calls)
+
+		election.calls = 0
+		var post []byte
+		retrying(clk, 2, func() {
+			post, err = worker.generatePoSt(ctx, ffi.SortedPublicSectorInfo{}, nil, candidates)
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []byte("post"), post)
+		assert.Equal(t, 3, election.calls)
+	})
+
+	t.Run("block produced after retries", func(t *testing.T) {
+		clk := th.NewFakeClock(time.Unix(1234567890, 0))
+		params, base := newFakeMiningParameters(t)
+		params.Election = &flakyElection{failures: 2}
+		params.Clock = clk
+		params.ProvingRetries = 3
+		params.ProvingRetryDelay = delay
+		worker := NewDefaultWorker(params)
+
+		outCh := make(chan Output, 1)
+		won := false
+		retrying(clk, 2, func() {
+			won = worker.Mine(ctx, base, 0, outCh)
+		})
+		require.True(t, won)
+		out := <-outCh
+		require.NoError(t, out.Err)
+		assert.NotNil(t, out.NewBlock)
+	})
+
+	t.Run("typed error when retries exhausted", func(t *testing.T) {
+		clk := th.NewFakeClock(time.Unix(1234567890, 0))
+		election := &flakyElection{failures: 5}
+		worker := newWorker(election, clk, 2)
+
+		var err error
+		retrying(clk, 2, func() {
+			_, err = worker.generatePoSt(ctx, ffi.SortedPublicSectorInfo{}, nil, nil)
+		})
+		require.Error(t, err)
+		exhausted, ok := err.(*ProvingRetriesExhaustedError)
+		require.True(t, ok)
+		assert.Equal(t, 3, exhausted.Attempts)
+		assert.EqualError(t, exhausted.Err, "transient ffi failure")
+		assert.Equal(t, 3, election.calls)
+	})
+
+	t.Run("no retries by default", func(t *testing.T) {
+		election := &flakyElection{failures: 1}
+		worker := newWorker(election, nil, 0)
+		_, err := worker.generatePoSt(ctx, ffi.SortedPublicSectorInfo{}, nil, nil)
+		assert.EqualError(t, err, "transient ffi failure")
+		assert.Equal(t, 1, election.calls)
+	})
+}
@@ -0,0 +1,52 @@
+package mining
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// ComputeElectionRandomness returns the PoSt randomness of an election for a
+// block mined after `nullBlocks` null blocks on `head`. The election ticket is
+// the min ticket `sampler` draws from the chain at `epoch`, the election
+// epoch given by consensus.Params.ElectionEpoch, signed by `worker` with the
+// null block count, exactly as a worker does in each round.
+func ComputeElectionRandomness(sampler *chain.Sampler, head block.TipSetKey, epoch abi.ChainEpoch, worker address.Address, signer types.Signer, nullBlocks uint64) ([]byte, error) {
+	_, rand, err := electionRandomness(context.Background(), consensus.ElectionMachine{}, sampler, head, epoch, worker, signer, nullBlocks)
+	return rand, err
+}
+
+// electionRandomness samples the election ticket like ComputeElectionRandomness
+// and returns it with the PoSt randomness `election` generates from it.
+func electionRandomness(ctx context.Context, election electionUtil, sampler *chain.Sampler, head block.TipSetKey, epoch abi.ChainEpoch, worker address.Address, signer types.Signer, nullBlocks uint64) (block.Ticket, []byte, error) {
+	ticket, err := sampler.MinTicketAt(ctx, head, epoch)
+	if err != nil {
+		return block.Ticket{}, nil, errors.Wrap(err, "failed to sample election ticket")
+	}
+	rand, err := election.GeneratePoStRandomness(ticket, worker, signer, nullBlocks)
+	if err != nil {
+		return block.Ticket{}, nil, err
+	}
+	return ticket, rand, nil
+}
+
+// ancestorTipSets provides the tipsets of an ancestor chain segment the
+// worker has already loaded, so that it can be sampled without reloading it.
+type ancestorTipSets []block.TipSet
+
+// GetTipSet returns the ancestor with key `key`.
+func (a ancestorTipSets) GetTipSet(key block.TipSetKey) (block.TipSet, error) {
+	for _, ts := range a {
+		if ts.Key().Equals(key) {
+			return ts, nil
+		}
+	}
+	return block.UndefTipSet, errors.Errorf("tipset %s is not a loaded ancestor", key)
+}
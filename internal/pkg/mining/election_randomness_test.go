@@ -0,0 +1,94 @@
+package mining
+
+import (
+	"context"
+	"testing"
+
+	ffi "github.com/filecoin-project/filecoin-ffi"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/postgenerator"
+	appstate "github.com/filecoin-project/go-filecoin/internal/pkg/state"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+// randomnessOnlyElection generates PoSt randomness as the ElectionMachine
+// does, but no candidates.
+type randomnessOnlyElection struct {
+	consensus.ElectionMachine
+}
+
+func (randomnessOnlyElection) GenerateCandidates([]byte, ffi.SortedPublicSectorInfo, postgenerator.PoStGenerator) ([]ffi.Candidate, error) {
+	return nil, nil
+}
+
+func TestComputeElectionRandomness(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signer := types.NewMockSigner(types.MustGenerateMixedKeyInfo(1, 1))
+	workerAddr := signer.Addresses[0]
+	minerAddr := vmaddr.NewForTestGetter()()
+	view := appstate.NewFakeStateView(abi.NewStoragePower(4096))
+	view.Miners[minerAddr] = &appstate.FakeMinerState{
+		Worker:       workerAddr,
+		SectorSize:   1024,
+		ClaimedPower: abi.NewStoragePower(2048),
+	}
+
+	// Ancestors from the base down, each with a distinct ticket.
+	builder := chain.NewBuilder(t, minerAddr)
+	ancestors := []block.TipSet{builder.NewGenesis()}
+	for i := 1; i <= 5; i++ {
+		ts := builder.BuildOneOn(ancestors[0], func(b *chain.BlockBuilder) {
+			b.SetTicket([]byte{byte(i)})
+		})
+		ancestors = append([]block.TipSet{ts}, ancestors...)
+	}
+	sampler := chain.NewSampler(builder)
+
+	params := consensus.DefaultParams()
+	params.ElectionLookback = 3
+	worker := NewDefaultWorker(WorkerParameters{
+		API:          &viewAPI{view: view},
+		MinerAddr:    minerAddr,
+		WorkerSigner: signer,
+		Election:     randomnessOnlyElection{},
+		TicketGen:    &consensus.FakeTicketMachine{},
+		GetAncestors: func(context.Context, block.TipSet, abi.ChainEpoch) ([]block.TipSet, error) {
+			return ancestors, nil
+		},
+		Params: params,
+	})
+
+	nullBlocks := uint64(2)
+	round, err := worker.prepareRound(ctx, ancestors[0], nullBlocks)
+	require.NoError(t, err)
+
+	epoch, err := params.ElectionEpoch(ancestors)
+	require.NoError(t, err)
+	rand, err := ComputeElectionRandomness(sampler, ancestors[0].Key(), epoch, workerAddr, signer, nullBlocks)
+	require.NoError(t, err)
+	assert.Equal(t, round.PoStRandomness, rand)
+	assert.True(t, consensus.ElectionMachine{}.VerifyPoStRandomness(rand, round.ElectionTicket, workerAddr, nullBlocks))
+
+	// The worker and validation sample the same election ticket.
+	electionTicket, err := params.ElectionTicket(ancestors)
+	require.NoError(t, err)
+	assert.Equal(t, electionTicket, round.ElectionTicket)
+
+	// Each input changes the randomness.
+	otherNulls, err := ComputeElectionRandomness(sampler, ancestors[0].Key(), epoch, workerAddr, signer, nullBlocks+1)
+	require.NoError(t, err)
+	assert.NotEqual(t, rand, otherNulls)
+	otherEpoch, err := ComputeElectionRandomness(sampler, ancestors[0].Key(), epoch-1, workerAddr, signer, nullBlocks)
+	require.NoError(t, err)
+	assert.NotEqual(t, rand, otherEpoch)
+}
@@ -1,12 +1,10 @@
 package mining
 
 import (
-	"bytes"
 	"container/heap"
 	"sort"
 
 	"github.com/filecoin-project/go-address"
-	specsbig "github.com/filecoin-project/specs-actors/actors/abi/big"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 )
@@ -95,13 +93,7 @@ func (pq queueHeap) Len() int { return len(pq) }
 
 // Less implements Heap.Interface.Less to compare items on gas price and sender address.
 func (pq queueHeap) Less(i, j int) bool {
-	delta := specsbig.Sub(pq[i][0].Message.GasPrice, pq[j][0].Message.GasPrice)
-	if !delta.IsZero() {
-		// We want Pop to give us the highest gas price, so use GreaterThan.
-		return delta.GreaterThan(types.ZeroAttoFIL)
-	}
-	// Secondarily order by address to give a stable ordering.
-	return bytes.Compare(pq[i][0].Message.From.Bytes(), pq[j][0].Message.From.Bytes()) < 0
+	return pq[i][0].HigherFeeThan(pq[j][0])
 }
 
 func (pq queueHeap) Swap(i, j int) {
@@ -0,0 +1,67 @@
+package mining
+
+import (
+	"context"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// DefaultRemoteSignTimeout is how long a remote signer is given to produce a
+// signature before the worker gives up on it.
+const DefaultRemoteSignTimeout = 10 * time.Second
+
+// RemoteSigner signs on the worker's behalf outside this process, e.g. in an
+// HSM or a signing service. Unlike types.Signer it takes a context, which is
+// canceled when the worker stops waiting for the signature.
+type RemoteSigner interface {
+	SignBytes(ctx context.Context, data []byte, addr address.Address) (crypto.Signature, error)
+}
+
+// NewRemoteSignerAdapter returns a types.Signer, suitable as the worker's
+// WorkerSigner, which signs with `remote` and fails if it takes longer than
+// `timeout`. A non-positive timeout means DefaultRemoteSignTimeout.
+func NewRemoteSignerAdapter(remote RemoteSigner, timeout time.Duration) types.Signer {
+	if timeout <= 0 {
+		timeout = DefaultRemoteSignTimeout
+	}
+	return &remoteSignerAdapter{remote: remote, timeout: timeout}
+}
+
+type remoteSignerAdapter struct {
+	remote  RemoteSigner
+	timeout time.Duration
+}
+
+type remoteSignature struct {
+	sig crypto.Signature
+	err error
+}
+
+// SignBytes asks the remote signer to sign `data` with the key for `addr`.
+func (a *remoteSignerAdapter) SignBytes(data []byte, addr address.Address) (crypto.Signature, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+	defer cancel()
+
+	// The remote signer may not honor cancellation, so don't wait on it past
+	// the timeout.
+	done := make(chan remoteSignature, 1)
+	go func() {
+		sig, err := a.remote.SignBytes(ctx, data, addr)
+		done <- remoteSignature{sig, err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return crypto.Signature{}, errors.Wrapf(res.err, "remote signer failed to sign for %s", addr)
+		}
+		return res.sig, nil
+	case <-ctx.Done():
+		return crypto.Signature{}, errors.Wrapf(ctx.Err(), "remote signer did not sign for %s within %s", addr, a.timeout)
+	}
+}
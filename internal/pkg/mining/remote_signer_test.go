@@ -0,0 +1,101 @@
+package mining_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/mining"
+	appstate "github.com/filecoin-project/go-filecoin/internal/pkg/state"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+// fakeRemoteSigner signs with a local signer after a delay, or fails.
+type fakeRemoteSigner struct {
+	signer types.Signer
+	delay  time.Duration
+	err    error
+}
+
+func (s *fakeRemoteSigner) SignBytes(ctx context.Context, data []byte, addr address.Address) (crypto.Signature, error) {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return crypto.Signature{}, ctx.Err()
+	}
+	if s.err != nil {
+		return crypto.Signature{}, s.err
+	}
+	return s.signer.SignBytes(data, addr)
+}
+
+func TestRemoteSigner(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	signer := types.NewMockSigner(types.MustGenerateKeyInfo(1, 42))
+	workerAddr := signer.Addresses[0]
+	minerAddr := vmaddr.NewForTestGetter()()
+
+	builder := chain.NewBuilder(t, address.Undef)
+	base := builder.AppendOn(builder.NewGenesis(), 1)
+	view := appstate.NewFakeStateView(abi.NewStoragePower(1))
+	view.Miners[minerAddr] = &appstate.FakeMinerState{Owner: workerAddr, Worker: workerAddr}
+	api := &keyedViewAPI{views: map[string]consensus.PowerStateView{base.Key().String(): view}}
+
+	// mine runs the worker until just after it signs its ticket, returning
+	// the error it stops with.
+	errStop := errors.New("ticket signed")
+	mine := func(remote mining.RemoteSigner, timeout time.Duration) error {
+		worker := mining.NewDefaultWorker(mining.WorkerParameters{
+			API:          api,
+			MinerAddr:    minerAddr,
+			WorkerSigner: mining.NewRemoteSignerAdapter(remote, timeout),
+			TicketGen:    consensus.TicketMachine{},
+			GetAncestors: func(context.Context, block.TipSet, abi.ChainEpoch) ([]block.TipSet, error) {
+				return nil, errStop
+			},
+		})
+		outCh := make(chan mining.Output, 1)
+		worker.Mine(ctx, base, 0, outCh)
+		return (<-outCh).Err
+	}
+
+	t.Run("signs despite latency", func(t *testing.T) {
+		remote := &fakeRemoteSigner{signer: signer, delay: 10 * time.Millisecond}
+		assert.Equal(t, errStop, mine(remote, time.Second))
+	})
+
+	t.Run("signature is valid", func(t *testing.T) {
+		adapter := mining.NewRemoteSignerAdapter(&fakeRemoteSigner{signer: signer}, time.Second)
+		blk := &block.Block{Miner: minerAddr, Height: 1}
+		require.NoError(t, block.SignBlock(adapter, blk, workerAddr))
+		assert.True(t, crypto.IsValidSignature(blk.SignatureData(), workerAddr, blk.BlockSig))
+	})
+
+	t.Run("remote error fails mining", func(t *testing.T) {
+		remote := &fakeRemoteSigner{signer: signer, err: errors.New("hsm unavailable")}
+		err := mine(remote, time.Second)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "hsm unavailable")
+	})
+
+	t.Run("slow signer times out", func(t *testing.T) {
+		remote := &fakeRemoteSigner{signer: signer, delay: time.Minute}
+		err := mine(remote, 10*time.Millisecond)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "did not sign")
+	})
+}
@@ -0,0 +1,272 @@
+package mining
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ffi "github.com/filecoin-project/filecoin-ffi"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/postgenerator"
+	appstate "github.com/filecoin-project/go-filecoin/internal/pkg/state"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/util/hasher"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+// viewAPI serves one power state view for every tipset.
+type viewAPI struct {
+	view consensus.PowerStateView
+}
+
+func (a *viewAPI) BlockTime() time.Duration {
+	return time.Second
+}
+
+func (a *viewAPI) PowerStateView(block.TipSetKey) (consensus.PowerStateView, error) {
+	return a.view, nil
+}
+
+// recordingElection produces one candidate per listed sector, wins those in
+// `winning`, and records the inputs it is given.
+type recordingElection struct {
+	sectors []abi.SectorNumber
+	winning map[abi.SectorNumber]bool
+
+	electionTicket block.Ticket
+	worker         address.Address
+	numSectors     uint64
+	networkPower   uint64
+	sectorSize     uint64
+}
+
+func (e *recordingElection) GeneratePoStRandomness(ticket block.Ticket, worker address.Address, _ types.Signer, _ uint64) ([]byte, error) {
+	e.electionTicket = ticket
+	e.worker = worker
+	return []byte("randomness"), nil
+}
+
+func (e *recordingElection) GenerateCandidates(_ []byte, _ ffi.SortedPublicSectorInfo, _ postgenerator.PoStGenerator) ([]ffi.Candidate, error) {
+	var candidates []ffi.Candidate
+	for _, num := range e.sectors {
+		candidates = append(candidates, ffi.Candidate{SectorNum: num, PartialTicket: [32]byte{byte(num)}})
+	}
+	return candidates, nil
+}
+
+func (e *recordingElection) GeneratePoSt(ffi.SortedPublicSectorInfo, []byte, []ffi.Candidate, postgenerator.PoStGenerator) ([]byte, error) {
+	return nil, nil
+}
+
+func (e *recordingElection) CandidateWins(challengeTicket []byte, numSectors, _, networkPower, sectorSize uint64) bool {
+	e.numSectors, e.networkPower, e.sectorSize = numSectors, networkPower, sectorSize
+	for num := range e.winning {
+		if string(challengeTicket) == string(challengeTicketFor(num)) {
+			return true
+		}
+	}
+	return false
+}
+
+// challengeTicketFor returns the challenge ticket of the recordingElection
+// candidate for sector `num`.
+func challengeTicketFor(num abi.SectorNumber) []byte {
+	partial := [32]byte{byte(num)}
+	h := hasher.NewHasher()
+	h.Bytes(partial[:])
+	return h.Hash()
+}
+
+func TestPrepareRound(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	addrs := vmaddr.NewForTestGetter()
+	minerAddr, workerAddr := addrs(), addrs()
+	view := appstate.NewFakeStateView(abi.NewStoragePower(4096))
+	view.Miners[minerAddr] = &appstate.FakeMinerState{
+		Worker:       workerAddr,
+		SectorSize:   1024,
+		ClaimedPower: abi.NewStoragePower(2048),
+	}
+
+	baseTicket := block.Ticket{VRFProof: []byte("base")}
+	base, err := block.NewTipSet(&block.Block{Miner: minerAddr, Ticket: baseTicket, Height: 5})
+	require.NoError(t, err)
+
+	newWorker := func(election electionUtil, ancestorsErr error) *DefaultWorker {
+		return NewDefaultWorker(WorkerParameters{
+			API:       &viewAPI{view: view},
+			MinerAddr: minerAddr,
+			Election:  election,
+			TicketGen: &consensus.FakeTicketMachine{},
+			GetAncestors: func(context.Context, block.TipSet, abi.ChainEpoch) ([]block.TipSet, error) {
+				return []block.TipSet{base}, ancestorsErr
+			},
+			Params: consensus.Params{ElectionLookback: 1},
+		})
+	}
+
+	t.Run("populates round", func(t *testing.T) {
+		election := &recordingElection{
+			sectors: []abi.SectorNumber{3, 1, 2},
+			winning: map[abi.SectorNumber]bool{2: true, 3: true},
+		}
+		round, err := newWorker(election, nil).prepareRound(ctx, base, 0)
+		require.NoError(t, err)
+
+		assert.Equal(t, workerAddr, round.WorkerAddr)
+		assert.Equal(t, consensus.MakeFakeTicketForTest(), round.NextTicket)
+		assert.Equal(t, baseTicket, round.ElectionTicket)
+		assert.Equal(t, []byte("randomness"), round.PoStRandomness)
+		assert.Len(t, round.Candidates, 3)
+		require.Len(t, round.Winners, 2)
+		assert.Equal(t, abi.SectorNumber(2), round.Winners[0].SectorNum)
+		assert.Equal(t, abi.SectorNumber(3), round.Winners[1].SectorNum)
+
+		assert.Equal(t, baseTicket, election.electionTicket)
+		assert.Equal(t, workerAddr, election.worker)
+		assert.Equal(t, uint64(2), election.numSectors)
+		assert.Equal(t, uint64(4096), election.networkPower)
+		assert.Equal(t, uint64(1024), election.sectorSize)
+	})
+
+	t.Run("lost election has no winners", func(t *testing.T) {
+		election := &recordingElection{sectors: []abi.SectorNumber{1, 2}}
+		round, err := newWorker(election, nil).prepareRound(ctx, base, 0)
+		require.NoError(t, err)
+		assert.Len(t, round.Candidates, 2)
+		assert.Empty(t, round.Winners)
+	})
+
+	t.Run("stage error returned", func(t *testing.T) {
+		_, err := newWorker(&recordingElection{}, errors.New("no ancestors")).prepareRound(ctx, base, 0)
+		assert.EqualError(t, err, "no ancestors")
+	})
+}
+
+func TestPrepareRoundElectionLookback(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	addrs := vmaddr.NewForTestGetter()
+	minerAddr, workerAddr := addrs(), addrs()
+	view := appstate.NewFakeStateView(abi.NewStoragePower(4096))
+	view.Miners[minerAddr] = &appstate.FakeMinerState{
+		Worker:       workerAddr,
+		SectorSize:   1024,
+		ClaimedPower: abi.NewStoragePower(2048),
+	}
+
+	// Ancestors from the base down, each with a distinct ticket.
+	var ancestors []block.TipSet
+	for i := 5; i > 0; i-- {
+		ts, err := block.NewTipSet(&block.Block{Miner: minerAddr, Ticket: block.Ticket{VRFProof: []byte{byte(i)}}, Height: abi.ChainEpoch(i)})
+		require.NoError(t, err)
+		ancestors = append(ancestors, ts)
+	}
+
+	params := consensus.DefaultParams()
+	params.ElectionLookback = 3
+	election := &recordingElection{}
+	worker := NewDefaultWorker(WorkerParameters{
+		API:       &viewAPI{view: view},
+		MinerAddr: minerAddr,
+		Election:  election,
+		TicketGen: &consensus.FakeTicketMachine{},
+		GetAncestors: func(context.Context, block.TipSet, abi.ChainEpoch) ([]block.TipSet, error) {
+			return ancestors, nil
+		},
+		Params: params,
+	})
+
+	round, err := worker.prepareRound(ctx, ancestors[0], 0)
+	require.NoError(t, err)
+
+	expected, err := params.ElectionTicket(ancestors)
+	require.NoError(t, err)
+	assert.Equal(t, ancestors[2].At(0).Ticket, expected)
+	assert.Equal(t, expected, round.ElectionTicket)
+	assert.Equal(t, expected, election.electionTicket)
+}
+
+// newFakeMiningParameters returns the parameters of a worker for a miner
+// with power in a fake state, which wins every election on the returned
+// base, and the base.
+func newFakeMiningParameters(t *testing.T) (WorkerParameters, block.TipSet) {
+	signer, kis := types.NewMockSignersAndKeyInfo(1)
+	workerAddr, err := kis[0].Address()
+	require.NoError(t, err)
+	minerAddr := vmaddr.NewForTestGetter()()
+	view := appstate.NewFakeStateView(abi.NewStoragePower(4096))
+	view.Miners[minerAddr] = &appstate.FakeMinerState{
+		Worker:       workerAddr,
+		SectorSize:   1024,
+		ClaimedPower: abi.NewStoragePower(2048),
+	}
+	base, err := block.NewTipSet(&block.Block{Miner: minerAddr, Ticket: block.Ticket{VRFProof: []byte("base")}, Height: 5, ParentWeight: fbig.Zero()})
+	require.NoError(t, err)
+
+	return WorkerParameters{
+		API:            &viewAPI{view: view},
+		MinerAddr:      minerAddr,
+		WorkerSigner:   signer,
+		TipSetMetadata: fixedRoots{},
+		GetWeight: func(context.Context, block.TipSet) (fbig.Int, error) {
+			return fbig.Zero(), nil
+		},
+		GetAncestors: func(context.Context, block.TipSet, abi.ChainEpoch) ([]block.TipSet, error) {
+			return []block.TipSet{base}, nil
+		},
+		Election: &recordingElection{
+			sectors: []abi.SectorNumber{1},
+			winning: map[abi.SectorNumber]bool{1: true},
+		},
+		TicketGen:     &consensus.FakeTicketMachine{},
+		MessageSource: pendingSource{},
+		MessageStore:  chain.NewMessageStore(blockstore.NewBlockstore(datastore.NewMapDatastore())),
+		Clock:         clock.NewSystemClock(),
+		Params:        consensus.Params{ElectionLookback: 1},
+	}, base
+}
+
+func TestMineLocalOnly(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	mine := func(localOnly bool) Output {
+		params, base := newFakeMiningParameters(t)
+		params.LocalOnly = localOnly
+		worker := NewDefaultWorker(params)
+		outCh := make(chan Output, 1)
+		require.True(t, worker.Mine(ctx, base, 0, outCh))
+		return <-outCh
+	}
+
+	t.Run("blocks are broadcast by default", func(t *testing.T) {
+		out := mine(false)
+		require.NoError(t, out.Err)
+		assert.NotNil(t, out.NewBlock)
+		assert.False(t, out.LocalOnly)
+	})
+
+	t.Run("local only blocks are output but marked", func(t *testing.T) {
+		out := mine(true)
+		require.NoError(t, out.Err)
+		assert.NotNil(t, out.NewBlock)
+		assert.True(t, out.LocalOnly)
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/pkg/errors"
 
@@ -26,6 +27,13 @@ type Scheduler interface {
 	Continue()
 }
 
+// workerKeyTracker is implemented by workers able to follow changes to the
+// miner's worker key.
+type workerKeyTracker interface {
+	WorkerAddressAt(ctx context.Context, key block.TipSetKey) (address.Address, error)
+	ReloadSigner(worker address.Address) error
+}
+
 type timingScheduler struct {
 	// worker contains the actual mining logic.
 	worker Worker
@@ -41,6 +49,9 @@ type timingScheduler struct {
 	skipping bool
 
 	isStarted bool
+
+	// workerAddr is the worker address seen in the previous round.
+	workerAddr address.Address
 }
 
 // Start starts mining taking in a context.
@@ -96,6 +107,7 @@ func (s *timingScheduler) mineLoop(miningCtx context.Context, outCh chan Output,
 			log.Errorf("error getting height from base", err)
 		}
 		nullBlkCount := uint64(currEpoch-h) - 1
+		s.trackWorkerKey(workContext, base)
 		doneWg.Add(1)
 		go func(ctx context.Context) {
 			s.worker.Mine(ctx, base, nullBlkCount, outCh)
@@ -104,6 +116,31 @@ func (s *timingScheduler) mineLoop(miningCtx context.Context, outCh chan Output,
 	}
 }
 
+// trackWorkerKey compares the worker address at base with that of the
+// previous round and has the worker reload its signer if it changed.
+func (s *timingScheduler) trackWorkerKey(ctx context.Context, base block.TipSet) {
+	tracker, ok := s.worker.(workerKeyTracker)
+	if !ok || !base.Defined() {
+		return
+	}
+	workerAddr, err := tracker.WorkerAddressAt(ctx, base.Key())
+	if err != nil {
+		log.Errorf("error reading worker address from mining scheduler %s", err)
+		return
+	}
+	if workerAddr == s.workerAddr {
+		return
+	}
+	if !s.workerAddr.Empty() {
+		log.Infof("worker address changed from %s to %s", s.workerAddr, workerAddr)
+		if err := tracker.ReloadSigner(workerAddr); err != nil {
+			log.Errorf("error reloading worker signer %s", err)
+			return
+		}
+	}
+	s.workerAddr = workerAddr
+}
+
 func (s *timingScheduler) isSkipping() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -21,6 +21,7 @@ import (
 	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
 )
 
@@ -370,6 +371,67 @@ func TestSkips(t *testing.T) {
 	wg.Wait()
 }
 
+// keyTrackingWorker is a TestWorker which also reports a sequence of worker
+// addresses, one per round, and records signer reloads.
+type keyTrackingWorker struct {
+	*TestWorker
+
+	mu       sync.Mutex
+	rounds   []address.Address
+	reloaded []address.Address
+}
+
+func (w *keyTrackingWorker) WorkerAddressAt(_ context.Context, _ block.TipSetKey) (address.Address, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	addr := w.rounds[0]
+	if len(w.rounds) > 1 {
+		w.rounds = w.rounds[1:]
+	}
+	return addr, nil
+}
+
+func (w *keyTrackingWorker) ReloadSigner(worker address.Address) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.reloaded = append(w.reloaded, worker)
+	return nil
+}
+
+func TestReloadsSignerOnWorkerKeyChange(t *testing.T) {
+	tf.UnitTest(t)
+	ts := testHead(t)
+
+	fakeClock, chainClock, blockTime := testClock(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	addrs := vmaddr.NewForTestGetter()
+	oldWorker, newWorker := addrs(), addrs()
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	w := &keyTrackingWorker{
+		TestWorker: NewTestWorker(t, func(_ context.Context, _ block.TipSet, _ uint64, _ chan<- Output) bool {
+			wg.Done()
+			return true
+		}),
+		rounds: []address.Address{oldWorker, oldWorker, newWorker},
+	}
+
+	scheduler := NewScheduler(w, headFunc(ts), chainClock)
+	scheduler.Start(ctx)
+	for i := 0; i < 3; i++ {
+		fakeClock.BlockUntil(1)
+		fakeClock.Advance(blockTime)
+	}
+	wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	assert.Equal(t, []address.Address{newWorker}, w.reloaded)
+}
+
 // Helper functions
 
 func testHead(t *testing.T) block.TipSet {
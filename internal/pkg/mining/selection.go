@@ -0,0 +1,104 @@
+package mining
+
+import (
+	"sort"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// SelectionPolicy controls how pending messages are ordered for inclusion in a block.
+// Whatever the policy, messages from a single sender are always in increasing nonce order.
+type SelectionPolicy int
+
+const (
+	// HighestFee orders messages by decreasing gas price.
+	HighestFee SelectionPolicy = iota
+	// FIFO orders messages by the height at which they entered the pool, oldest first.
+	FIFO
+	// Hybrid orders messages by decreasing gas price, except that messages pending
+	// for at least StarvationThreshold epochs come first, oldest first.
+	Hybrid
+)
+
+// StarvationThreshold is the number of epochs after which the Hybrid policy
+// prioritises a pending message over higher paying ones.
+const StarvationThreshold = abi.ChainEpoch(20)
+
+// MessageAgeSource reports the height at which a pending message was added.
+// A MessageSource may implement it to support the FIFO and Hybrid policies.
+type MessageAgeSource interface {
+	AddedAt(c cid.Cid) (abi.ChainEpoch, bool)
+}
+
+// SelectMessages orders `msgs` for inclusion in a block at `height` according to `policy`.
+// Messages whose age `ages` cannot report (or all messages, if `ages` is nil) are treated
+// as added at `height`.
+func SelectMessages(policy SelectionPolicy, msgs []*types.SignedMessage, height abi.ChainEpoch, ages MessageAgeSource) []*types.SignedMessage {
+	if policy == HighestFee {
+		mq := NewMessageQueue(msgs)
+		return mq.Drain()
+	}
+
+	addedAt := make(map[*types.SignedMessage]abi.ChainEpoch, len(msgs))
+	bySender := make(map[address.Address]nonceQueue)
+	for _, m := range msgs {
+		addedAt[m] = height
+		if ages != nil {
+			if c, err := m.Cid(); err == nil {
+				if at, ok := ages.AddedAt(c); ok {
+					addedAt[m] = at
+				}
+			}
+		}
+		bySender[m.Message.From] = append(bySender[m.Message.From], m)
+	}
+
+	older := func(a, b *types.SignedMessage) bool {
+		if addedAt[a] != addedAt[b] {
+			return addedAt[a] < addedAt[b]
+		}
+		return a.HigherFeeThan(b)
+	}
+	before := older
+	if policy == Hybrid {
+		before = func(a, b *types.SignedMessage) bool {
+			aStarved := height-addedAt[a] >= StarvationThreshold
+			bStarved := height-addedAt[b] >= StarvationThreshold
+			if aStarved != bStarved {
+				return aStarved
+			}
+			if aStarved {
+				return older(a, b)
+			}
+			return a.HigherFeeThan(b)
+		}
+	}
+
+	queues := make([]nonceQueue, 0, len(bySender))
+	for _, nq := range bySender {
+		sort.Slice(nq, func(i, j int) bool { return nq[i].Message.CallSeqNum < nq[j].Message.CallSeqNum })
+		queues = append(queues, nq)
+	}
+
+	// Repeatedly take the best of the senders' next messages.
+	out := make([]*types.SignedMessage, 0, len(msgs))
+	for len(queues) > 0 {
+		best := 0
+		for i := 1; i < len(queues); i++ {
+			if before(queues[i][0], queues[best][0]) {
+				best = i
+			}
+		}
+		out = append(out, queues[best][0])
+		if len(queues[best]) == 1 {
+			queues = append(queues[:best], queues[best+1:]...)
+		} else {
+			queues[best] = queues[best][1:]
+		}
+	}
+	return out
+}
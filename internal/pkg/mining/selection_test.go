@@ -0,0 +1,77 @@
+package mining
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+type fakeMessageAges map[cid.Cid]abi.ChainEpoch
+
+func (f fakeMessageAges) AddedAt(c cid.Cid) (abi.ChainEpoch, bool) {
+	at, ok := f[c]
+	return at, ok
+}
+
+func TestSelectMessages(t *testing.T) {
+	tf.UnitTest(t)
+
+	var ki = types.MustGenerateKeyInfo(10, 42)
+	var mockSigner = types.NewMockSigner(ki)
+
+	a0 := mockSigner.Addresses[0]
+	a1 := mockSigner.Addresses[2]
+	a2 := mockSigner.Addresses[3]
+	to := mockSigner.Addresses[9]
+
+	ages := fakeMessageAges{}
+	sign := func(from address.Address, nonce uint64, price int64, addedAt abi.ChainEpoch) *types.SignedMessage {
+		msg := types.UnsignedMessage{
+			From:       from,
+			To:         to,
+			CallSeqNum: nonce,
+			GasPrice:   types.NewGasPrice(price),
+		}
+		s, err := types.NewSignedMessage(msg, &mockSigner)
+		require.NoError(t, err)
+		c, err := s.Cid()
+		require.NoError(t, err)
+		ages[c] = addedAt
+		return s
+	}
+
+	// At height 100, the messages added at 0 and 50 are starved.
+	height := abi.ChainEpoch(100)
+	cheapOld := sign(a0, 0, 1, 0)
+	pricey := sign(a1, 0, 5, 90)
+	midFirst := sign(a2, 0, 3, 50)
+	midSecond := sign(a2, 1, 10, 95)
+	msgs := []*types.SignedMessage{midSecond, pricey, cheapOld, midFirst}
+
+	t.Run("highest fee", func(t *testing.T) {
+		selected := SelectMessages(HighestFee, msgs, height, ages)
+		assert.Equal(t, []*types.SignedMessage{pricey, midFirst, midSecond, cheapOld}, selected)
+	})
+
+	t.Run("fifo", func(t *testing.T) {
+		selected := SelectMessages(FIFO, msgs, height, ages)
+		assert.Equal(t, []*types.SignedMessage{cheapOld, midFirst, pricey, midSecond}, selected)
+	})
+
+	t.Run("hybrid", func(t *testing.T) {
+		selected := SelectMessages(Hybrid, msgs, height, ages)
+		assert.Equal(t, []*types.SignedMessage{cheapOld, midFirst, midSecond, pricey}, selected)
+	})
+
+	t.Run("unknown ages fall back to fee order", func(t *testing.T) {
+		selected := SelectMessages(FIFO, msgs, height, nil)
+		assert.Equal(t, []*types.SignedMessage{pricey, midFirst, midSecond, cheapOld}, selected)
+	})
+}
@@ -0,0 +1,101 @@
+package mining
+
+import (
+	"sync"
+
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+)
+
+// DefaultTipSetRootCacheSize is the number of tipsets whose state and receipt
+// roots a worker remembers.
+const DefaultTipSetRootCacheSize = 64
+
+// tipSetRootCache is a bounded cache of tipset state and receipt roots in
+// front of a tipSetMetadata. A tipset's roots never change, but those of
+// tipsets abandoned by a reorg won't be asked for again, so the cache is
+// cleared when the mining base stops extending the previous one. Once full
+// the oldest tipset is evicted.
+type tipSetRootCache struct {
+	source tipSetMetadata
+
+	mu    sync.Mutex
+	size  int
+	head  block.TipSetKey
+	order []string
+	roots map[string]*tipSetRoots
+}
+
+// tipSetRoots holds the roots fetched so far for one tipset; either may be
+// undefined.
+type tipSetRoots struct {
+	state    cid.Cid
+	receipts cid.Cid
+}
+
+func newTipSetRootCache(source tipSetMetadata, size int) *tipSetRootCache {
+	return &tipSetRootCache{
+		source: source,
+		size:   size,
+		order:  make([]string, 0, size),
+		roots:  make(map[string]*tipSetRoots, size),
+	}
+}
+
+// GetTipSetStateRoot returns the state root of the tipset identified by `key`.
+func (c *tipSetRootCache) GetTipSetStateRoot(key block.TipSetKey) (cid.Cid, error) {
+	return c.get(key, func(r *tipSetRoots) *cid.Cid { return &r.state }, c.source.GetTipSetStateRoot)
+}
+
+// GetTipSetReceiptsRoot returns the receipts root of the tipset identified by `key`.
+func (c *tipSetRootCache) GetTipSetReceiptsRoot(key block.TipSetKey) (cid.Cid, error) {
+	return c.get(key, func(r *tipSetRoots) *cid.Cid { return &r.receipts }, c.source.GetTipSetReceiptsRoot)
+}
+
+// observeBase records the tipset being mined on, clearing the cache if it
+// neither is nor extends the previous base.
+func (c *tipSetRootCache) observeBase(key, parents block.TipSetKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.head.Empty() && !c.head.Equals(key) && !c.head.Equals(parents) {
+		c.order = c.order[:0]
+		c.roots = make(map[string]*tipSetRoots, c.size)
+	}
+	c.head = key
+}
+
+func (c *tipSetRootCache) get(key block.TipSetKey, field func(*tipSetRoots) *cid.Cid, fetch func(block.TipSetKey) (cid.Cid, error)) (cid.Cid, error) {
+	k := key.String()
+	c.mu.Lock()
+	if r, ok := c.roots[k]; ok && field(r).Defined() {
+		root := *field(r)
+		c.mu.Unlock()
+		return root, nil
+	}
+	c.mu.Unlock()
+
+	root, err := fetch(key)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size <= 0 {
+		return root, nil
+	}
+	r, ok := c.roots[k]
+	if !ok {
+		if len(c.order) >= c.size {
+			oldest := c.order[0]
+			c.order = c.order[1:]
+			delete(c.roots, oldest)
+		}
+		r = &tipSetRoots{}
+		c.order = append(c.order, k)
+		c.roots[k] = r
+	}
+	*field(r) = root
+	return root, nil
+}
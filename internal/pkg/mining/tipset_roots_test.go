@@ -0,0 +1,134 @@
+package mining
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	dag "github.com/ipfs/go-merkledag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+// countingTSMetadata derives roots from the tipset key and counts reads.
+type countingTSMetadata struct {
+	stateReads   int
+	receiptReads int
+}
+
+func (tm *countingTSMetadata) GetTipSetStateRoot(key block.TipSetKey) (cid.Cid, error) {
+	tm.stateReads++
+	return dag.NewRawNode([]byte("state " + key.String())).Cid(), nil
+}
+
+func (tm *countingTSMetadata) GetTipSetReceiptsRoot(key block.TipSetKey) (cid.Cid, error) {
+	tm.receiptReads++
+	return dag.NewRawNode([]byte("receipts " + key.String())).Cid(), nil
+}
+
+func testTipSetKey(name string) block.TipSetKey {
+	return block.NewTipSetKey(dag.NewRawNode([]byte(name)).Cid())
+}
+
+func TestTipSetRootCache(t *testing.T) {
+	tf.UnitTest(t)
+
+	a, b, c := testTipSetKey("a"), testTipSetKey("b"), testTipSetKey("c")
+
+	t.Run("cached roots match uncached", func(t *testing.T) {
+		source := &countingTSMetadata{}
+		cache := newTipSetRootCache(source, 4)
+		for i := 0; i < 3; i++ {
+			for _, key := range []block.TipSetKey{a, b} {
+				want, err := (&countingTSMetadata{}).GetTipSetStateRoot(key)
+				require.NoError(t, err)
+				got, err := cache.GetTipSetStateRoot(key)
+				require.NoError(t, err)
+				assert.Equal(t, want, got)
+
+				want, err = (&countingTSMetadata{}).GetTipSetReceiptsRoot(key)
+				require.NoError(t, err)
+				got, err = cache.GetTipSetReceiptsRoot(key)
+				require.NoError(t, err)
+				assert.Equal(t, want, got)
+			}
+		}
+		assert.Equal(t, 2, source.stateReads)
+		assert.Equal(t, 2, source.receiptReads)
+	})
+
+	t.Run("oldest evicted when full", func(t *testing.T) {
+		source := &countingTSMetadata{}
+		cache := newTipSetRootCache(source, 2)
+		for _, key := range []block.TipSetKey{a, b, c, b, c} {
+			_, err := cache.GetTipSetStateRoot(key)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 3, source.stateReads)
+
+		_, err := cache.GetTipSetStateRoot(a)
+		require.NoError(t, err)
+		assert.Equal(t, 4, source.stateReads)
+	})
+
+	t.Run("extending base keeps entries", func(t *testing.T) {
+		source := &countingTSMetadata{}
+		cache := newTipSetRootCache(source, 4)
+		cache.observeBase(a, block.TipSetKey{})
+		_, err := cache.GetTipSetStateRoot(a)
+		require.NoError(t, err)
+
+		cache.observeBase(a, block.TipSetKey{})
+		cache.observeBase(b, a)
+		_, err = cache.GetTipSetStateRoot(a)
+		require.NoError(t, err)
+		assert.Equal(t, 1, source.stateReads)
+	})
+
+	t.Run("reorg clears entries", func(t *testing.T) {
+		source := &countingTSMetadata{}
+		cache := newTipSetRootCache(source, 4)
+		cache.observeBase(b, a)
+		_, err := cache.GetTipSetStateRoot(b)
+		require.NoError(t, err)
+
+		// c is a sibling of b.
+		cache.observeBase(c, a)
+		_, err = cache.GetTipSetStateRoot(b)
+		require.NoError(t, err)
+		assert.Equal(t, 2, source.stateReads)
+	})
+}
+
+func BenchmarkTipSetRootCache(b *testing.B) {
+	key := testTipSetKey("base")
+
+	b.Run("uncached", func(b *testing.B) {
+		source := &countingTSMetadata{}
+		for i := 0; i < b.N; i++ {
+			if _, err := source.GetTipSetStateRoot(key); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := source.GetTipSetReceiptsRoot(key); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(source.stateReads+source.receiptReads)/float64(b.N), "reads/op")
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		source := &countingTSMetadata{}
+		cache := newTipSetRootCache(source, DefaultTipSetRootCacheSize)
+		for i := 0; i < b.N; i++ {
+			if _, err := cache.GetTipSetStateRoot(key); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := cache.GetTipSetReceiptsRoot(key); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(source.stateReads+source.receiptReads)/float64(b.N), "reads/op")
+	})
+}
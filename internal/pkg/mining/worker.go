@@ -6,12 +6,14 @@ package mining
 
 import (
 	"context"
+	"runtime"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
-	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
 	"github.com/ipfs/go-cid"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	logging "github.com/ipfs/go-log"
@@ -22,21 +24,41 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/postgenerator"
-	"github.com/filecoin-project/go-filecoin/internal/pkg/sampling"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/util/hasher"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
 )
 
 var log = logging.Logger("mining")
 
+// DefaultRecentMessageDepth is the default number of ancestor tipsets a
+// worker checks for messages already included in the chain.
+const DefaultRecentMessageDepth = 5
+
+// DefaultProvingRetryDelay is the default delay before a worker's first
+// retry of failed candidate or PoSt generation.
+const DefaultProvingRetryDelay = time.Second
+
+// DefaultMaxNullBlocks is the default cap on the null block count a worker
+// will mine over.
+const DefaultMaxNullBlocks = 10000
+
+// ErrTooManyNullBlocks is returned when Mine is asked to mine over more null
+// blocks than the worker allows.
+var ErrTooManyNullBlocks = errors.New("null block count exceeds maximum")
+
 // Output is the result of a single mining run. It has either a new
 // block or an error, mimicing the golang (retVal, error) pattern.
 // If a mining run's context is canceled there is no output.
 type Output struct {
 	NewBlock *block.Block
 	Err      error
+	// LocalOnly is set on blocks from a worker configured not to broadcast.
+	// The caller must handle them locally rather than forward them to the network.
+	LocalOnly bool
 }
 
 // NewOutput instantiates a new Output.
@@ -58,6 +80,61 @@ type GetStateTree func(context.Context, block.TipSetKey) (state.Tree, error)
 // expressed as two uint64s comprising a rational number.
 type GetWeight func(context.Context, block.TipSet) (fbig.Int, error)
 
+// WeightDelta returns weight(a) - weight(b), which is negative when `a` is
+// lighter than `b`. The tipsets need not be at the same height.
+func WeightDelta(ctx context.Context, a, b block.TipSet, getWeight GetWeight) (fbig.Int, error) {
+	aWeight, err := getWeight(ctx, a)
+	if err != nil {
+		return fbig.Zero(), errors.Wrapf(err, "failed to weigh tipset %s", a.Key())
+	}
+	bWeight, err := getWeight(ctx, b)
+	if err != nil {
+		return fbig.Zero(), errors.Wrapf(err, "failed to weigh tipset %s", b.Key())
+	}
+	return fbig.Sub(aWeight, bWeight), nil
+}
+
+// stateRootProvider provides the state roots of tipsets.
+type stateRootProvider interface {
+	GetTipSetStateRoot(key block.TipSetKey) (cid.Cid, error)
+}
+
+// NewWeightCalculator returns the canonical GetWeight for tipsets whose
+// parent state is viewed by `view`. The genesis tipset weighs zero.
+func NewWeightCalculator(view consensus.PowerStateView) GetWeight {
+	return func(ctx context.Context, ts block.TipSet) (fbig.Int, error) {
+		parent, err := ts.Parents()
+		if err != nil {
+			return fbig.Zero(), err
+		}
+		if parent.Len() == 0 {
+			return fbig.Zero(), nil
+		}
+		return consensus.TipSetWeight(ctx, ts, view)
+	}
+}
+
+// NewChainWeightCalculator returns the canonical GetWeight for tipsets of any
+// parent state, weighing each like NewWeightCalculator with a view from
+// `views` of the state root of its parent found in `roots`.
+func NewChainWeightCalculator(views consensus.StateViewer, roots stateRootProvider) GetWeight {
+	return func(ctx context.Context, ts block.TipSet) (fbig.Int, error) {
+		parent, err := ts.Parents()
+		if err != nil {
+			return fbig.Zero(), err
+		}
+		// The genesis tipset has no parent state.
+		if parent.Len() == 0 {
+			return fbig.Zero(), nil
+		}
+		root, err := roots.GetTipSetStateRoot(parent)
+		if err != nil {
+			return fbig.Zero(), err
+		}
+		return NewWeightCalculator(views.StateView(root))(ctx, ts)
+	}
+}
+
 // GetAncestors is a function that returns the necessary ancestor chain to
 // process the input tipset.
 type GetAncestors func(context.Context, block.TipSet, abi.ChainEpoch) ([]block.TipSet, error)
@@ -70,6 +147,12 @@ type MessageSource interface {
 	Remove(message cid.Cid)
 }
 
+// ReadyMessageSource is a MessageSource which can select the messages ready
+// for inclusion in a block on top of a state tree.
+type ReadyMessageSource interface {
+	SelectReady(ctx context.Context, st state.Tree, maxGas gas.Unit, maxCount int) []*types.SignedMessage
+}
+
 // A MessageApplier processes all the messages in a message pool.
 type MessageApplier interface {
 	// Dragons: add something back or remove
@@ -103,9 +186,12 @@ type DefaultWorker struct {
 
 	minerAddr      address.Address
 	minerOwnerAddr address.Address
-	workerSigner   types.Signer
 
-	tsMetadata    tipSetMetadata
+	// workerSigner may be replaced when the miner's worker key changes.
+	workerSigner *reloadableSigner
+	loadSigner   func(worker address.Address) (types.Signer, error)
+
+	tsMetadata    *tipSetRootCache
 	getStateTree  GetStateTree
 	getWeight     GetWeight
 	getAncestors  GetAncestors
@@ -114,9 +200,25 @@ type DefaultWorker struct {
 	messageSource MessageSource
 	processor     MessageApplier
 	messageStore  chain.MessageWriter // nolint: structcheck
+	messages      chain.MessageProvider
+	recentDepth   int
 	blockstore    blockstore.Blockstore
 	clock         clock.Clock
 	poster        postgenerator.PoStGenerator
+	maxNullBlocks uint64
+	params        consensus.Params
+	selection     SelectionPolicy
+	localOnly     bool
+
+	// fallbackPoster, if set, is tried when poster fails.
+	fallbackPoster postgenerator.PoStGenerator
+
+	provingRetries    int
+	provingRetryDelay time.Duration
+
+	// candidateSlots bounds the number of concurrent candidate generation
+	// calls across mining runs.
+	candidateSlots chan struct{}
 }
 
 // WorkerParameters use for NewDefaultWorker parameters
@@ -125,7 +227,9 @@ type WorkerParameters struct {
 
 	MinerAddr      address.Address
 	MinerOwnerAddr address.Address
-	WorkerSigner   types.Signer
+	// WorkerSigner signs tickets and blocks with the worker key. Use
+	// NewRemoteSignerAdapter for keys held outside this process.
+	WorkerSigner types.Signer
 
 	// consensus things
 	TipSetMetadata tipSetMetadata
@@ -142,27 +246,103 @@ type WorkerParameters struct {
 	Blockstore    blockstore.Blockstore
 	Clock         clock.Clock
 	Poster        postgenerator.PoStGenerator
+
+	// FallbackPoster, if set, generates candidates and PoSts when Poster
+	// fails, e.g. a CPU prover standing in for an unavailable GPU.
+	FallbackPoster postgenerator.PoStGenerator
+
+	// ProvingRetries is the number of times failed candidate or PoSt
+	// generation is retried before the round is abandoned with a
+	// ProvingRetriesExhaustedError. Zero means no retries.
+	ProvingRetries int
+
+	// ProvingRetryDelay is the delay before the first retry, doubling for
+	// each subsequent one. Zero means DefaultProvingRetryDelay.
+	ProvingRetryDelay time.Duration
+
+	// SelectionPolicy orders pending messages for inclusion. The zero value
+	// is HighestFee.
+	SelectionPolicy SelectionPolicy
+
+	// Params holds the consensus parameters, which must match those the
+	// node validates blocks with. The zero value means consensus.DefaultParams.
+	Params consensus.Params
+
+	// MaxNullBlocks caps the null block count passed to Mine, bounding the
+	// ancestor walk. Zero means DefaultMaxNullBlocks.
+	MaxNullBlocks uint64
+
+	// CandidateConcurrency bounds the number of candidate generation calls
+	// in flight at once. Zero means GOMAXPROCS.
+	CandidateConcurrency int
+
+	// MessageProvider, if set, loads the messages of recent ancestors so that
+	// messages they already include are not selected again.
+	MessageProvider chain.MessageProvider
+
+	// RecentMessageDepth is the number of ancestor tipsets checked for
+	// already included messages. Zero means DefaultRecentMessageDepth.
+	RecentMessageDepth int
+
+	// LocalOnly marks mined blocks as not to be broadcast, e.g. when
+	// simulating. The zero value broadcasts them.
+	LocalOnly bool
+
+	// LoadSigner, if set, is used to replace the worker signer when the
+	// miner's worker key changes. If nil the signer is kept.
+	LoadSigner func(worker address.Address) (types.Signer, error)
 }
 
 // NewDefaultWorker instantiates a new Worker.
 func NewDefaultWorker(parameters WorkerParameters) *DefaultWorker {
+	maxNullBlocks := parameters.MaxNullBlocks
+	if maxNullBlocks == 0 {
+		maxNullBlocks = DefaultMaxNullBlocks
+	}
+	candidateConcurrency := parameters.CandidateConcurrency
+	if candidateConcurrency <= 0 {
+		candidateConcurrency = runtime.GOMAXPROCS(0)
+	}
+	recentDepth := parameters.RecentMessageDepth
+	if recentDepth <= 0 {
+		recentDepth = DefaultRecentMessageDepth
+	}
+	provingRetryDelay := parameters.ProvingRetryDelay
+	if provingRetryDelay <= 0 {
+		provingRetryDelay = DefaultProvingRetryDelay
+	}
+	params := parameters.Params
+	if params == (consensus.Params{}) {
+		params = consensus.DefaultParams()
+	}
 	return &DefaultWorker{
-		api:            parameters.API,
-		getStateTree:   parameters.GetStateTree,
-		getWeight:      parameters.GetWeight,
-		getAncestors:   parameters.GetAncestors,
-		messageSource:  parameters.MessageSource,
-		messageStore:   parameters.MessageStore,
-		processor:      parameters.Processor,
-		blockstore:     parameters.Blockstore,
-		minerAddr:      parameters.MinerAddr,
-		minerOwnerAddr: parameters.MinerOwnerAddr,
-		workerSigner:   parameters.WorkerSigner,
-		election:       parameters.Election,
-		ticketGen:      parameters.TicketGen,
-		tsMetadata:     parameters.TipSetMetadata,
-		clock:          parameters.Clock,
-		poster:         parameters.Poster,
+		api:               parameters.API,
+		getStateTree:      parameters.GetStateTree,
+		getWeight:         parameters.GetWeight,
+		getAncestors:      parameters.GetAncestors,
+		messageSource:     parameters.MessageSource,
+		messageStore:      parameters.MessageStore,
+		messages:          parameters.MessageProvider,
+		recentDepth:       recentDepth,
+		processor:         parameters.Processor,
+		blockstore:        parameters.Blockstore,
+		minerAddr:         parameters.MinerAddr,
+		minerOwnerAddr:    parameters.MinerOwnerAddr,
+		workerSigner:      &reloadableSigner{signer: parameters.WorkerSigner},
+		loadSigner:        parameters.LoadSigner,
+		election:          parameters.Election,
+		ticketGen:         parameters.TicketGen,
+		tsMetadata:        newTipSetRootCache(parameters.TipSetMetadata, DefaultTipSetRootCacheSize),
+		clock:             parameters.Clock,
+		poster:            parameters.Poster,
+		fallbackPoster:    parameters.FallbackPoster,
+		provingRetries:    parameters.ProvingRetries,
+		provingRetryDelay: provingRetryDelay,
+		maxNullBlocks:     maxNullBlocks,
+		params:            params,
+		selection:         parameters.SelectionPolicy,
+		localOnly:         parameters.LocalOnly,
+		candidateSlots:    make(chan struct{}, candidateConcurrency),
 	}
 }
 
@@ -176,171 +356,267 @@ func (w *DefaultWorker) Mine(ctx context.Context, base block.TipSet, nullBlkCoun
 		return
 	}
 
+	if nullBlkCount > w.maxNullBlocks {
+		log.Warnf("Worker.Mine returning because null block count %d exceeds %d", nullBlkCount, w.maxNullBlocks)
+		outCh <- Output{Err: errors.Wrapf(ErrTooManyNullBlocks, "%d null blocks, max is %d", nullBlkCount, w.maxNullBlocks)}
+		return
+	}
+
+	parents, err := base.Parents()
+	if err != nil {
+		outCh <- Output{Err: err}
+		return
+	}
+	w.tsMetadata.observeBase(base.Key(), parents)
+
 	log.Debugf("Mining on tipset: %s, with %d null blocks.", base.String(), nullBlkCount)
 	if ctx.Err() != nil {
 		log.Warnf("Worker.Mine returning with ctx error %s", ctx.Err().Error())
 		return
 	}
 
-	// Read uncached worker address
-	view, err := w.api.PowerStateView(base.Key())
+	round, err := w.prepareRound(ctx, base, nullBlkCount)
 	if err != nil {
+		if ctx.Err() != nil {
+			log.Infow("Mining run on tipset with null blocks canceled.", "tipset", base, "nullBlocks", nullBlkCount, "error", err)
+			return
+		}
 		outCh <- Output{Err: err}
 		return
 	}
-	_, workerAddr, err := view.MinerControlAddresses(ctx, w.minerAddr)
-	if err != nil {
+	winners := round.Winners
+
+	// no winners we are done
+	if len(winners) == 0 {
+		return
+	}
+	// we have a winning block
+
+	// Generate PoSt
+	postDone := make(chan []byte)
+	errCh := make(chan error)
+	go func() {
+		defer close(postDone)
+		defer close(errCh)
+		post, err := w.generatePoSt(ctx, round.SectorInfos, round.PoStRandomness, winners)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		postDone <- post
+	}()
+	var post []byte
+	select {
+	case <-ctx.Done():
+		log.Infow("Mining run on tipset with null blocks canceled.", "tipset", base, "nullBlocks", nullBlkCount)
+	case err := <-errCh:
+		log.Warnf("Worker.Mine failed to generate post %s", err)
 		outCh <- Output{Err: err}
 		return
+	case postOut := <-postDone:
+		post = postOut
+	}
+
+	postInfo := block.NewEPoStInfo(post, round.PoStRandomness, block.FromFFICandidates(winners...)...)
+
+	next, err := w.Generate(ctx, base, round.NextTicket, abi.ChainEpoch(nullBlkCount), postInfo)
+	if err == nil {
+		log.Debugf("Worker.Mine generates new winning block! %s", next.Cid().String())
+	}
+	out := NewOutput(next, err)
+	out.LocalOnly = w.localOnly
+	outCh <- out
+	won = true
+	return
+}
+
+// MiningRound holds the intermediate results of running the election on a
+// base tipset, for testing and telemetry.
+type MiningRound struct {
+	WorkerAddr     address.Address
+	NextTicket     block.Ticket
+	ElectionTicket block.Ticket
+	PoStRandomness []byte
+	SectorInfos    ffi.SortedPublicSectorInfo
+	Candidates     []ffi.Candidate
+	// Winners is empty if the miner lost the election.
+	Winners []ffi.Candidate
+}
+
+// prepareRound runs the election on `base` after `nullBlkCount` null blocks,
+// returning everything Mine needs to generate a block if the miner won.
+func (w *DefaultWorker) prepareRound(ctx context.Context, base block.TipSet, nullBlkCount uint64) (*MiningRound, error) {
+	// Read uncached worker address
+	workerAddr, err := w.WorkerAddressAt(ctx, base.Key())
+	if err != nil {
+		return nil, err
 	}
 
 	// lookback consensus.ElectionLookback
 	prevTicket, err := base.MinTicket()
 	if err != nil {
-		log.Warnf("Worker.Mine couldn't read parent ticket %s", err)
-		outCh <- Output{Err: err}
-		return
+		log.Warnf("Worker.prepareRound couldn't read parent ticket %s", err)
+		return nil, err
 	}
 
 	nextTicket, err := w.ticketGen.NextTicket(prevTicket, workerAddr, w.workerSigner)
 	if err != nil {
-		log.Warnf("Worker.Mine couldn't generate next ticket %s", err)
-		outCh <- Output{Err: err}
-		return
+		log.Warnf("Worker.prepareRound couldn't generate next ticket %s", err)
+		return nil, err
 	}
-	// lookback consensus.ElectionLookback for the election ticket
+	// lookback ElectionLookback for the election ticket
 	baseHeight, err := base.Height()
 	if err != nil {
-		log.Warnf("Worker.Mine couldn't read base height %s", err)
-		outCh <- Output{Err: err}
-		return
+		log.Warnf("Worker.prepareRound couldn't read base height %s", err)
+		return nil, err
 	}
 	ancestors, err := w.getAncestors(ctx, base, baseHeight+(abi.ChainEpoch(nullBlkCount+1)))
 	if err != nil {
-		log.Warnf("Worker.Mine couldn't get ancestorst %s", err)
-		outCh <- Output{Err: err}
-		return
+		log.Warnf("Worker.prepareRound couldn't get ancestorst %s", err)
+		return nil, err
 	}
-	electionTicket, err := sampling.SampleNthTicket(int(miner.ElectionLookback-1), ancestors)
+	electionEpoch, err := w.params.ElectionEpoch(ancestors)
 	if err != nil {
-		log.Warnf("Worker.Mine couldn't read parent ticket %s", err)
-		outCh <- Output{Err: err}
-		return
+		log.Warnf("Worker.prepareRound couldn't read election epoch %s", err)
+		return nil, err
 	}
-	postRandomness, err := w.election.GeneratePoStRandomness(electionTicket, workerAddr, w.workerSigner, nullBlkCount)
+	sampler := chain.NewSampler(ancestorTipSets(ancestors))
+	electionTicket, postRandomness, err := electionRandomness(ctx, w.election, sampler, base.Key(), electionEpoch, workerAddr, w.workerSigner, nullBlkCount)
 	if err != nil {
-		log.Errorf("Worker.Mine failed to generate post randomness %s", err)
-		outCh <- Output{Err: err}
-		return
+		log.Errorf("Worker.prepareRound failed to generate post randomness %s", err)
+		return nil, err
 	}
 	powerTable, err := w.getPowerTable(ctx, base.Key())
 	if err != nil {
-		log.Errorf("Worker.Mine couldn't get snapshot for tipset: %s", err.Error())
-		outCh <- Output{Err: err}
-		return
+		log.Errorf("Worker.prepareRound couldn't get snapshot for tipset: %s", err.Error())
+		return nil, err
 	}
 	sortedSectorInfos, err := powerTable.SortedSectorInfos(ctx, w.minerAddr)
 	if err != nil {
-		log.Warnf("Worker.Mine failed to get ssi for %s", w.minerAddr)
-		outCh <- Output{Err: err}
-		return
+		log.Warnf("Worker.prepareRound failed to get ssi for %s", w.minerAddr)
+		return nil, err
 	}
 	// Generate election post candidates
-	done := make(chan []ffi.Candidate)
-	errCh := make(chan error)
-	go func() {
-		defer close(done)
-		defer close(errCh)
-		candidates, err := w.election.GenerateCandidates(postRandomness, sortedSectorInfos, w.poster)
-		if err != nil {
-			errCh <- err
-			return
+	candidates, err := w.generateCandidates(ctx, postRandomness, sortedSectorInfos)
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Infof("Worker.prepareRound canceled generating candidates %s", err)
+		} else {
+			log.Warnf("Worker.prepareRound failed to generate candidates %s", err)
 		}
-		done <- candidates
-	}()
-	var candidates []ffi.Candidate
-	select {
-	case <-ctx.Done():
-		log.Infow("Mining run on tipset with null blocks canceled.", "tipset", base, "nullBlocks", nullBlkCount)
-	case err := <-errCh:
-		log.Warnf("Worker.Mine failed to get ssi for %s", err)
-		outCh <- Output{Err: err}
-		return
-	case genResult := <-done:
-		candidates = genResult
+		return nil, err
 	}
 
 	// Look for any winning candidates
 	sectorNum, err := powerTable.NumSectors(ctx, w.minerAddr)
 	if err != nil {
 		log.Errorf("failed to get number of sectors for miner: %s", err)
-		outCh <- Output{Err: err}
-		return
+		return nil, err
 	}
 	networkPower, err := powerTable.Total(ctx)
 	if err != nil {
 		log.Errorf("failed to get total power: %s", err)
-		outCh <- Output{Err: err}
-		return
+		return nil, err
 	}
 	sectorSize, err := powerTable.SectorSize(ctx, w.minerAddr)
 	if err != nil {
 		log.Errorf("failed to get sector size for miner: %s", err)
-		outCh <- Output{Err: err}
-		return
+		return nil, err
 	}
+	winners := SelectWinners(candidates, func(challengeTicket []byte) bool {
+		// Dragons: converting to uint64 here is not safe
+		// Dragons: must set fault count, not zero
+		return w.election.CandidateWins(challengeTicket, sectorNum, 0, networkPower.Uint64(), uint64(sectorSize))
+	})
+
+	return &MiningRound{
+		WorkerAddr:     workerAddr,
+		NextTicket:     nextTicket,
+		ElectionTicket: electionTicket,
+		PoStRandomness: postRandomness,
+		SectorInfos:    sortedSectorInfos,
+		Candidates:     candidates,
+		Winners:        winners,
+	}, nil
+}
+
+// SelectWinners returns the candidates whose challenge ticket wins the
+// election according to `wins`, ordered by ascending SectorID.
+//
+// Candidates with identical partial tickets hash to the same challenge ticket.
+// When this happens only the candidate with the lowest SectorID is kept, so
+// that every node derives the same set of winners from the same candidates.
+func SelectWinners(candidates []ffi.Candidate, wins func(challengeTicket []byte) bool) []ffi.Candidate {
+	sorted := make([]ffi.Candidate, len(candidates))
+	copy(sorted, candidates)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].SectorNum < sorted[j].SectorNum
+	})
+
 	hasher := hasher.NewHasher()
+	seen := make(map[string]struct{})
 	var winners []ffi.Candidate
-	for _, candidate := range candidates {
+	for _, candidate := range sorted {
 		hasher.Bytes(candidate.PartialTicket[:])
 		challengeTicket := hasher.Hash()
-		// Dragons: converting to uint64 here is not safe
-		// Dragons: must set fault count, not zero
-		if w.election.CandidateWins(challengeTicket, sectorNum, 0, networkPower.Uint64(), uint64(sectorSize)) {
+		if _, ok := seen[string(challengeTicket)]; ok {
+			continue
+		}
+		if wins(challengeTicket) {
+			seen[string(challengeTicket)] = struct{}{}
 			winners = append(winners, candidate)
 		}
 	}
+	return winners
+}
 
-	// no winners we are done
-	if len(winners) == 0 {
-		return
+// WorkerAddressAt returns the miner's worker address in the state at the
+// tipset identified by `key`.
+func (w *DefaultWorker) WorkerAddressAt(ctx context.Context, key block.TipSetKey) (address.Address, error) {
+	view, err := w.api.PowerStateView(key)
+	if err != nil {
+		return address.Undef, errors.Wrapf(err, "failed to read state view at %s", key)
 	}
-	// we have a winning block
+	_, workerAddr, err := view.MinerControlAddresses(ctx, w.minerAddr)
+	if err != nil {
+		return address.Undef, errors.Wrapf(err, "failed to read worker address at %s", key)
+	}
+	return workerAddr, nil
+}
 
-	// Generate PoSt
-	postDone := make(chan []byte)
-	errCh = make(chan error)
-	go func() {
-		defer close(postDone)
-		defer close(errCh)
-		post, err := w.election.GeneratePoSt(sortedSectorInfos, postRandomness, winners, w.poster)
-		if err != nil {
-			errCh <- err
-			return
-		}
-		postDone <- post
-	}()
-	var post []byte
-	select {
-	case <-ctx.Done():
-		log.Infow("Mining run on tipset with null blocks canceled.", "tipset", base, "nullBlocks", nullBlkCount)
-	case err := <-errCh:
-		log.Warnf("Worker.Mine failed to generate post %s", err)
-		outCh <- Output{Err: err}
-		return
-	case postOut := <-postDone:
-		post = postOut
+// ReloadSigner replaces the worker signer with one able to sign for
+// `worker`. It is a no-op if the worker has no signer loader.
+func (w *DefaultWorker) ReloadSigner(worker address.Address) error {
+	if w.loadSigner == nil {
+		return nil
 	}
+	signer, err := w.loadSigner(worker)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load signer for worker %s", worker)
+	}
+	w.workerSigner.set(signer)
+	return nil
+}
 
-	postInfo := block.NewEPoStInfo(post, postRandomness, block.FromFFICandidates(winners...)...)
+// reloadableSigner is a types.Signer whose underlying signer may be swapped
+// out while mining jobs are in flight.
+type reloadableSigner struct {
+	mu     sync.Mutex
+	signer types.Signer
+}
 
-	next, err := w.Generate(ctx, base, nextTicket, abi.ChainEpoch(nullBlkCount), postInfo)
-	if err == nil {
-		log.Debugf("Worker.Mine generates new winning block! %s", next.Cid().String())
-	}
-	outCh <- NewOutput(next, err)
-	won = true
-	return
+// SignBytes signs with the current underlying signer.
+func (s *reloadableSigner) SignBytes(data []byte, addr address.Address) (crypto.Signature, error) {
+	s.mu.Lock()
+	signer := s.signer
+	s.mu.Unlock()
+	return signer.SignBytes(data, addr)
+}
+
+func (s *reloadableSigner) set(signer types.Signer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signer = signer
 }
 
 func (w *DefaultWorker) getPowerTable(ctx context.Context, baseKey block.TipSetKey) (consensus.PowerTableView, error) {
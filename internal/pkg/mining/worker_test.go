@@ -32,11 +32,13 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/mining"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	appstate "github.com/filecoin-project/go-filecoin/internal/pkg/state"
 	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
 )
 
@@ -847,6 +849,105 @@ func TestGenerateError(t *testing.T) {
 	assert.Len(t, pool.Pending(), 1) // No messages are removed from the pool.
 }
 
+func TestSelectWinnersBreaksTiesBySectorID(t *testing.T) {
+	tf.UnitTest(t)
+
+	alwaysWins := func([]byte) bool { return true }
+	collidingTicket := [32]byte{0xa}
+	candidates := []bls.Candidate{
+		{SectorNum: 7, PartialTicket: collidingTicket, SectorChallengeIndex: 0},
+		{SectorNum: 5, PartialTicket: [32]byte{0xb}, SectorChallengeIndex: 1},
+		{SectorNum: 3, PartialTicket: collidingTicket, SectorChallengeIndex: 2},
+	}
+
+	winners := mining.SelectWinners(candidates, alwaysWins)
+	require.Len(t, winners, 2)
+	assert.Equal(t, abi.SectorNumber(3), winners[0].SectorNum)
+	assert.Equal(t, abi.SectorNumber(5), winners[1].SectorNum)
+
+	// The result does not depend on the order candidates were generated in.
+	reversed := []bls.Candidate{candidates[2], candidates[1], candidates[0]}
+	assert.Equal(t, winners, mining.SelectWinners(reversed, alwaysWins))
+
+	// Losing candidates are never selected.
+	assert.Empty(t, mining.SelectWinners(candidates, func([]byte) bool { return false }))
+}
+
+func TestNewWeightCalculator(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genesis := builder.NewGenesis()
+	child := builder.AppendOn(genesis, 2)
+
+	genesisRoot, err := builder.GetTipSetStateRoot(genesis.Key())
+	require.NoError(t, err)
+	view := appstate.NewFakeStateView(abi.NewStoragePower(16))
+	viewer := consensus.FakePowerStateViewer{
+		Views: map[cid.Cid]*appstate.FakeStateView{genesisRoot: view},
+	}
+	selector := consensus.NewChainSelector(cbor.NewMemCborStore(), &viewer, genesis.At(0).Cid())
+	expected, err := selector.Weight(ctx, child, genesisRoot)
+	require.NoError(t, err)
+
+	t.Run("weighs against the view", func(t *testing.T) {
+		getWeight := mining.NewWeightCalculator(view)
+		actual, err := getWeight(ctx, child)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+
+		genesisWeight, err := getWeight(ctx, genesis)
+		require.NoError(t, err)
+		assert.Equal(t, fbig.Zero(), genesisWeight)
+	})
+
+	t.Run("weighs against the parent state", func(t *testing.T) {
+		getWeight := mining.NewChainWeightCalculator(&viewer, builder)
+		actual, err := getWeight(ctx, child)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+
+		genesisWeight, err := getWeight(ctx, genesis)
+		require.NoError(t, err)
+		assert.Equal(t, fbig.Zero(), genesisWeight)
+	})
+}
+
+func TestWeightDelta(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genesis := builder.NewGenesis()
+	heavier := builder.AppendOn(genesis, 3)
+	lighter := builder.AppendOn(genesis, 1)
+
+	delta, err := mining.WeightDelta(ctx, heavier, lighter, getWeightTest)
+	require.NoError(t, err)
+	assert.Equal(t, fbig.NewInt(20), delta)
+
+	delta, err = mining.WeightDelta(ctx, lighter, heavier, getWeightTest)
+	require.NoError(t, err)
+	assert.Equal(t, fbig.NewInt(-20), delta)
+
+	// tipsets at different heights
+	higher := builder.AppendOn(lighter, 1)
+	higherWeight, err := getWeightTest(ctx, higher)
+	require.NoError(t, err)
+	heavierWeight, err := getWeightTest(ctx, heavier)
+	require.NoError(t, err)
+	delta, err = mining.WeightDelta(ctx, higher, heavier, getWeightTest)
+	require.NoError(t, err)
+	assert.Equal(t, fbig.Sub(higherWeight, heavierWeight), delta)
+
+	failingWeight := func(context.Context, block.TipSet) (fbig.Int, error) {
+		return fbig.Zero(), errors.New("boom")
+	}
+	_, err = mining.WeightDelta(ctx, heavier, lighter, failingWeight)
+	assert.Error(t, err)
+}
+
 func getWeightTest(_ context.Context, ts block.TipSet) (fbig.Int, error) {
 	w, err := ts.ParentWeight()
 	if err != nil {
@@ -877,3 +978,94 @@ func (tm fakeTSMetadata) GetTipSetStateRoot(key block.TipSetKey) (cid.Cid, error
 func (tm fakeTSMetadata) GetTipSetReceiptsRoot(key block.TipSetKey) (cid.Cid, error) {
 	return dag.NewRawNode([]byte("receipt root")).Cid(), nil
 }
+
+func TestMineRejectsTooManyNullBlocks(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	base := builder.AppendOn(builder.NewGenesis(), 1)
+
+	fetched := false
+	getAncestors := func(context.Context, block.TipSet, abi.ChainEpoch) ([]block.TipSet, error) {
+		fetched = true
+		return nil, nil
+	}
+	worker := mining.NewDefaultWorker(mining.WorkerParameters{
+		GetAncestors:  getAncestors,
+		MaxNullBlocks: 5,
+	})
+
+	outCh := make(chan mining.Output, 1)
+	won := worker.Mine(ctx, base, 6, outCh)
+	assert.False(t, won)
+
+	out := <-outCh
+	require.Error(t, out.Err)
+	assert.True(t, errors.Is(out.Err, mining.ErrTooManyNullBlocks))
+	assert.False(t, fetched)
+}
+
+// keyedViewAPI serves a different power state view for each tipset.
+type keyedViewAPI struct {
+	views map[string]consensus.PowerStateView
+}
+
+func (a *keyedViewAPI) BlockTime() time.Duration {
+	return th.BlockTimeTest
+}
+
+func (a *keyedViewAPI) PowerStateView(key block.TipSetKey) (consensus.PowerStateView, error) {
+	view, ok := a.views[key.String()]
+	if !ok {
+		return nil, errors.New("no view for tipset")
+	}
+	return view, nil
+}
+
+func TestWorkerAddressAt(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	addrs := vmaddr.NewForTestGetter()
+	minerAddr, ownerAddr, oldWorker, newWorker := addrs(), addrs(), addrs(), addrs()
+
+	builder := chain.NewBuilder(t, address.Undef)
+	before := builder.AppendOn(builder.NewGenesis(), 1)
+	after := builder.AppendOn(before, 1)
+	unknown := builder.AppendOn(after, 1)
+
+	viewWithWorker := func(worker address.Address) consensus.PowerStateView {
+		view := appstate.NewFakeStateView(abi.NewStoragePower(1))
+		view.Miners[minerAddr] = &appstate.FakeMinerState{Owner: ownerAddr, Worker: worker}
+		return view
+	}
+	api := &keyedViewAPI{views: map[string]consensus.PowerStateView{
+		before.Key().String(): viewWithWorker(oldWorker),
+		after.Key().String():  viewWithWorker(newWorker),
+	}}
+
+	var loaded []address.Address
+	worker := mining.NewDefaultWorker(mining.WorkerParameters{
+		API:       api,
+		MinerAddr: minerAddr,
+		LoadSigner: func(worker address.Address) (types.Signer, error) {
+			loaded = append(loaded, worker)
+			return types.NewMockSigner(types.MustGenerateKeyInfo(1, 42)), nil
+		},
+	})
+
+	workerAddr, err := worker.WorkerAddressAt(ctx, before.Key())
+	require.NoError(t, err)
+	assert.Equal(t, oldWorker, workerAddr)
+
+	workerAddr, err = worker.WorkerAddressAt(ctx, after.Key())
+	require.NoError(t, err)
+	assert.Equal(t, newWorker, workerAddr)
+
+	_, err = worker.WorkerAddressAt(ctx, unknown.Key())
+	assert.Error(t, err)
+
+	require.NoError(t, worker.ReloadSigner(newWorker))
+	assert.Equal(t, []address.Address{newWorker}, loaded)
+}
@@ -0,0 +1,61 @@
+package net
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+)
+
+// DefaultSeenBlockCacheSize is the number of recently received block CIDs
+// remembered for deduplicating gossiped blocks.
+const DefaultSeenBlockCacheSize = 1024
+
+// SeenBlockCache is a bounded set of block CIDs which evicts the least
+// recently seen CID once full. It is safe for concurrent use.
+type SeenBlockCache struct {
+	lk       sync.Mutex
+	capacity int
+	order    *list.List // of cid.Cid, most recently seen at the front
+	index    map[cid.Cid]*list.Element
+}
+
+// NewSeenBlockCache returns an empty cache holding at most `capacity` CIDs.
+func NewSeenBlockCache(capacity int) *SeenBlockCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &SeenBlockCache{
+		capacity: capacity,
+		order:    list.New(),
+		index:    make(map[cid.Cid]*list.Element),
+	}
+}
+
+// Has returns true if `c` is in the cache, marking it as recently seen.
+func (sc *SeenBlockCache) Has(c cid.Cid) bool {
+	sc.lk.Lock()
+	defer sc.lk.Unlock()
+	if el, ok := sc.index[c]; ok {
+		sc.order.MoveToFront(el)
+		return true
+	}
+	return false
+}
+
+// Add records `c` as seen, returning false if it was already present.
+func (sc *SeenBlockCache) Add(c cid.Cid) bool {
+	sc.lk.Lock()
+	defer sc.lk.Unlock()
+	if el, ok := sc.index[c]; ok {
+		sc.order.MoveToFront(el)
+		return false
+	}
+	sc.index[c] = sc.order.PushFront(c)
+	if sc.order.Len() > sc.capacity {
+		oldest := sc.order.Back()
+		sc.order.Remove(oldest)
+		delete(sc.index, oldest.Value.(cid.Cid))
+	}
+	return true
+}
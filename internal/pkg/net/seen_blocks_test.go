@@ -0,0 +1,40 @@
+package net_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/net"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+func TestSeenBlockCache(t *testing.T) {
+	tf.UnitTest(t)
+
+	a := types.CidFromString(t, "a")
+	b := types.CidFromString(t, "b")
+	c := types.CidFromString(t, "c")
+
+	t.Run("add reports duplicates", func(t *testing.T) {
+		cache := net.NewSeenBlockCache(2)
+		assert.False(t, cache.Has(a))
+		assert.True(t, cache.Add(a))
+		assert.False(t, cache.Add(a))
+		assert.True(t, cache.Has(a))
+	})
+
+	t.Run("evicts least recently seen", func(t *testing.T) {
+		cache := net.NewSeenBlockCache(2)
+		cache.Add(a)
+		cache.Add(b)
+		// Seeing a again makes b the oldest.
+		assert.True(t, cache.Has(a))
+		cache.Add(c)
+
+		assert.True(t, cache.Has(a))
+		assert.False(t, cache.Has(b))
+		assert.True(t, cache.Has(c))
+	})
+}
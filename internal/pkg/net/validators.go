@@ -9,6 +9,7 @@ import (
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/constants"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 )
@@ -17,6 +18,7 @@ var blockTopicLogger = logging.Logger("net/block_validator")
 var messageTopicLogger = logging.Logger("net/message_validator")
 var mDecodeBlkFail = metrics.NewInt64Counter("net/pubsub_block_decode_failure", "Number of blocks that fail to decode seen on BlockTopic pubsub channel")
 var mInvalidBlk = metrics.NewInt64Counter("net/pubsub_invalid_block", "Number of blocks that fail syntax validation seen on BlockTopic pubsub channel")
+var mDuplicateBlk = metrics.NewInt64Counter("net/pubsub_duplicate_block", "Number of already seen blocks dropped from BlockTopic pubsub channel")
 var mDecodeMsgFail = metrics.NewInt64Counter("net/pubsub_message_decode_failure", "Number of messages that fail to decode seen on MessageTopic pubsub channel")
 var mInvalidMsg = metrics.NewInt64Counter("net/pubsub_invalid_message", "Number of messages that fail syntax validation seen on MessageTopic pubsub channel")
 
@@ -27,11 +29,23 @@ type BlockTopicValidator struct {
 	opts      []pubsub.ValidatorOpt
 }
 
-// NewBlockTopicValidator retruns a BlockTopicValidator using `bv` for message validation
+// NewBlockTopicValidator retruns a BlockTopicValidator using `bv` for message validation.
+// Blocks which have already passed validation are dropped without being
+// decoded again, so a block relayed by several peers is only processed once.
 func NewBlockTopicValidator(bv consensus.BlockSyntaxValidator, opts ...pubsub.ValidatorOpt) *BlockTopicValidator {
+	seen := NewSeenBlockCache(DefaultSeenBlockCacheSize)
 	return &BlockTopicValidator{
 		opts: opts,
 		validator: func(ctx context.Context, p peer.ID, msg *pubsub.Message) bool {
+			// The block CID is the hash of its encoding, so a duplicate can
+			// be recognised from the raw message data.
+			blkCid, err := constants.DefaultCidBuilder.Sum(msg.GetData())
+			if err == nil && seen.Has(blkCid) {
+				blockTopicLogger.Debugf("block: %s from peer: %s already seen", blkCid.String(), p.String())
+				mDuplicateBlk.Inc(ctx, 1)
+				return false
+			}
+
 			blk, err := block.DecodeBlock(msg.GetData())
 			if err != nil {
 				blockTopicLogger.Debugf("block from peer: %s failed to decode: %s", p.String(), err.Error())
@@ -43,6 +57,11 @@ func NewBlockTopicValidator(bv consensus.BlockSyntaxValidator, opts ...pubsub.Va
 				mInvalidBlk.Inc(ctx, 1)
 				return false
 			}
+			// Only the first of concurrent copies of a block is accepted.
+			if !seen.Add(blk.Cid()) {
+				mDuplicateBlk.Inc(ctx, 1)
+				return false
+			}
 			return true
 		},
 	}
@@ -51,6 +51,40 @@ func TestBlockTopicValidator(t *testing.T) {
 	assert.False(t, validator(ctx, pid1, nonBlkPubSubMsg()))
 }
 
+// countingSyntaxValidator counts the blocks it validates.
+type countingSyntaxValidator struct {
+	calls int
+}
+
+func (v *countingSyntaxValidator) ValidateSyntax(_ context.Context, _ *block.Block) error {
+	v.calls++
+	return nil
+}
+
+func TestBlockTopicValidatorDropsDuplicates(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	bv := &countingSyntaxValidator{}
+	validator := net.NewBlockTopicValidator(bv).Validator()
+	builder := chain.NewBuilder(t, address.Undef)
+	pid1 := th.RequireIntPeerID(t, 1)
+	pid2 := th.RequireIntPeerID(t, 2)
+
+	blk := builder.BuildOnBlock(nil, func(b *chain.BlockBuilder) {})
+	other := builder.BuildOnBlock(nil, func(b *chain.BlockBuilder) {})
+
+	assert.True(t, validator(ctx, pid1, blkToPubSub(blk)))
+	assert.Equal(t, 1, bv.calls)
+
+	// The same block from another peer is dropped without validation.
+	assert.False(t, validator(ctx, pid2, blkToPubSub(blk)))
+	assert.Equal(t, 1, bv.calls)
+
+	assert.True(t, validator(ctx, pid2, blkToPubSub(other)))
+	assert.Equal(t, 2, bv.calls)
+}
+
 func TestBlockPubSubValidation(t *testing.T) {
 	tf.IntegrationTest(t)
 	ctx := context.Background()
@@ -57,7 +57,10 @@ const (
 	},
 	"mpool": {
 		"maxPoolSize": 10000,
-		"maxNonceGap": 100
+		"maxNonceGap": 100,
+		"minGasPrice": "0",
+		"flaggedSenders": [],
+		"flaggedSenderMinGasPrice": "0"
 	},
 	"observability": {
 		"metrics": {
@@ -10,25 +10,35 @@ import (
 // SampleNthTicket produces a ticket sampled from the nth tipset in the
 // provided ancestor slice.  It handles sampling from genesis.
 func SampleNthTicket(n int, tipSetsDescending []block.TipSet) (block.Ticket, error) {
+	ts, err := SampleNthTipSet(n, tipSetsDescending)
+	if err != nil {
+		return block.Ticket{}, err
+	}
+	return ts.MinTicket()
+}
+
+// SampleNthTipSet returns the nth tipset in the provided ancestor slice, or
+// the genesis tipset if the slice reaches it before the nth.
+func SampleNthTipSet(n int, tipSetsDescending []block.TipSet) (block.TipSet, error) {
 	if len(tipSetsDescending) == 0 {
-		return block.Ticket{}, errors.New("can't sample empty chain segment")
+		return block.UndefTipSet, errors.New("can't sample empty chain segment")
 	}
 	lastIdx := len(tipSetsDescending) - 1
 	if n > lastIdx {
 		// Handle chain startup
 		lowestAvailableHeight, err := tipSetsDescending[lastIdx].Height()
 		if err != nil {
-			return block.Ticket{}, errors.Wrap(err, "failed to read chain segment height")
+			return block.UndefTipSet, errors.Wrap(err, "failed to read chain segment height")
 		}
-		// Return genesis ticket if this is the edge case where
+		// Return genesis if this is the edge case where
 		// tipSetsDescending run all the way back to genesis
 		if lowestAvailableHeight == 0 {
-			return tipSetsDescending[lastIdx].MinTicket()
+			return tipSetsDescending[lastIdx], nil
 		}
-		return block.Ticket{}, errors.Errorf("can't sample ticket %d from %d tipsets", n, lastIdx+1)
+		return block.UndefTipSet, errors.Errorf("can't sample tipset %d from %d tipsets", n, lastIdx+1)
 	}
 
-	return tipSetsDescending[n].MinTicket()
+	return tipSetsDescending[n], nil
 }
 
 // SampleChainRandomness produces a slice of bytes (a ticket) sampled from the highest tipset with
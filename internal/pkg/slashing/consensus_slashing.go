@@ -65,3 +65,12 @@ func (detector *ConsensusFaultDetector) CheckBlock(b *block.Block, p block.TipSe
 	}
 	return nil
 }
+
+// DetectEquivocation returns true iff `a` and `b` are distinct blocks mined by
+// the same miner at the same height on the same parents.
+func DetectEquivocation(a, b *block.Block) bool {
+	return a.Miner == b.Miner &&
+		a.Height == b.Height &&
+		a.Parents.Equals(b.Parents) &&
+		!a.Cid().Equals(b.Cid())
+}
@@ -9,6 +9,7 @@ import (
 	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
 	. "github.com/filecoin-project/go-filecoin/internal/pkg/slashing"
 	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
 )
@@ -136,3 +137,29 @@ func TestFaultNullBlocks(t *testing.T) {
 	})
 
 }
+
+func TestDetectEquivocation(t *testing.T) {
+	tf.UnitTest(t)
+
+	addrGetter := vmaddr.NewForTestGetter()
+	minerAddr := addrGetter()
+	parents := block.NewTipSetKey(types.CidFromString(t, "parent"))
+
+	t.Run("distinct blocks on the same parents equivocate", func(t *testing.T) {
+		block1 := &block.Block{Miner: minerAddr, Height: 43, Parents: parents, StateRoot: e.NewCid(types.CidFromString(t, "some-state"))}
+		block2 := &block.Block{Miner: minerAddr, Height: 43, Parents: parents, StateRoot: e.NewCid(types.CidFromString(t, "some-other-state"))}
+		assert.True(t, DetectEquivocation(block1, block2))
+	})
+
+	t.Run("the same block does not equivocate", func(t *testing.T) {
+		block1 := &block.Block{Miner: minerAddr, Height: 43, Parents: parents}
+		block2 := &block.Block{Miner: minerAddr, Height: 43, Parents: parents}
+		assert.False(t, DetectEquivocation(block1, block2))
+	})
+
+	t.Run("blocks at different heights do not equivocate", func(t *testing.T) {
+		block1 := &block.Block{Miner: minerAddr, Height: 43, Parents: parents}
+		block2 := &block.Block{Miner: minerAddr, Height: 44, Parents: parents}
+		assert.False(t, DetectEquivocation(block1, block2))
+	})
+}
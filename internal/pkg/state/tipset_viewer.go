@@ -1,6 +1,9 @@
 package state
 
 import (
+	"context"
+	"sync"
+
 	"github.com/ipfs/go-cid"
 	cbor "github.com/ipfs/go-ipld-cbor"
 	"github.com/pkg/errors"
@@ -8,6 +11,10 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 )
 
+// DefaultStateViewCacheSize is the number of state views, keyed by state
+// root, kept by a TipSetStateViewer.
+const DefaultStateViewCacheSize = 64
+
 // Abstracts over a store of blockchain state.
 type chainStateChainReader interface {
 	GetTipSetStateRoot(key block.TipSetKey) (cid.Cid, error)
@@ -19,18 +26,116 @@ type TipSetStateViewer struct {
 	chainReader chainStateChainReader
 	// To load the tree for the head tipset state root.
 	cst cbor.IpldStore
+	// Intermediate state roots recorded while applying a tipset's messages.
+	checkpoints *stateCheckpoints
+	// Recently requested views, which keep the state they have loaded.
+	views *viewCache
 }
 
 // NewTipSetStateViewer constructs a TipSetStateViewer.
 func NewTipSetStateViewer(chainReader chainStateChainReader, cst cbor.IpldStore) *TipSetStateViewer {
-	return &TipSetStateViewer{chainReader, cst}
+	return &TipSetStateViewer{chainReader, cst, newStateCheckpoints(), newViewCache(DefaultStateViewCacheSize)}
 }
 
 // StateView creates a state view after the application of a tipset's messages.
+// Views are cached by state root, so repeated requests for the same state
+// reuse what has already been loaded.
 func (cs TipSetStateViewer) StateView(baseKey block.TipSetKey) (*View, error) {
 	root, err := cs.chainReader.GetTipSetStateRoot(baseKey)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to get state root for %s", baseKey.String())
 	}
-	return NewView(cs.cst, root), nil
+	return cs.views.getOrCreate(root, func() *View {
+		return NewView(cs.cst, root)
+	}), nil
+}
+
+// RecordStateRoot checkpoints the state root reached after applying the
+// message at `messageIndex` (in application order) of the tipset identified
+// by `key`, so that an interrupted computation can resume from it.
+func (cs TipSetStateViewer) RecordStateRoot(key block.TipSetKey, messageIndex int, root cid.Cid) {
+	cs.checkpoints.put(key, messageIndex, root)
+}
+
+// StateRootAfter returns the checkpointed state root reached after applying
+// the message at `messageIndex` of the tipset identified by `key`.
+func (cs TipSetStateViewer) StateRootAfter(ctx context.Context, key block.TipSetKey, messageIndex int) (cid.Cid, error) {
+	root, ok := cs.checkpoints.get(key, messageIndex)
+	if !ok {
+		return cid.Undef, errors.Errorf("no state root recorded after message %d of %s", messageIndex, key)
+	}
+	return root, nil
+}
+
+// ForgetStateRoots drops the checkpoints of the tipset identified by `key`,
+// once its state has been fully computed and stored.
+func (cs TipSetStateViewer) ForgetStateRoots(key block.TipSetKey) {
+	cs.checkpoints.forget(key)
+}
+
+type stateCheckpoints struct {
+	mu    sync.Mutex
+	roots map[string]map[int]cid.Cid
+}
+
+func newStateCheckpoints() *stateCheckpoints {
+	return &stateCheckpoints{roots: make(map[string]map[int]cid.Cid)}
+}
+
+func (sc *stateCheckpoints) put(key block.TipSetKey, messageIndex int, root cid.Cid) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	byIndex, ok := sc.roots[key.String()]
+	if !ok {
+		byIndex = make(map[int]cid.Cid)
+		sc.roots[key.String()] = byIndex
+	}
+	byIndex[messageIndex] = root
+}
+
+func (sc *stateCheckpoints) get(key block.TipSetKey, messageIndex int) (cid.Cid, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	root, ok := sc.roots[key.String()][messageIndex]
+	return root, ok
+}
+
+func (sc *stateCheckpoints) forget(key block.TipSetKey) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	delete(sc.roots, key.String())
+}
+
+// viewCache is a bounded cache of state views keyed by state root. Once full
+// the oldest view is evicted.
+type viewCache struct {
+	mu    sync.Mutex
+	size  int
+	order []cid.Cid
+	views map[cid.Cid]*View
+}
+
+func newViewCache(size int) *viewCache {
+	return &viewCache{
+		size:  size,
+		order: make([]cid.Cid, 0, size),
+		views: make(map[cid.Cid]*View, size),
+	}
+}
+
+func (vc *viewCache) getOrCreate(root cid.Cid, create func() *View) *View {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	if view, ok := vc.views[root]; ok {
+		return view
+	}
+	view := create()
+	if len(vc.order) >= vc.size {
+		oldest := vc.order[0]
+		vc.order = vc.order[1:]
+		delete(vc.views, oldest)
+	}
+	vc.order = append(vc.order, root)
+	vc.views[root] = view
+	return view
 }
@@ -0,0 +1,190 @@
+package state_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/util/adt"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/state"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+	vmstate "github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
+)
+
+func TestStateRootAfter(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	cst := cborutil.NewIpldStore(blockstore.NewBlockstore(datastore.NewMapDatastore()))
+	viewer := state.NewTipSetStateViewer(nil, cst)
+	key := block.NewTipSetKey(types.CidFromString(t, "tipset"))
+
+	// Each "message" credits an actor; applying the first n of them is a replay.
+	addrGetter := vmaddr.NewForTestGetter()
+	type credit struct {
+		addr    address.Address
+		balance abi.TokenAmount
+	}
+	messages := []credit{
+		{addrGetter(), abi.NewTokenAmount(10)},
+		{addrGetter(), abi.NewTokenAmount(20)},
+		{addrGetter(), abi.NewTokenAmount(30)},
+	}
+	apply := func(tree vmstate.Tree, m credit) cid.Cid {
+		require.NoError(t, tree.SetActor(ctx, m.addr, actor.NewActor(builtin.AccountActorCodeID, m.balance)))
+		root, err := tree.Flush(ctx)
+		require.NoError(t, err)
+		return root
+	}
+
+	tree := vmstate.NewTree(cst)
+	for i, m := range messages {
+		viewer.RecordStateRoot(key, i, apply(tree, m))
+	}
+
+	for i := range messages {
+		replay := vmstate.NewTree(cst)
+		var expected cid.Cid
+		for _, m := range messages[:i+1] {
+			expected = apply(replay, m)
+		}
+
+		root, err := viewer.StateRootAfter(ctx, key, i)
+		require.NoError(t, err)
+		assert.Equal(t, expected, root)
+	}
+
+	_, err := viewer.StateRootAfter(ctx, key, len(messages))
+	assert.Error(t, err)
+
+	viewer.ForgetStateRoots(key)
+	_, err = viewer.StateRootAfter(ctx, key, 0)
+	assert.Error(t, err)
+}
+
+// countingStore counts the objects read from an IpldStore.
+type countingStore struct {
+	cbor.IpldStore
+	gets int
+}
+
+func (s *countingStore) Get(ctx context.Context, c cid.Cid, out interface{}) error {
+	s.gets++
+	return s.IpldStore.Get(ctx, c, out)
+}
+
+// fakeStateRoots maps tipset keys to state roots.
+type fakeStateRoots map[string]cid.Cid
+
+func (f fakeStateRoots) GetTipSetStateRoot(key block.TipSetKey) (cid.Cid, error) {
+	return f[key.String()], nil
+}
+
+// requireMinerState stores a state tree holding a single miner actor and
+// returns its root.
+func requireMinerState(t require.TestingT, cst cbor.IpldStore, maddr, owner, worker address.Address) cid.Cid {
+	ctx := context.Background()
+	store := state.StoreFromCbor(ctx, cst)
+	emptyMap, err := adt.MakeEmptyMap(store)
+	require.NoError(t, err)
+	emptyArray, err := adt.MakeEmptyArray(store)
+	require.NoError(t, err)
+	minerState := miner.ConstructState(emptyArray.Root(), emptyMap.Root(), owner, worker, peer.ID("miner"), abi.SectorSize(2048))
+	head, err := cst.Put(ctx, minerState)
+	require.NoError(t, err)
+
+	tree := vmstate.NewTree(cst)
+	minerActor := actor.NewActor(builtin.StorageMinerActorCodeID, abi.NewTokenAmount(0))
+	minerActor.Head = e.NewCid(head)
+	require.NoError(t, tree.SetActor(ctx, maddr, minerActor))
+	root, err := tree.Flush(ctx)
+	require.NoError(t, err)
+	return root
+}
+
+func TestStateViewCache(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	cst := &countingStore{IpldStore: cborutil.NewIpldStore(blockstore.NewBlockstore(datastore.NewMapDatastore()))}
+	addrGetter := vmaddr.NewForTestGetter()
+	maddr, owner, worker := addrGetter(), addrGetter(), addrGetter()
+	root := requireMinerState(t, cst, maddr, owner, worker)
+
+	key := block.NewTipSetKey(types.CidFromString(t, "tipset"))
+	viewer := state.NewTipSetStateViewer(fakeStateRoots{key.String(): root}, cst)
+
+	view, err := viewer.StateView(key)
+	require.NoError(t, err)
+	cst.gets = 0
+	cachedOwner, cachedWorker, err := view.MinerControlAddresses(ctx, maddr)
+	require.NoError(t, err)
+	firstGets := cst.gets
+
+	// A repeated request returns the same view, which doesn't reload the actor.
+	again, err := viewer.StateView(key)
+	require.NoError(t, err)
+	assert.Same(t, view, again)
+	cst.gets = 0
+	againOwner, againWorker, err := again.MinerControlAddresses(ctx, maddr)
+	require.NoError(t, err)
+	assert.Less(t, cst.gets, firstGets)
+	assert.Equal(t, cachedOwner, againOwner)
+	assert.Equal(t, cachedWorker, againWorker)
+
+	// Cached results match a fresh load.
+	freshOwner, freshWorker, err := state.NewView(cst, root).MinerControlAddresses(ctx, maddr)
+	require.NoError(t, err)
+	assert.Equal(t, owner, freshOwner)
+	assert.Equal(t, worker, freshWorker)
+	assert.Equal(t, freshOwner, cachedOwner)
+	assert.Equal(t, freshWorker, cachedWorker)
+}
+
+func BenchmarkStateView(b *testing.B) {
+	ctx := context.Background()
+	cst := cborutil.NewIpldStore(blockstore.NewBlockstore(datastore.NewMapDatastore()))
+	addrGetter := vmaddr.NewForTestGetter()
+	maddr, owner, worker := addrGetter(), addrGetter(), addrGetter()
+	root := requireMinerState(b, cst, maddr, owner, worker)
+
+	key := block.NewTipSetKey(root)
+	viewer := state.NewTipSetStateViewer(fakeStateRoots{key.String(): root}, cst)
+
+	b.Run("fresh", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, _, err := state.NewView(cst, root).MinerControlAddresses(ctx, maddr); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			view, err := viewer.StateView(key)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, _, err := view.MinerControlAddresses(ctx, maddr); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -2,6 +2,7 @@ package state
 
 import (
 	"context"
+	"sync"
 
 	addr "github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
@@ -42,6 +43,11 @@ func (c *Viewer) StateView(root cid.Cid) *View {
 type View struct {
 	ipldStore cbor.IpldStore
 	root      cid.Cid
+
+	// actors memoizes the actors read from the state tree. The tree under a
+	// root never changes, so they never need to be invalidated.
+	actorsMu sync.Mutex
+	actors   map[addr.Address]actor.Actor
 }
 
 // NewView creates a new state view
@@ -49,6 +55,7 @@ func NewView(store cbor.IpldStore, root cid.Cid) *View {
 	return &View{
 		ipldStore: store,
 		root:      root,
+		actors:    make(map[addr.Address]actor.Actor),
 	}
 }
 
@@ -234,13 +241,26 @@ func (v *View) loadPowerActor(ctx context.Context) (*power.State, error) {
 }
 
 func (v *View) loadActor(ctx context.Context, address addr.Address) (*actor.Actor, error) {
+	v.actorsMu.Lock()
+	actr, ok := v.actors[address]
+	v.actorsMu.Unlock()
+	if ok {
+		return &actr, nil
+	}
+
 	tree := v.asMap(ctx, v.root)
-	var actr actor.Actor
 	found, err := tree.Get(adt.AddrKey(address), &actr)
 	if !found {
 		return nil, errors.Errorf("no actor at %v", address)
 	}
-	return &actr, err
+	if err != nil {
+		return nil, err
+	}
+
+	v.actorsMu.Lock()
+	v.actors[address] = actr
+	v.actorsMu.Unlock()
+	return &actr, nil
 }
 
 func (v *View) asArray(ctx context.Context, root cid.Cid) *adt.Array {
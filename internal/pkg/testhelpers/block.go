@@ -1,10 +1,14 @@
 package testhelpers
 
 import (
+	"fmt"
 	"testing"
 
-	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/ipfs/go-cid"
 	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/constants"
 )
 
 // RequireNewTipSet instantiates and returns a new tipset of the given blocks
@@ -14,3 +18,16 @@ func RequireNewTipSet(t *testing.T, blks ...*block.Block) block.TipSet {
 	require.NoError(t, err)
 	return ts
 }
+
+// TipSetKeyFromHeights returns a synthetic tipset key with one CID per
+// height. The CID for a height is always the same, so equal heights give
+// equal keys.
+func TipSetKeyFromHeights(t *testing.T, heights ...int) block.TipSetKey {
+	cids := make([]cid.Cid, len(heights))
+	for i, h := range heights {
+		c, err := constants.DefaultCidBuilder.Sum([]byte(fmt.Sprintf("height %d", h)))
+		require.NoError(t, err)
+		cids[i] = c
+	}
+	return block.NewTipSetKey(cids...)
+}
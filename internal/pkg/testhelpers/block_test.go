@@ -0,0 +1,20 @@
+package testhelpers_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestTipSetKeyFromHeights(t *testing.T) {
+	tf.UnitTest(t)
+
+	assert.Equal(t, th.TipSetKeyFromHeights(t, 1, 2), th.TipSetKeyFromHeights(t, 1, 2))
+	assert.Equal(t, th.TipSetKeyFromHeights(t, 1, 2), th.TipSetKeyFromHeights(t, 2, 1))
+	assert.Equal(t, 2, th.TipSetKeyFromHeights(t, 1, 2).Len())
+	assert.False(t, th.TipSetKeyFromHeights(t, 1).Equals(th.TipSetKeyFromHeights(t, 2)))
+	assert.True(t, th.TipSetKeyFromHeights(t).Empty())
+}
@@ -44,9 +44,7 @@ func RequireSignedTestBlockFromTipSet(t *testing.T, baseTipSet block.TipSet, sta
 		BLSAggregateSig: emptyBLSSig,
 		EPoStInfo:       postInfo,
 	}
-	sig, err := signer.SignBytes(b.SignatureData(), minerWorker)
-	require.NoError(t, err)
-	b.BlockSig = sig
+	require.NoError(t, block.SignBlock(signer, b, minerWorker))
 
 	return b
 }
@@ -0,0 +1,76 @@
+package testhelpers
+
+import (
+	"encoding/binary"
+
+	ffi "github.com/filecoin-project/filecoin-ffi"
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/postgenerator"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/util/hasher"
+)
+
+// DeterministicElection is an election for tests which replaces all proving
+// with hashing, so mining runs are reproducible without FFI. Randomness and
+// candidates are derived from a seed and the election inputs, and a
+// candidate wins when its challenge ticket, read as a big-endian integer, is
+// divisible by the configured win rate.
+type DeterministicElection struct {
+	seed    []byte
+	winRate uint64
+}
+
+// NewDeterministicElection returns an election drawing from `seed` in which
+// on average one in `winRate` candidates wins. A win rate of 1 or less makes
+// every candidate win.
+func NewDeterministicElection(seed []byte, winRate uint64) *DeterministicElection {
+	if winRate == 0 {
+		winRate = 1
+	}
+	return &DeterministicElection{seed: seed, winRate: winRate}
+}
+
+// GeneratePoStRandomness hashes the seed with the ticket, candidate and null
+// block count.
+func (de *DeterministicElection) GeneratePoStRandomness(ticket block.Ticket, candidateAddr address.Address, _ types.Signer, nullBlockCount uint64) ([]byte, error) {
+	h := hasher.NewHasher()
+	h.Bytes(de.seed)
+	h.Bytes(ticket.VRFProof)
+	h.Bytes(candidateAddr.Bytes())
+	h.Int(nullBlockCount)
+	return h.Hash(), nil
+}
+
+// GenerateCandidates returns one candidate per sector, with a partial ticket
+// hashed from the randomness and sector number.
+func (de *DeterministicElection) GenerateCandidates(poStRand []byte, sectorInfos ffi.SortedPublicSectorInfo, _ postgenerator.PoStGenerator) ([]ffi.Candidate, error) {
+	h := hasher.NewHasher()
+	var candidates []ffi.Candidate
+	for _, info := range sectorInfos.Values() {
+		h.Bytes(poStRand)
+		h.Int(uint64(info.SectorNum))
+		var partial [32]byte
+		copy(partial[:], h.Hash())
+		candidates = append(candidates, ffi.Candidate{
+			SectorNum:     info.SectorNum,
+			PartialTicket: partial,
+		})
+	}
+	return candidates, nil
+}
+
+// GeneratePoSt returns a fake post proof.
+func (de *DeterministicElection) GeneratePoSt(_ ffi.SortedPublicSectorInfo, _ []byte, _ []ffi.Candidate, _ postgenerator.PoStGenerator) ([]byte, error) {
+	return consensus.MakeFakePoStForTest(), nil
+}
+
+// CandidateWins returns true iff the first eight bytes of the challenge
+// ticket are divisible by the win rate. The remaining inputs are ignored.
+func (de *DeterministicElection) CandidateWins(challengeTicket []byte, _, _, _, _ uint64) bool {
+	var buf [8]byte
+	copy(buf[:], challengeTicket)
+	return binary.BigEndian.Uint64(buf[:])%de.winRate == 0
+}
@@ -0,0 +1,63 @@
+package testhelpers_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/mining"
+	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+// DeterministicElection must be usable as a worker's election.
+var _ = mining.WorkerParameters{Election: th.NewDeterministicElection(nil, 1)}
+
+func TestDeterministicElection(t *testing.T) {
+	tf.UnitTest(t)
+
+	miner := vmaddr.NewForTestGetter()()
+	sectors := consensus.NFakeSectorInfos(20)
+
+	// outcomes runs 30 rounds of the election, returning which rounds won.
+	outcomes := func(e *th.DeterministicElection) []bool {
+		var wins []bool
+		for round := uint64(0); round < 30; round++ {
+			rand, err := e.GeneratePoStRandomness(block.Ticket{VRFProof: []byte{1, 2, 3}}, miner, nil, round)
+			require.NoError(t, err)
+			candidates, err := e.GenerateCandidates(rand, sectors, nil)
+			require.NoError(t, err)
+			require.Len(t, candidates, 20)
+
+			winners := mining.SelectWinners(candidates, func(challengeTicket []byte) bool {
+				return e.CandidateWins(challengeTicket, 0, 0, 0, 0)
+			})
+			wins = append(wins, len(winners) > 0)
+		}
+		return wins
+	}
+
+	t.Run("same seed reproduces outcomes", func(t *testing.T) {
+		first := outcomes(th.NewDeterministicElection([]byte("seed"), 50))
+		second := outcomes(th.NewDeterministicElection([]byte("seed"), 50))
+		assert.Equal(t, first, second)
+		assert.Contains(t, first, true)
+		assert.Contains(t, first, false)
+	})
+
+	t.Run("different seeds diverge", func(t *testing.T) {
+		first := outcomes(th.NewDeterministicElection([]byte("seed"), 50))
+		other := outcomes(th.NewDeterministicElection([]byte("other seed"), 50))
+		assert.NotEqual(t, first, other)
+	})
+
+	t.Run("win rate of one always wins", func(t *testing.T) {
+		for _, won := range outcomes(th.NewDeterministicElection([]byte("seed"), 1)) {
+			assert.True(t, won)
+		}
+	})
+}
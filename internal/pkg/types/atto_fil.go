@@ -4,6 +4,8 @@ import (
 	"math/big"
 	"strings"
 
+	"github.com/pkg/errors"
+
 	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	specsabi "github.com/filecoin-project/specs-actors/actors/abi"
@@ -87,3 +89,36 @@ func NewAttoFILFromString(s string, base int) (AttoFIL, bool) {
 	_, isErr := out.Int.SetString(s, base)
 	return out, isErr
 }
+
+// SplitAttoFILByPermille divides a into len(parts) amounts, where each entry of
+// parts is a share expressed in thousandths of a. Shares must sum to 1000.
+// Any remainder left by integer division is assigned to the first part, so the
+// outputs always sum to exactly a.
+//
+// This is a function rather than a method because AttoFIL is an alias of a
+// type defined outside this package.
+func SplitAttoFILByPermille(a AttoFIL, parts []int64) ([]AttoFIL, error) {
+	if len(parts) == 0 {
+		return nil, errors.New("no shares to split between")
+	}
+	var total int64
+	for _, p := range parts {
+		if p < 0 {
+			return nil, errors.Errorf("negative share %d", p)
+		}
+		total += p
+	}
+	if total != 1000 {
+		return nil, errors.Errorf("shares sum to %d, expected 1000", total)
+	}
+
+	thousand := specsbig.NewInt(1000)
+	out := make([]AttoFIL, len(parts))
+	remainder := a
+	for i, p := range parts {
+		out[i] = specsbig.Div(specsbig.Mul(a, specsbig.NewInt(p)), thousand)
+		remainder = specsbig.Sub(remainder, out[i])
+	}
+	out[0] = specsbig.Add(out[0], remainder)
+	return out, nil
+}
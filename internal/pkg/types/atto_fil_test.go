@@ -149,6 +149,46 @@ func TestAttoFILSubtraction(t *testing.T) {
 	})
 }
 
+func TestSplitAttoFILByPermille(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("outputs sum to the input", func(t *testing.T) {
+		a := specsbig.NewInt(1000003)
+		out, err := SplitAttoFILByPermille(a, []int64{500, 300, 200})
+		require.NoError(t, err)
+		require.Len(t, out, 3)
+
+		sum := ZeroAttoFIL
+		for _, part := range out {
+			sum = specsbig.Add(sum, part)
+		}
+		assert.True(t, sum.Equals(a))
+	})
+
+	t.Run("remainder goes to the first part", func(t *testing.T) {
+		out, err := SplitAttoFILByPermille(specsbig.NewInt(10), []int64{333, 333, 334})
+		require.NoError(t, err)
+		assert.Equal(t, specsbig.NewInt(4), out[0])
+		assert.Equal(t, specsbig.NewInt(3), out[1])
+		assert.Equal(t, specsbig.NewInt(3), out[2])
+
+		again, err := SplitAttoFILByPermille(specsbig.NewInt(10), []int64{333, 333, 334})
+		require.NoError(t, err)
+		assert.Equal(t, out, again)
+	})
+
+	t.Run("errors when shares do not sum to 1000", func(t *testing.T) {
+		_, err := SplitAttoFILByPermille(specsbig.NewInt(10), []int64{500, 400})
+		assert.Error(t, err)
+
+		_, err = SplitAttoFILByPermille(specsbig.NewInt(10), []int64{})
+		assert.Error(t, err)
+
+		_, err = SplitAttoFILByPermille(specsbig.NewInt(10), []int64{1100, -100})
+		assert.Error(t, err)
+	})
+}
+
 func TestAttoFILCborMarshaling(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -227,6 +267,46 @@ func TestAttoFILJsonMarshaling(t *testing.T) {
 	})
 }
 
+func TestAttoFILCrossFormatMarshaling(t *testing.T) {
+	tf.UnitTest(t)
+
+	large, ok := NewAttoFILFromFILString("912129289198393.123456789012345678")
+	require.True(t, ok)
+
+	cases := map[string]AttoFIL{
+		"zero":           ZeroAttoFIL,
+		"small":          NewAttoFIL(big.NewInt(1)),
+		"large":          large,
+		"negative":       NewAttoFIL(big.NewInt(-42)),
+		"large negative": specsbig.Neg(large),
+	}
+	for name, value := range cases {
+		t.Run(name, func(t *testing.T) {
+			cborBytes, err := encoding.Encode(value)
+			require.NoError(t, err)
+			var fromCBOR AttoFIL
+			require.NoError(t, encoding.Decode(cborBytes, &fromCBOR))
+
+			jsonBytes, err := json.Marshal(value)
+			require.NoError(t, err)
+			var fromJSON AttoFIL
+			require.NoError(t, json.Unmarshal(jsonBytes, &fromJSON))
+
+			assert.True(t, value.Equals(fromCBOR), "cbor: want %s got %s", value, fromCBOR)
+			assert.True(t, value.Equals(fromJSON), "json: want %s got %s", value, fromJSON)
+			assert.Equal(t, fromCBOR.String(), fromJSON.String())
+
+			// Re-encoding a decoded value reproduces the original bytes.
+			cborAgain, err := encoding.Encode(fromJSON)
+			require.NoError(t, err)
+			assert.Equal(t, cborBytes, cborAgain)
+			jsonAgain, err := json.Marshal(fromCBOR)
+			require.NoError(t, err)
+			assert.Equal(t, jsonBytes, jsonAgain)
+		})
+	}
+}
+
 func TestAttoFILIsZero(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -33,6 +33,9 @@ type GasUnits int64
 // BlockGasLimit is the maximum amount of gas that can be used to execute messages in a single block
 var BlockGasLimit = GasUnits(10000000)
 
+// BlockMessageLimit is the maximum number of messages in a single block
+const BlockMessageLimit = 512
+
 // EmptyMessagesCID is the cid of an empty collection of messages.
 var EmptyMessagesCID cid.Cid
 
@@ -50,7 +53,7 @@ func init() {
 	}
 	EmptyMessagesCID = emptyAMTCid
 	EmptyReceiptsCID = emptyAMTCid
-	EmptyTxMetaCID, err = tmpCst.Put(context.Background(), TxMeta{SecpRoot: e.NewCid(EmptyMessagesCID), BLSRoot: e.NewCid(EmptyMessagesCID)})
+	EmptyTxMetaCID, err = tmpCst.Put(context.Background(), TxMeta{SecpRoot: e.NewCid(EmptyMessagesCID), BLSRoot: e.NewCid(EmptyMessagesCID), Version: CurrentTxMetaVersion})
 	if err != nil {
 		panic("could not create CID for empty TxMeta")
 	}
@@ -188,11 +191,79 @@ func NewGasPrice(price int64) AttoFIL {
 	return NewAttoFIL(big.NewInt(price))
 }
 
+// TxMetaVersion identifies the encoding of a TxMeta and the layout of the
+// message collections it references.
+type TxMetaVersion uint64
+
+const (
+	// TxMetaVersionLegacy TxMetas are encoded as a bare [SecpRoot, BLSRoot]
+	// array with no version tag.
+	TxMetaVersionLegacy TxMetaVersion = 0
+	// TxMetaVersion1 TxMetas append a version tag to the legacy fields.
+	TxMetaVersion1 TxMetaVersion = 1
+	// CurrentTxMetaVersion is the version in which new message collections
+	// are written.
+	CurrentTxMetaVersion = TxMetaVersion1
+	// LatestTxMetaVersion is the highest version that can be read.
+	LatestTxMetaVersion = TxMetaVersion1
+)
+
 // TxMeta tracks the merkleroots of both secp and bls messages separately
 type TxMeta struct {
 	_        struct{} `cbor:",toarray"`
 	SecpRoot e.Cid    `json:"secpRoot"`
 	BLSRoot  e.Cid    `json:"blsRoot"`
+	// Version selects the encoding. It is only written for versions after
+	// TxMetaVersionLegacy so that legacy TxMetas keep their cids.
+	Version TxMetaVersion `json:"version" cbor:"-"`
+}
+
+// legacyTxMeta is the untagged encoding of a TxMeta.
+type legacyTxMeta struct {
+	_        struct{} `cbor:",toarray"`
+	SecpRoot e.Cid
+	BLSRoot  e.Cid
+}
+
+// taggedTxMeta is the versioned encoding of a TxMeta. The roots keep their
+// legacy positions so selectors indexing into a TxMeta are unaffected.
+type taggedTxMeta struct {
+	_        struct{} `cbor:",toarray"`
+	SecpRoot e.Cid
+	BLSRoot  e.Cid
+	Version  TxMetaVersion
+}
+
+// MarshalCBOR encodes the TxMeta in the layout selected by its Version.
+func (m TxMeta) MarshalCBOR() ([]byte, error) {
+	switch m.Version {
+	case TxMetaVersionLegacy:
+		return encoding.Encode(legacyTxMeta{SecpRoot: m.SecpRoot, BLSRoot: m.BLSRoot})
+	case TxMetaVersion1:
+		return encoding.Encode(taggedTxMeta{SecpRoot: m.SecpRoot, BLSRoot: m.BLSRoot, Version: m.Version})
+	default:
+		return nil, errPkg.Errorf("unknown tx meta version %d", m.Version)
+	}
+}
+
+// UnmarshalCBOR decodes a TxMeta in any known layout, recording the layout
+// in its Version.
+func (m *TxMeta) UnmarshalCBOR(raw []byte) error {
+	var tagged taggedTxMeta
+	if err := encoding.Decode(raw, &tagged); err == nil {
+		if tagged.Version == TxMetaVersionLegacy || tagged.Version > LatestTxMetaVersion {
+			return errPkg.Errorf("unknown tx meta version %d", tagged.Version)
+		}
+		*m = TxMeta{SecpRoot: tagged.SecpRoot, BLSRoot: tagged.BLSRoot, Version: tagged.Version}
+		return nil
+	}
+
+	var legacy legacyTxMeta
+	if err := encoding.Decode(raw, &legacy); err != nil {
+		return errPkg.Wrap(err, "could not decode tx meta")
+	}
+	*m = TxMeta{SecpRoot: legacy.SecpRoot, BLSRoot: legacy.BLSRoot, Version: TxMetaVersionLegacy}
+	return nil
 }
 
 // String returns a readable printing string of TxMeta
@@ -8,6 +8,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
 )
@@ -105,3 +107,44 @@ func TestMessageString(t *testing.T) {
 	got := msg.String()
 	assert.Contains(t, got, cid.String())
 }
+
+func TestTxMetaVersions(t *testing.T) {
+	tf.UnitTest(t)
+
+	secp := e.NewCid(CidFromString(t, "secp"))
+	bls := e.NewCid(CidFromString(t, "bls"))
+
+	t.Run("legacy encoding is unchanged", func(t *testing.T) {
+		raw, err := encoding.Encode(TxMeta{SecpRoot: secp, BLSRoot: bls})
+		require.NoError(t, err)
+		expected, err := encoding.Encode(legacyTxMeta{SecpRoot: secp, BLSRoot: bls})
+		require.NoError(t, err)
+		assert.Equal(t, expected, raw)
+
+		var out TxMeta
+		require.NoError(t, encoding.Decode(raw, &out))
+		assert.Equal(t, TxMeta{SecpRoot: secp, BLSRoot: bls, Version: TxMetaVersionLegacy}, out)
+	})
+
+	t.Run("new messages use the latest encoding", func(t *testing.T) {
+		assert.Equal(t, LatestTxMetaVersion, CurrentTxMetaVersion)
+	})
+
+	t.Run("tagged version round trips", func(t *testing.T) {
+		meta := TxMeta{SecpRoot: secp, BLSRoot: bls, Version: TxMetaVersion1}
+		raw, err := encoding.Encode(meta)
+		require.NoError(t, err)
+
+		var out TxMeta
+		require.NoError(t, encoding.Decode(raw, &out))
+		assert.Equal(t, meta, out)
+	})
+
+	t.Run("unknown tagged version fails to decode", func(t *testing.T) {
+		raw, err := encoding.Encode(taggedTxMeta{SecpRoot: secp, BLSRoot: bls, Version: LatestTxMetaVersion + 1})
+		require.NoError(t, err)
+
+		var out TxMeta
+		assert.Error(t, encoding.Decode(raw, &out))
+	})
+}
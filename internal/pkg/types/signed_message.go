@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/filecoin-project/go-address"
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-cid"
 	cbor "github.com/ipfs/go-ipld-cbor"
@@ -76,6 +77,16 @@ func (smsg *SignedMessage) Cid() (cid.Cid, error) {
 	return obj.Cid(), nil
 }
 
+// OnChainCid returns the CID identifying the message once included in a
+// block. BLS messages are included unsigned, so theirs is the CID of the
+// unsigned message; other messages are identified by Cid.
+func (smsg *SignedMessage) OnChainCid() (cid.Cid, error) {
+	if smsg.Message.From.Protocol() == address.BLS {
+		return smsg.Message.Cid()
+	}
+	return smsg.Cid()
+}
+
 // ToNode converts the SignedMessage to an IPLD node.
 func (smsg *SignedMessage) ToNode() (ipld.Node, error) {
 	data, err := encoding.Encode(smsg)
@@ -102,11 +113,36 @@ func (smsg *SignedMessage) ToNode() (ipld.Node, error) {
 
 // VerifySignature returns true iff the signature is valid for the message content and from address.
 func (smsg *SignedMessage) VerifySignature() bool {
-	bmsg, err := smsg.Message.Marshal()
+	return VerifySignedMessage(smsg) == nil
+}
+
+// VerifySignedMessage checks that the signature on `msg` is of the type
+// required by its sender's address and was made over the message content by
+// the sender's key, returning an error describing the first problem found.
+// Messages from ID addresses can't be verified without state and are rejected.
+func VerifySignedMessage(msg *SignedMessage) error {
+	from := msg.Message.From
+	switch from.Protocol() {
+	case address.SECP256K1:
+		if msg.Signature.Type != crypto.SigTypeSecp256k1 {
+			return errors.Errorf("secp256k1 sender %s has signature of type %d", from, msg.Signature.Type)
+		}
+	case address.BLS:
+		if msg.Signature.Type != crypto.SigTypeBLS {
+			return errors.Errorf("bls sender %s has signature of type %d", from, msg.Signature.Type)
+		}
+	default:
+		return errors.Errorf("cannot verify signature of sender %s with protocol %d", from, from.Protocol())
+	}
+
+	data, err := msg.Message.Marshal()
 	if err != nil {
-		return false
+		return errors.Wrap(err, "failed to marshal message")
+	}
+	if !crypto.IsValidSignature(data, from, msg.Signature) {
+		return errors.Errorf("invalid signature by %s", from)
 	}
-	return crypto.IsValidSignature(bmsg, smsg.Message.From, smsg.Signature)
+	return nil
 }
 
 // OnChainLen returns the amount of bytes used to represent the message on chain.
@@ -133,3 +169,13 @@ func (smsg *SignedMessage) Equals(other *SignedMessage) bool {
 		smsg.Signature.Type == other.Signature.Type &&
 		bytes.Equal(smsg.Signature.Data, other.Signature.Data)
 }
+
+// HigherFeeThan tests whether the message pays a higher gas price than
+// `other`, ordering equally priced messages by sender address to give a
+// stable ordering.
+func (smsg *SignedMessage) HigherFeeThan(other *SignedMessage) bool {
+	if !smsg.Message.GasPrice.Equals(other.Message.GasPrice) {
+		return smsg.Message.GasPrice.GreaterThan(other.Message.GasPrice)
+	}
+	return bytes.Compare(smsg.Message.From.Bytes(), other.Message.From.Bytes()) < 0
+}
@@ -1,6 +1,8 @@
 package types
 
 import (
+	"bytes"
+	"fmt"
 	"reflect"
 	"testing"
 
@@ -9,6 +11,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 )
 
@@ -59,6 +62,27 @@ func TestSignedMessageCid(t *testing.T) {
 
 }
 
+func TestSignedMessageCidStableAcrossEncoding(t *testing.T) {
+	tf.UnitTest(t)
+
+	smsg := makeMessage(t, mockSigner, 41)
+	c, err := smsg.Cid()
+	require.NoError(t, err)
+
+	marshalled, err := smsg.Marshal()
+	require.NoError(t, err)
+	decoded := SignedMessage{}
+	require.NoError(t, decoded.Unmarshal(marshalled))
+
+	decodedCid, err := decoded.Cid()
+	require.NoError(t, err)
+	assert.Equal(t, c, decodedCid)
+
+	again, err := smsg.Cid()
+	require.NoError(t, err)
+	assert.Equal(t, c, again)
+}
+
 func TestSignedMessageCidToNode(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -74,12 +98,76 @@ func TestSignedMessageCidToNode(t *testing.T) {
 
 }
 
+func TestVerifySignedMessage(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("correctly signed messages pass", func(t *testing.T) {
+		signer := NewMockSigner(MustGenerateMixedKeyInfo(1, 1))
+		for i := range signer.Addresses {
+			smsg := makeMessageFrom(t, signer, i, 1)
+			assert.NoError(t, VerifySignedMessage(smsg))
+			assert.True(t, smsg.VerifySignature())
+		}
+	})
+
+	t.Run("tampered message fails", func(t *testing.T) {
+		smsg := makeMessage(t, mockSigner, 1)
+		smsg.Message.CallSeqNum++
+		assert.Error(t, VerifySignedMessage(smsg))
+		assert.False(t, smsg.VerifySignature())
+	})
+
+	t.Run("message signed by another key fails", func(t *testing.T) {
+		signer := NewMockSigner(MustGenerateKeyInfo(2, 42))
+		smsg := makeMessage(t, signer, 1)
+		other := makeMessageFrom(t, signer, 1, 1)
+		smsg.Signature = other.Signature
+		assert.Error(t, VerifySignedMessage(smsg))
+	})
+
+	t.Run("signature type must match sender", func(t *testing.T) {
+		smsg := makeMessage(t, mockSigner, 1)
+		smsg.Signature.Type = crypto.SigTypeBLS
+		assert.EqualError(t, VerifySignedMessage(smsg), fmt.Sprintf("secp256k1 sender %s has signature of type %d", smsg.Message.From, crypto.SigTypeBLS))
+	})
+
+	t.Run("ID sender fails", func(t *testing.T) {
+		smsg := makeMessage(t, mockSigner, 1)
+		idAddr, err := address.NewIDAddress(100)
+		require.NoError(t, err)
+		smsg.Message.From = idAddr
+		assert.Error(t, VerifySignedMessage(smsg))
+	})
+}
+
+func TestSignedMessageHigherFeeThan(t *testing.T) {
+	tf.UnitTest(t)
+
+	signer := NewMockSigner(MustGenerateKeyInfo(2, 42))
+	a, b := makeMessageFrom(t, signer, 0, 1), makeMessageFrom(t, signer, 1, 1)
+
+	b.Message.GasPrice = NewGasPrice(1001)
+	assert.True(t, b.HigherFeeThan(a))
+	assert.False(t, a.HigherFeeThan(b))
+
+	// Equally priced messages are ordered by sender.
+	b.Message.GasPrice = a.Message.GasPrice
+	aFirst := bytes.Compare(a.Message.From.Bytes(), b.Message.From.Bytes()) < 0
+	assert.Equal(t, aFirst, a.HigherFeeThan(b))
+	assert.Equal(t, !aFirst, b.HigherFeeThan(a))
+	assert.False(t, a.HigherFeeThan(a))
+}
+
 func makeMessage(t *testing.T, signer MockSigner, nonce uint64) *SignedMessage {
+	return makeMessageFrom(t, signer, 0, nonce)
+}
+
+func makeMessageFrom(t *testing.T, signer MockSigner, from int, nonce uint64) *SignedMessage {
 	newAddr, err := address.NewSecp256k1Address([]byte("receiver"))
 	require.NoError(t, err)
 
 	msg := NewMeteredMessage(
-		signer.Addresses[0],
+		signer.Addresses[from],
 		newAddr,
 		nonce,
 		NewAttoFILFromFIL(2),
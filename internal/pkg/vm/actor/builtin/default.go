@@ -3,11 +3,15 @@ package builtin
 import (
 	specs "github.com/filecoin-project/specs-actors/actors/builtin"
 	"github.com/filecoin-project/specs-actors/actors/builtin/account"
+	"github.com/filecoin-project/specs-actors/actors/builtin/cron"
 	init_ "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
 	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
 	"github.com/filecoin-project/specs-actors/actors/builtin/multisig"
 	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
 	"github.com/filecoin-project/specs-actors/actors/builtin/power"
+	"github.com/filecoin-project/specs-actors/actors/builtin/reward"
+	"github.com/ipfs/go-cid"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/dispatch"
 )
@@ -23,3 +27,17 @@ var DefaultActors = dispatch.NewBuilder().
 	Add(specs.StoragePowerActorCodeID, &power.Actor{}).
 	Add(specs.StorageMinerActorCodeID, &miner.Actor{}).
 	Build()
+
+// DefaultActorStates constructs an empty state value for each builtin actor
+// with state, indexed by its code CID.
+var DefaultActorStates = map[cid.Cid]func() interface{}{
+	specs.InitActorCodeID:           func() interface{} { return &init_.State{} },
+	specs.AccountActorCodeID:        func() interface{} { return &account.State{} },
+	specs.MultisigActorCodeID:       func() interface{} { return &multisig.State{} },
+	specs.PaymentChannelActorCodeID: func() interface{} { return &paych.State{} },
+	specs.StoragePowerActorCodeID:   func() interface{} { return &power.State{} },
+	specs.StorageMinerActorCodeID:   func() interface{} { return &miner.State{} },
+	specs.StorageMarketActorCodeID:  func() interface{} { return &market.State{} },
+	specs.RewardActorCodeID:         func() interface{} { return &reward.State{} },
+	specs.CronActorCodeID:           func() interface{} { return &cron.State{} },
+}
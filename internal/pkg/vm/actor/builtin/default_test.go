@@ -0,0 +1,44 @@
+package builtin_test
+
+import (
+	"io"
+	"testing"
+
+	specs "github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor/builtin"
+)
+
+func TestDefaultActorStates(t *testing.T) {
+	tf.UnitTest(t)
+
+	codes := map[string]cid.Cid{
+		"init":            specs.InitActorCodeID,
+		"account":         specs.AccountActorCodeID,
+		"multisig":        specs.MultisigActorCodeID,
+		"payment channel": specs.PaymentChannelActorCodeID,
+		"storage power":   specs.StoragePowerActorCodeID,
+		"storage miner":   specs.StorageMinerActorCodeID,
+		"storage market":  specs.StorageMarketActorCodeID,
+		"reward":          specs.RewardActorCodeID,
+		"cron":            specs.CronActorCodeID,
+	}
+	for name, code := range codes {
+		t.Run(name, func(t *testing.T) {
+			newState, ok := builtin.DefaultActorStates[code]
+			require.True(t, ok)
+
+			// Each state decodes with its actor's CBOR codec.
+			st := newState()
+			_, ok = st.(interface{ UnmarshalCBOR(io.Reader) error })
+			assert.True(t, ok)
+			// Each call returns a fresh value to decode into.
+			assert.False(t, st == newState())
+		})
+	}
+	assert.Len(t, builtin.DefaultActorStates, len(codes))
+}
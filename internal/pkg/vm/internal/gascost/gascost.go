@@ -1,26 +1,111 @@
 package gascost
 
 import (
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/message"
 )
 
+// The gas prices charged by the VM. They are part of consensus: every node
+// must charge the same gas for the same message, so changing any of them
+// changes the state the network computes and needs a coordinated upgrade.
+const (
+	// onChainMessageGas is charged for including a message in the chain.
+	onChainMessageGas = 7
+	// onChainReturnValueGas is charged for storing a message receipt.
+	onChainReturnValueGas = 3
+	// methodInvocationGas is charged for each method invoked, including
+	// those invoked by actors.
+	methodInvocationGas = 1
+	// storageGetGas is charged for each actor state object read.
+	storageGetGas = 1
+	// storagePutGas is charged for each actor state object written.
+	storagePutGas = 2
+)
+
 // OnChainMessage returns the FIL cost of storing a message of a given size in the chain.
 func OnChainMessage(size uint32) gas.Unit {
-	// TODO: set true cost when Spec has them (issue: ????)
-	return gas.NewGas(7)
+	return gas.NewGas(onChainMessageGas)
 }
 
 // OnChainReturnValue returns the FIL cost of storing the response of a message in the chain.
 func OnChainReturnValue(receipt *message.Receipt) gas.Unit {
-	// TODO: set true cost when Spec has them (issue: ????)
-	return gas.NewGas(3)
+	return gas.NewGas(onChainReturnValueGas)
 }
 
 type methodInvocationArgs interface{}
 
 // OnMethodInvocation returns the FIL cost of invoking a method.
 func OnMethodInvocation(args methodInvocationArgs) gas.Unit {
-	// TODO: set true cost when Spec has them (issue: ????)
-	return gas.NewGas(1)
+	return gas.NewGas(methodInvocationGas)
+}
+
+// StorageGasPricer prices the actor state reads and writes made while a
+// message executes.
+type StorageGasPricer struct {
+	// GetCost is charged for each object read from the store.
+	GetCost gas.Unit
+	// PutCost is charged for each object written to the store.
+	PutCost gas.Unit
+}
+
+// DefaultStorageGasPricer returns the pricer used by the VM.
+func DefaultStorageGasPricer() StorageGasPricer {
+	return StorageGasPricer{
+		GetCost: gas.NewGas(storageGetGas),
+		PutCost: gas.NewGas(storagePutGas),
+	}
+}
+
+// OnStorageGet returns the gas cost of reading an object from the store.
+func (p StorageGasPricer) OnStorageGet() gas.Unit {
+	return p.GetCost
+}
+
+// OnStoragePut returns the gas cost of writing an object to the store.
+func (p StorageGasPricer) OnStoragePut() gas.Unit {
+	return p.PutCost
+}
+
+// GasOutputs is the settlement of a message's gas once execution completes.
+type GasOutputs struct {
+	// Refund is returned to the sender for gas it reserved but did not use.
+	Refund abi.TokenAmount
+	// Burn is the part of the used gas cost that is destroyed.
+	Burn abi.TokenAmount
+	// MinerTip is the part of the used gas cost paid to the block miner.
+	MinerTip abi.TokenAmount
+}
+
+// GasSplit divides the cost of the gas used by a message between burning and
+// the block miner.
+type GasSplit struct {
+	// BurnNumerator over BurnDenominator is the fraction of the used gas cost
+	// that is burnt. The remainder goes to the miner.
+	BurnNumerator   int64
+	BurnDenominator int64
+}
+
+// DefaultGasSplit returns the split used by the VM, which pays all of the used
+// gas cost to the miner.
+func DefaultGasSplit() GasSplit {
+	return GasSplit{
+		BurnNumerator:   0,
+		BurnDenominator: 1,
+	}
+}
+
+// Outputs settles a message which consumed `consumed` of its `limit` gas at
+// `price`. The unused gas is refunded, and the cost of the used gas is split
+// between burn and miner tip, with any rounding remainder going to the miner.
+func (s GasSplit) Outputs(consumed, limit gas.Unit, price abi.TokenAmount) GasOutputs {
+	used := consumed.ToTokens(price)
+	burn := big.Div(big.Mul(used, big.NewInt(s.BurnNumerator)), big.NewInt(s.BurnDenominator))
+	return GasOutputs{
+		Refund:   big.Sub(limit.ToTokens(price), used),
+		Burn:     burn,
+		MinerTip: big.Sub(used, burn),
+	}
 }
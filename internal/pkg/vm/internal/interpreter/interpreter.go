@@ -3,6 +3,7 @@ package interpreter
 import (
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
@@ -17,6 +18,22 @@ type VMInterpreter interface {
 	ApplyTipSetMessages(blocks []BlockMessagesInfo, epoch abi.ChainEpoch, rnd crypto.RandomnessSource) ([]message.Receipt, error)
 }
 
+// MessageHook is called around the application of each message of a tipset.
+// Messages included by more than one block are applied, and hooked, once.
+type MessageHook interface {
+	// BeforeApply is called before `msg` is applied.
+	BeforeApply(msg *types.UnsignedMessage)
+	// AfterApply is called with the receipt of applying `msg`.
+	AfterApply(msg *types.UnsignedMessage, receipt message.Receipt)
+}
+
+// CheckpointHook is called with the state root reached after each message of
+// a tipset is applied, indexed in application order. The root includes the
+// implicit reward messages of the blocks preceding the message's.
+type CheckpointHook interface {
+	Checkpoint(messageIndex int, root cid.Cid)
+}
+
 // BlockMessagesInfo contains messages for one block in a tipset.
 type BlockMessagesInfo struct {
 	BLSMessages  []*types.UnsignedMessage
@@ -2,7 +2,9 @@ package vmcontext
 
 import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/gascost"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/runtime"
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
 )
@@ -11,6 +13,7 @@ import (
 type GasTracker struct {
 	gasLimit    gas.Unit
 	gasConsumed gas.Unit
+	onComplete  func(consumed, limit gas.Unit)
 }
 
 // NewGasTracker initializes a new empty gas tracker
@@ -50,7 +53,33 @@ func (t *GasTracker) GasConsumed() gas.Unit {
 	return t.gasConsumed
 }
 
+// Receipt returns the gas used to record in the message's receipt. It is the
+// gas consumed, which never exceeds the limit.
+func (t *GasTracker) Receipt() gas.Unit {
+	return t.gasConsumed
+}
+
 // RemainingGas returns the gas remaining.
 func (t *GasTracker) RemainingGas() gas.Unit {
 	return gas.Unit(big.Sub(t.gasLimit.AsBigInt(), t.gasConsumed.AsBigInt()))
 }
+
+// OnComplete registers a hook called with the final gas consumed and the gas
+// limit when the tracker is finalized. A nil hook does nothing.
+func (t *GasTracker) OnComplete(hook func(consumed, limit gas.Unit)) {
+	t.onComplete = hook
+}
+
+// Finalize marks the end of gas usage for the message, invoking the
+// completion hook if one is registered.
+func (t *GasTracker) Finalize() {
+	if t.onComplete != nil {
+		t.onComplete(t.gasConsumed, t.gasLimit)
+	}
+}
+
+// Settle returns the refund, burn and miner tip owed for the gas consumed so
+// far against the limit, priced at `price` and divided according to `split`.
+func (t *GasTracker) Settle(price abi.TokenAmount, split gascost.GasSplit) gascost.GasOutputs {
+	return split.Outputs(t.gasConsumed, t.gasLimit, price)
+}
@@ -0,0 +1,176 @@
+package vmcontext_test
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/gascost"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/message"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/runtime"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/internal/vmcontext"
+)
+
+func TestGasTrackerOnComplete(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("hook receives final consumed and limit", func(t *testing.T) {
+		tracker := vmcontext.NewGasTracker(gas.NewGas(100))
+
+		calls := 0
+		var consumed, limit gas.Unit
+		tracker.OnComplete(func(c, l gas.Unit) {
+			calls++
+			consumed, limit = c, l
+		})
+
+		assert.True(t, tracker.TryCharge(gas.NewGas(30)))
+		assert.True(t, tracker.TryCharge(gas.NewGas(12)))
+		tracker.Finalize()
+
+		assert.Equal(t, 1, calls)
+		assert.True(t, consumed.AsBigInt().Equals(gas.NewGas(42).AsBigInt()))
+		assert.True(t, limit.AsBigInt().Equals(gas.NewGas(100).AsBigInt()))
+	})
+
+	t.Run("nil hook is a no-op", func(t *testing.T) {
+		tracker := vmcontext.NewGasTracker(gas.NewGas(100))
+		tracker.Finalize()
+	})
+}
+
+func TestGasTrackerReceipt(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("receipt reflects gas consumed", func(t *testing.T) {
+		tracker := vmcontext.NewGasTracker(gas.NewGas(100))
+		assert.True(t, tracker.TryCharge(gas.NewGas(42)))
+
+		receipt := message.Value(nil).WithGas(tracker.Receipt())
+		assert.True(t, receipt.GasUsed.AsBigInt().Equals(tracker.GasConsumed().AsBigInt()))
+
+		encoded, err := encoding.Encode(receipt)
+		require.NoError(t, err)
+		var decoded message.Receipt
+		require.NoError(t, encoding.Decode(encoded, &decoded))
+		assert.True(t, decoded.GasUsed.AsBigInt().Equals(gas.NewGas(42).AsBigInt()))
+		assert.Equal(t, receipt.ExitCode, decoded.ExitCode)
+		assert.Equal(t, receipt.ReturnValue, decoded.ReturnValue)
+	})
+
+	t.Run("receipt is capped at the limit", func(t *testing.T) {
+		tracker := vmcontext.NewGasTracker(gas.NewGas(100))
+		assert.False(t, tracker.TryCharge(gas.NewGas(101)))
+
+		receipt := message.Failure(exitcode.SysErrOutOfGas, tracker.Receipt())
+		encoded, err := encoding.Encode(receipt)
+		require.NoError(t, err)
+		var decoded message.Receipt
+		require.NoError(t, encoding.Decode(encoded, &decoded))
+		assert.True(t, decoded.GasUsed.AsBigInt().Equals(gas.NewGas(100).AsBigInt()))
+		assert.Equal(t, exitcode.SysErrOutOfGas, decoded.ExitCode)
+	})
+}
+
+func TestGasChargingStore(t *testing.T) {
+	tf.UnitTest(t)
+
+	pricer := gascost.StorageGasPricer{GetCost: gas.NewGas(3), PutCost: gas.NewGas(5)}
+	state := testActorStateHandleState{FieldA: "fakestate"}
+
+	t.Run("read and write charge their cost", func(t *testing.T) {
+		tracker := vmcontext.NewGasTracker(gas.NewGas(100))
+		store := vmcontext.NewGasChargingStore(vm.NewTestStorage(nil), &tracker, pricer)
+
+		c := store.Put(&state)
+		assert.True(t, tracker.GasConsumed().AsBigInt().Equals(gas.NewGas(5).AsBigInt()))
+
+		var out testActorStateHandleState
+		store.Get(c, &out)
+		assert.True(t, tracker.GasConsumed().AsBigInt().Equals(gas.NewGas(8).AsBigInt()))
+	})
+
+	t.Run("exceeding the limit aborts", func(t *testing.T) {
+		tracker := vmcontext.NewGasTracker(gas.NewGas(4))
+		store := vmcontext.NewGasChargingStore(vm.NewTestStorage(nil), &tracker, pricer)
+
+		defer func() {
+			r := recover()
+			require.NotNil(t, r)
+			p, ok := r.(runtime.ExecutionPanic)
+			require.True(t, ok)
+			assert.Equal(t, exitcode.SysErrOutOfGas, p.Code())
+		}()
+		store.Put(&state)
+		t.Fail()
+	})
+}
+
+func TestGasTrackerSettle(t *testing.T) {
+	tf.UnitTest(t)
+
+	price := abi.NewTokenAmount(10)
+	split := gascost.GasSplit{BurnNumerator: 1, BurnDenominator: 4}
+
+	t.Run("fully used message gets no refund", func(t *testing.T) {
+		tracker := vmcontext.NewGasTracker(gas.NewGas(100))
+		require.True(t, tracker.TryCharge(gas.NewGas(100)))
+
+		out := tracker.Settle(price, split)
+		assertTokens(t, 0, out.Refund)
+		assertTokens(t, 250, out.Burn)
+		assertTokens(t, 750, out.MinerTip)
+	})
+
+	t.Run("partially used message is refunded the remainder", func(t *testing.T) {
+		tracker := vmcontext.NewGasTracker(gas.NewGas(100))
+		require.True(t, tracker.TryCharge(gas.NewGas(40)))
+
+		out := tracker.Settle(price, split)
+		assertTokens(t, 600, out.Refund)
+		assertTokens(t, 100, out.Burn)
+		assertTokens(t, 300, out.MinerTip)
+	})
+
+	t.Run("failed message is charged its full limit", func(t *testing.T) {
+		tracker := vmcontext.NewGasTracker(gas.NewGas(100))
+		require.True(t, tracker.TryCharge(gas.NewGas(60)))
+		require.False(t, tracker.TryCharge(gas.NewGas(60)))
+
+		out := tracker.Settle(price, split)
+		assertTokens(t, 0, out.Refund)
+		assertTokens(t, 250, out.Burn)
+		assertTokens(t, 750, out.MinerTip)
+	})
+
+	t.Run("outputs add up to the gas limit cost", func(t *testing.T) {
+		tracker := vmcontext.NewGasTracker(gas.NewGas(100))
+		require.True(t, tracker.TryCharge(gas.NewGas(33)))
+
+		out := tracker.Settle(abi.NewTokenAmount(3), gascost.GasSplit{BurnNumerator: 1, BurnDenominator: 7})
+		total := big.Add(big.Add(out.Refund, out.Burn), out.MinerTip)
+		assertTokens(t, 300, total)
+	})
+
+	t.Run("default split pays all used gas to the miner", func(t *testing.T) {
+		tracker := vmcontext.NewGasTracker(gas.NewGas(100))
+		require.True(t, tracker.TryCharge(gas.NewGas(40)))
+
+		out := tracker.Settle(price, gascost.DefaultGasSplit())
+		assertTokens(t, 600, out.Refund)
+		assertTokens(t, 0, out.Burn)
+		assertTokens(t, 400, out.MinerTip)
+	})
+}
+
+func assertTokens(t *testing.T, expected int64, actual abi.TokenAmount) {
+	assert.True(t, abi.NewTokenAmount(expected).Equals(actual), "expected %d, got %s", expected, actual)
+}
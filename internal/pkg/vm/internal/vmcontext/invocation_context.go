@@ -63,7 +63,7 @@ func (ctx *stateHandleContext) AllowSideEffects(allow bool) {
 }
 
 func (ctx *stateHandleContext) Store() specsruntime.Store {
-	return ctx.rt.Store()
+	return NewGasChargingStore(ctx.rt.Store(), ctx.gasTank, ctx.rt.storagePricer)
 }
 
 func (ctx *invocationContext) invoke() interface{} {
@@ -85,7 +85,7 @@ func (a *runtimeAdapter) State() specsruntime.StateHandle {
 
 // Store implements Runtime.
 func (a *runtimeAdapter) Store() specsruntime.Store {
-	return a.ctx.Runtime().Store()
+	return NewGasChargingStore(a.ctx.Runtime().Store(), a.ctx.gasTank, a.ctx.rt.storagePricer)
 }
 
 // Send implements Runtime.
@@ -39,6 +39,14 @@ type VM struct {
 	store        *storage.VMStorage
 	state        *state.CachedTree
 	currentEpoch abi.ChainEpoch
+	// storagePricer prices the state reads and writes made by actors.
+	storagePricer gascost.StorageGasPricer
+	// gasSplit divides the cost of used gas between burn and the miner.
+	gasSplit gascost.GasSplit
+	// messageHook is called around each tipset message applied, if set.
+	messageHook interpreter.MessageHook
+	// checkpointHook is given the state root after each tipset message, if set.
+	checkpointHook interpreter.CheckpointHook
 }
 
 // ActorImplLookup provides access to upgradeable actor code.
@@ -71,12 +79,27 @@ func NewVM(actorImpls ActorImplLookup, store *storage.VMStorage, st state.Tree)
 		store:      store,
 		state:      state.NewCachedTree(st),
 		context:    context.Background(),
+
+		storagePricer: gascost.DefaultStorageGasPricer(),
+		gasSplit:      gascost.DefaultGasSplit(),
 		// loaded during execution
 		// currentEpoch: ..,
 		// rnd: ..,
 	}
 }
 
+// SetMessageHook sets the hook called around the application of each tipset
+// message. A nil hook is not called.
+func (vm *VM) SetMessageHook(hook interpreter.MessageHook) {
+	vm.messageHook = hook
+}
+
+// SetCheckpointHook sets the hook given the state root reached after each
+// tipset message. A nil hook is not called, and saves flushing the state.
+func (vm *VM) SetCheckpointHook(hook interpreter.CheckpointHook) {
+	vm.checkpointHook = hook
+}
+
 // ApplyGenesisMessage forces the execution of a message in the vm actor.
 //
 // This method is intended to be used in the generation of the genesis block only.
@@ -183,12 +206,15 @@ func (vm *VM) ApplyTipSetMessages(blocks []interpreter.BlockMessagesInfo, epoch
 			}
 
 			// apply message
-			receipt, minerPenaltyCurr, minerGasRewardCurr := vm.applyMessage(m, m.OnChainLen(), rnd)
+			receipt, minerPenaltyCurr, minerGasRewardCurr := vm.applyHookedMessage(m, m.OnChainLen(), rnd)
 
 			// accumulate result
 			minerPenaltyTotal = big.Add(minerPenaltyTotal, minerPenaltyCurr)
 			minerGasRewardTotal = big.Add(minerGasRewardTotal, minerGasRewardCurr)
 			receipts = append(receipts, receipt)
+			if err := vm.checkpoint(len(receipts) - 1); err != nil {
+				return nil, err
+			}
 
 			// flag msg as seen
 			seenMsgs[mcid] = struct{}{}
@@ -207,12 +233,15 @@ func (vm *VM) ApplyTipSetMessages(blocks []interpreter.BlockMessagesInfo, epoch
 
 			// apply message
 			// Note: the on-chain size for SECP messages is different
-			receipt, minerPenaltyCurr, minerGasRewardCurr := vm.applyMessage(&m, sm.OnChainLen(), rnd)
+			receipt, minerPenaltyCurr, minerGasRewardCurr := vm.applyHookedMessage(&m, sm.OnChainLen(), rnd)
 
 			// accumulate result
 			minerPenaltyTotal = big.Add(minerPenaltyTotal, minerPenaltyCurr)
 			minerGasRewardTotal = big.Add(minerGasRewardTotal, minerGasRewardCurr)
 			receipts = append(receipts, receipt)
+			if err := vm.checkpoint(len(receipts) - 1); err != nil {
+				return nil, err
+			}
 
 			// flag msg as seen
 			seenMsgs[mcid] = struct{}{}
@@ -250,6 +279,26 @@ func (vm *VM) ApplyTipSetMessages(blocks []interpreter.BlockMessagesInfo, epoch
 	return receipts, nil
 }
 
+// checkpoint gives the checkpoint hook, if set, the state root reached after
+// the message at `messageIndex`.
+func (vm *VM) checkpoint(messageIndex int) error {
+	if vm.checkpointHook == nil {
+		return nil
+	}
+	if err := vm.store.Flush(); err != nil {
+		return err
+	}
+	if err := vm.state.Commit(context.Background()); err != nil {
+		return err
+	}
+	root, err := vm.state.Flush(context.Background())
+	if err != nil {
+		return err
+	}
+	vm.checkpointHook.Checkpoint(messageIndex, root)
+	return nil
+}
+
 // applyImplicitMessage applies messages automatically generated by the vm itself.
 //
 // This messages do not consume client gas and must not fail.
@@ -296,6 +345,18 @@ func (vm *VM) applyImplicitMessage(imsg internalMessage, rnd crypto.RandomnessSo
 	return ctx.invoke(), nil
 }
 
+// applyHookedMessage applies the message like applyMessage, calling the
+// message hook around it.
+func (vm *VM) applyHookedMessage(msg *types.UnsignedMessage, onChainMsgSize uint32, rnd crypto.RandomnessSource) (message.Receipt, minerPenaltyFIL, gasRewardFIL) {
+	if vm.messageHook == nil {
+		return vm.applyMessage(msg, onChainMsgSize, rnd)
+	}
+	vm.messageHook.BeforeApply(msg)
+	receipt, minerPenalty, gasReward := vm.applyMessage(msg, onChainMsgSize, rnd)
+	vm.messageHook.AfterApply(msg, receipt)
+	return receipt, minerPenalty, gasReward
+}
+
 // applyMessage applies the message to the current state.
 func (vm *VM) applyMessage(msg *types.UnsignedMessage, onChainMsgSize uint32, rnd crypto.RandomnessSource) (message.Receipt, minerPenaltyFIL, gasRewardFIL) {
 	// Dragons: temp until we remove legacy types
@@ -361,6 +422,7 @@ func (vm *VM) applyMessage(msg *types.UnsignedMessage, onChainMsgSize uint32, rn
 	// Note: this should always succeed, due to the sender balance check above
 	// Note: after this point, we nede to return this funds back before exiting
 	vm.transfer(msg.From, builtin.BurntFundsActorAddr, gasLimitCost)
+	gasReward := vm.settleGasOnComplete(msg.From, &gasTank, msgGasPrice)
 
 	// 7. checkpoint state
 	// Even if the message fails, the following accumulated changes will be applied:
@@ -404,7 +466,7 @@ func (vm *VM) applyMessage(msg *types.UnsignedMessage, onChainMsgSize uint32, rn
 				case runtime.ExecutionPanic:
 					p := r.(runtime.ExecutionPanic)
 					vmlog.Warn("Abort during vm execution. %s", p)
-					out = message.Failure(p.Code(), gasTank.GasConsumed())
+					out = message.Failure(p.Code(), gasTank.Receipt())
 					return
 				default:
 					debug.PrintStack()
@@ -417,7 +479,7 @@ func (vm *VM) applyMessage(msg *types.UnsignedMessage, onChainMsgSize uint32, rn
 		ret := ctx.invoke()
 
 		// set return value
-		out = message.Value(ret).WithGas(gasTank.GasConsumed())
+		out = message.Value(ret).WithGas(gasTank.Receipt())
 
 		return
 	}
@@ -435,11 +497,27 @@ func (vm *VM) applyMessage(msg *types.UnsignedMessage, onChainMsgSize uint32, rn
 		// of method execution failure.
 
 		// Note: we are charging the caller not the miner, there is ZERO miner penalty
-		return message.Failure(exitcode.SysErrOutOfGas, gasTank.GasConsumed()), big.Zero(), gasTank.GasConsumed().ToTokens(msgGasPrice)
+		gasTank.Finalize()
+		return message.Failure(exitcode.SysErrOutOfGas, gasTank.Receipt()), big.Zero(), *gasReward
 	}
 
 	// 2. Success!
-	return receipt, big.Zero(), gasTank.GasConsumed().ToTokens(msgGasPrice)
+	gasTank.Finalize()
+	return receipt, big.Zero(), *gasReward
+}
+
+// settleGasOnComplete registers a completion hook on `gasTank` which refunds
+// the sender for the unused part of the gas withheld before execution and
+// sets the returned reward to the miner's share of the used gas.
+// The burnt share stays with the BurntFundsActor, which holds the withheld funds.
+func (vm *VM) settleGasOnComplete(from address.Address, gasTank *GasTracker, gasPrice abi.TokenAmount) *gasRewardFIL {
+	reward := big.Zero()
+	gasTank.OnComplete(func(consumed, limit gas.Unit) {
+		outputs := vm.gasSplit.Outputs(consumed, limit, gasPrice)
+		vm.transfer(builtin.BurntFundsActorAddr, from, outputs.Refund)
+		reward = outputs.MinerTip
+	})
+	return &reward
 }
 
 // transfer debits money from one account and credits it to another.
@@ -530,6 +608,32 @@ func (msg internalMessage) Receiver() address.Address {
 	return msg.to
 }
 
+// gasChargingStore charges gas for every read and write an actor makes to the store.
+type gasChargingStore struct {
+	inner   specsruntime.Store
+	gasTank *GasTracker
+	pricer  gascost.StorageGasPricer
+}
+
+// NewGasChargingStore returns a store charging `gasTank` for each access to `inner`.
+//
+// Note: just visible for testing.
+func NewGasChargingStore(inner specsruntime.Store, gasTank *GasTracker, pricer gascost.StorageGasPricer) specsruntime.Store {
+	return &gasChargingStore{inner: inner, gasTank: gasTank, pricer: pricer}
+}
+
+var _ specsruntime.Store = (*gasChargingStore)(nil)
+
+func (s *gasChargingStore) Put(obj specsruntime.CBORMarshaler) cid.Cid {
+	s.gasTank.Charge(s.pricer.OnStoragePut())
+	return s.inner.Put(obj)
+}
+
+func (s *gasChargingStore) Get(cid cid.Cid, obj specsruntime.CBORUnmarshaler) bool {
+	s.gasTank.Charge(s.pricer.OnStorageGet())
+	return s.inner.Get(cid, obj)
+}
+
 //
 // implement runtime.Store for actorStorage
 //
@@ -0,0 +1,221 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"sort"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-hamt-ipld"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+)
+
+// ActorChange describes how an actor differs between two state trees.
+type ActorChange int
+
+const (
+	// ActorAdded is an actor present only in the second tree.
+	ActorAdded ActorChange = iota
+	// ActorRemoved is an actor present only in the first tree.
+	ActorRemoved
+	// ActorModified is an actor present in both trees with different state.
+	ActorModified
+)
+
+// ActorDiff is an actor which differs between two state trees.
+// Before is nil for added actors and After is nil for removed ones.
+type ActorDiff struct {
+	Address address.Address
+	Change  ActorChange
+	Before  *actor.Actor
+	After   *actor.Actor
+}
+
+// Diff returns the actors added, removed or modified going from the state
+// tree rooted at `a` to the one rooted at `b`, ordered by address.
+// Subtrees with the same CID in both trees are skipped without being loaded.
+func Diff(ctx context.Context, a, b cid.Cid, store cbor.IpldStore) ([]ActorDiff, error) {
+	if a.Equals(b) {
+		return nil, nil
+	}
+	rootA, err := hamt.LoadNode(ctx, store, a, hamt.UseTreeBitWidth(TreeBitWidth))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load state root %s", a)
+	}
+	rootB, err := hamt.LoadNode(ctx, store, b, hamt.UseTreeBitWidth(TreeBitWidth))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load state root %s", b)
+	}
+
+	d := &treeDiffer{store: store}
+	if err := d.diffNodes(ctx, rootA, rootB); err != nil {
+		return nil, err
+	}
+	sort.Slice(d.diffs, func(i, j int) bool {
+		return bytes.Compare(d.diffs[i].Address.Bytes(), d.diffs[j].Address.Bytes()) < 0
+	})
+	return d.diffs, nil
+}
+
+// treeDiffer accumulates the differences between two HAMTs.
+type treeDiffer struct {
+	store cbor.IpldStore
+	diffs []ActorDiff
+}
+
+// diffNodes compares two nodes slot by slot. Keys land in the same slot of
+// both trees, so only slots whose pointers differ need to be inspected.
+func (d *treeDiffer) diffNodes(ctx context.Context, a, b *hamt.Node) error {
+	for slot := 0; slot < 1<<TreeBitWidth; slot++ {
+		pa := pointerAt(a, slot)
+		pb := pointerAt(b, slot)
+		if pa == nil && pb == nil {
+			continue
+		}
+		if pa != nil && pb != nil && pa.Link.Defined() && pb.Link.Defined() {
+			if pa.Link.Equals(pb.Link) {
+				continue
+			}
+			na, err := d.loadNode(ctx, pa.Link)
+			if err != nil {
+				return err
+			}
+			nb, err := d.loadNode(ctx, pb.Link)
+			if err != nil {
+				return err
+			}
+			if err := d.diffNodes(ctx, na, nb); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// The slot holds values directly on at least one side, so the subtree
+		// is small; compare its entries.
+		before, err := d.collect(ctx, pa)
+		if err != nil {
+			return err
+		}
+		after, err := d.collect(ctx, pb)
+		if err != nil {
+			return err
+		}
+		if err := d.diffEntries(before, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffEntries records the differences between two sets of raw HAMT entries.
+func (d *treeDiffer) diffEntries(before, after map[string][]byte) error {
+	for key, raw := range before {
+		afterRaw, found := after[key]
+		if found && bytes.Equal(raw, afterRaw) {
+			continue
+		}
+		diff, err := newActorDiff(key, raw, afterRaw)
+		if err != nil {
+			return err
+		}
+		d.diffs = append(d.diffs, diff)
+	}
+	for key, raw := range after {
+		if _, found := before[key]; found {
+			continue
+		}
+		diff, err := newActorDiff(key, nil, raw)
+		if err != nil {
+			return err
+		}
+		d.diffs = append(d.diffs, diff)
+	}
+	return nil
+}
+
+// collect returns all the entries beneath a pointer, keyed by raw address.
+func (d *treeDiffer) collect(ctx context.Context, p *hamt.Pointer) (map[string][]byte, error) {
+	entries := make(map[string][]byte)
+	if p == nil {
+		return entries, nil
+	}
+	var walk func(p *hamt.Pointer) error
+	walk = func(p *hamt.Pointer) error {
+		for _, kv := range p.KVs {
+			entries[kv.Key] = kv.Value.Raw
+		}
+		if !p.Link.Defined() {
+			return nil
+		}
+		n, err := d.loadNode(ctx, p.Link)
+		if err != nil {
+			return err
+		}
+		for _, child := range n.Pointers {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(p); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (d *treeDiffer) loadNode(ctx context.Context, c cid.Cid) (*hamt.Node, error) {
+	n, err := hamt.LoadNode(ctx, d.store, c, hamt.UseTreeBitWidth(TreeBitWidth))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load state tree node %s", c)
+	}
+	return n, nil
+}
+
+// pointerAt returns the pointer occupying `slot` of a node, or nil if the
+// slot is empty. Pointers are stored compactly, in slot order, for the slots
+// set in the node's bitfield.
+func pointerAt(n *hamt.Node, slot int) *hamt.Pointer {
+	if n.Bitfield.Bit(slot) == 0 {
+		return nil
+	}
+	idx := 0
+	for i := 0; i < slot; i++ {
+		if n.Bitfield.Bit(i) == 1 {
+			idx++
+		}
+	}
+	return n.Pointers[idx]
+}
+
+func newActorDiff(key string, before, after []byte) (ActorDiff, error) {
+	addr, err := address.NewFromBytes([]byte(key))
+	if err != nil {
+		return ActorDiff{}, errors.Wrapf(err, "bad address key %x", key)
+	}
+	diff := ActorDiff{Address: addr, Change: ActorModified}
+	if before == nil {
+		diff.Change = ActorAdded
+	} else if diff.Before, err = decodeActor(before); err != nil {
+		return ActorDiff{}, err
+	}
+	if after == nil {
+		diff.Change = ActorRemoved
+	} else if diff.After, err = decodeActor(after); err != nil {
+		return ActorDiff{}, err
+	}
+	return diff, nil
+}
+
+func decodeActor(raw []byte) (*actor.Actor, error) {
+	var act actor.Actor
+	if err := encoding.Decode(raw, &act); err != nil {
+		return nil, errors.Wrap(err, "failed to decode actor")
+	}
+	return &act, nil
+}
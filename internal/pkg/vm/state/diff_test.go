@@ -0,0 +1,113 @@
+package state
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+func TestDiff(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	bs := bstore.NewBlockstore(repo.NewInMemoryRepo().Datastore())
+	cst := cborutil.NewIpldStore(bs)
+
+	// Enough actors that the HAMT has child nodes.
+	addrGetter := vmaddr.NewForTestGetter()
+	var addrs []address.Address
+	base := NewTree(cst)
+	for i := 0; i < 100; i++ {
+		addr := addrGetter()
+		addrs = append(addrs, addr)
+		require.NoError(t, base.SetActor(ctx, addr, actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(int64(i)))))
+	}
+	baseRoot, err := base.Flush(ctx)
+	require.NoError(t, err)
+
+	// modify loads the base tree, applies `f` and returns the new root.
+	modify := func(f func(tree Tree)) cid.Cid {
+		tree, err := NewTreeLoader().LoadStateTree(ctx, cst, baseRoot)
+		require.NoError(t, err)
+		f(tree)
+		root, err := tree.Flush(ctx)
+		require.NoError(t, err)
+		return root
+	}
+
+	t.Run("identical roots", func(t *testing.T) {
+		diffs, err := Diff(ctx, baseRoot, baseRoot, cst)
+		require.NoError(t, err)
+		assert.Empty(t, diffs)
+	})
+
+	t.Run("balance and nonce change", func(t *testing.T) {
+		changed := addrs[42]
+		root := modify(func(tree Tree) {
+			act, err := tree.GetActor(ctx, changed)
+			require.NoError(t, err)
+			act.Balance = abi.NewTokenAmount(1000)
+			act.IncrementSeqNum()
+			require.NoError(t, tree.SetActor(ctx, changed, act))
+		})
+
+		diffs, err := Diff(ctx, baseRoot, root, cst)
+		require.NoError(t, err)
+		require.Len(t, diffs, 1)
+		assert.Equal(t, changed, diffs[0].Address)
+		assert.Equal(t, ActorModified, diffs[0].Change)
+		assert.True(t, abi.NewTokenAmount(42).Equals(diffs[0].Before.Balance))
+		assert.True(t, abi.NewTokenAmount(1000).Equals(diffs[0].After.Balance))
+		assert.Equal(t, uint64(0), diffs[0].Before.CallSeqNum)
+		assert.Equal(t, uint64(1), diffs[0].After.CallSeqNum)
+	})
+
+	t.Run("added and removed actors", func(t *testing.T) {
+		added := addrGetter()
+		root := modify(func(tree Tree) {
+			require.NoError(t, tree.SetActor(ctx, added, actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(7))))
+		})
+
+		diffs, err := Diff(ctx, baseRoot, root, cst)
+		require.NoError(t, err)
+		require.Len(t, diffs, 1)
+		assert.Equal(t, added, diffs[0].Address)
+		assert.Equal(t, ActorAdded, diffs[0].Change)
+		assert.Nil(t, diffs[0].Before)
+		assert.True(t, abi.NewTokenAmount(7).Equals(diffs[0].After.Balance))
+
+		diffs, err = Diff(ctx, root, baseRoot, cst)
+		require.NoError(t, err)
+		require.Len(t, diffs, 1)
+		assert.Equal(t, added, diffs[0].Address)
+		assert.Equal(t, ActorRemoved, diffs[0].Change)
+		assert.Nil(t, diffs[0].After)
+	})
+
+	t.Run("unrelated trees", func(t *testing.T) {
+		other := NewTree(cst)
+		require.NoError(t, other.SetActor(ctx, addrs[0], actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(0))))
+		otherRoot, err := other.Flush(ctx)
+		require.NoError(t, err)
+
+		diffs, err := Diff(ctx, baseRoot, otherRoot, cst)
+		require.NoError(t, err)
+		assert.Len(t, diffs, 99)
+		for _, d := range diffs {
+			assert.Equal(t, ActorRemoved, d.Change)
+		}
+	})
+}
@@ -32,6 +32,22 @@ func NewVM(st state.Tree, store *storage.VMStorage) Interpreter {
 	return &vm
 }
 
+// MessageHook is called around the application of each message of a tipset.
+type MessageHook = interpreter.MessageHook
+
+// CheckpointHook is given the state root reached after each message of a tipset.
+type CheckpointHook = interpreter.CheckpointHook
+
+// NewVMWithHooks creates a new VM interpreter calling `hook`, if not nil,
+// around the application of each message, and giving `checkpoints`, if not
+// nil, the state root after each.
+func NewVMWithHooks(st state.Tree, store *storage.VMStorage, hook MessageHook, checkpoints CheckpointHook) Interpreter {
+	vm := vmcontext.NewVM(builtin.DefaultActors, store, st)
+	vm.SetMessageHook(hook)
+	vm.SetCheckpointHook(checkpoints)
+	return &vm
+}
+
 // NewStorage creates a new Storage for the VM.
 func NewStorage(bs blockstore.Blockstore) Storage {
 	return storage.NewStorage(bs)
@@ -40,6 +56,9 @@ func NewStorage(bs blockstore.Blockstore) Storage {
 // DefaultActors is a code loader with the built-in actors that come with the system.
 var DefaultActors = builtin.DefaultActors
 
+// DefaultActorStates constructs empty states of the built-in actors for decoding.
+var DefaultActorStates = builtin.DefaultActorStates
+
 // ActorCodeLoader allows yo to load an actor's code based on its id an epoch.
 type ActorCodeLoader = dispatch.CodeLoader
 